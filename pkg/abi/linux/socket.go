@@ -144,6 +144,33 @@ const (
 	PACKET_OUTGOING  = 4 // Outgoing of any type
 )
 
+// Packet socket options from <linux/if_packet.h>
+const (
+	PACKET_ADD_MEMBERSHIP  = 1
+	PACKET_DROP_MEMBERSHIP = 2
+	PACKET_QDISC_BYPASS    = 20
+	PACKET_IGNORE_OUTGOING = 23
+)
+
+// Packet socket membership types from <linux/if_packet.h>
+const (
+	PACKET_MR_MULTICAST = 0
+	PACKET_MR_PROMISC   = 1
+	PACKET_MR_ALLMULTI  = 2
+	PACKET_MR_UNICAST   = 3
+)
+
+// PacketMreq is struct packet_mreq, from <linux/if_packet.h>.
+type PacketMreq struct {
+	IfIndex int32
+	Type    uint16
+	ALen    uint16
+	Address [8]byte
+}
+
+// SizeOfPacketMreq is the size of a struct packet_mreq.
+const SizeOfPacketMreq = 16
+
 // Socket options from socket.h.
 const (
 	SO_DEBUG                 = 1
@@ -507,6 +534,33 @@ const (
 	SCM_RIGHTS      = 0x1
 )
 
+// SCM_TIMESTAMPING is the control message type for SO_TIMESTAMPING, from
+// include/uapi/asm-generic/socket.h.
+const SCM_TIMESTAMPING = SO_TIMESTAMPING
+
+// SO_TIMESTAMPING flags, from include/uapi/linux/net_tstamp.h.
+const (
+	SOF_TIMESTAMPING_TX_HARDWARE  = 1 << 0
+	SOF_TIMESTAMPING_TX_SOFTWARE  = 1 << 1
+	SOF_TIMESTAMPING_RX_HARDWARE  = 1 << 2
+	SOF_TIMESTAMPING_RX_SOFTWARE  = 1 << 3
+	SOF_TIMESTAMPING_SOFTWARE     = 1 << 4
+	SOF_TIMESTAMPING_SYS_HARDWARE = 1 << 5
+	SOF_TIMESTAMPING_RAW_HARDWARE = 1 << 6
+	SOF_TIMESTAMPING_OPT_ID       = 1 << 7
+	SOF_TIMESTAMPING_TX_SCHED     = 1 << 8
+	SOF_TIMESTAMPING_TX_ACK       = 1 << 9
+	SOF_TIMESTAMPING_OPT_CMSG     = 1 << 10
+	SOF_TIMESTAMPING_OPT_TSONLY   = 1 << 11
+	SOF_TIMESTAMPING_OPT_STATS    = 1 << 12
+	SOF_TIMESTAMPING_OPT_PKTINFO  = 1 << 13
+	SOF_TIMESTAMPING_OPT_TX_SWHW  = 1 << 14
+	SOF_TIMESTAMPING_BIND_PHC     = 1 << 15
+
+	SOF_TIMESTAMPING_LAST = SOF_TIMESTAMPING_BIND_PHC
+	SOF_TIMESTAMPING_MASK = SOF_TIMESTAMPING_LAST<<1 - 1
+)
+
 // A ControlMessageHeader is the header for a socket control message.
 //
 // ControlMessageHeader represents struct cmsghdr from linux/socket.h.
@@ -571,6 +625,13 @@ const SizeOfControlMessageTOS = 1
 // SizeOfControlMessageTTL is the size of an IP_TTL control message.
 const SizeOfControlMessageTTL = 4
 
+// SizeOfControlMessageDropCount is the size of an SO_RXQ_OVFL control message.
+const SizeOfControlMessageDropCount = 4
+
+// SizeOfControlMessageTimestamping is the size of an SCM_TIMESTAMPING control
+// message, struct scm_timestamping, which holds three timespecs.
+const SizeOfControlMessageTimestamping = 3 * 16
+
 // SizeOfControlMessageTClass is the size of an IPV6_TCLASS control message.
 const SizeOfControlMessageTClass = 4
 
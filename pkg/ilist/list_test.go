@@ -0,0 +1,467 @@
+// Copyright 2023 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ilist
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+type testEntry struct {
+	Entry
+	value int
+}
+
+// newTestList returns a list of n entries with values 0 to n-1, along with the
+// entries in order.
+func newTestList(n int) (*List, []*testEntry) {
+	var l List
+	es := make([]*testEntry, n)
+	for i := range es {
+		es[i] = &testEntry{value: i}
+		l.PushBack(es[i])
+	}
+	return &l, es
+}
+
+// listValues returns the values of the entries in l, walking l forward and
+// checking that the backward links agree.
+func listValues(t *testing.T, l *List) []int {
+	t.Helper()
+	var vs []int
+	var prev Element
+	for e := l.Front(); e != nil; e = e.Next() {
+		if e.Prev() != prev {
+			t.Fatalf("entry %d has a bad prev link", e.(*testEntry).value)
+		}
+		vs = append(vs, e.(*testEntry).value)
+		prev = e
+	}
+	if l.Back() != prev {
+		t.Fatalf("list tail doesn't match its last entry")
+	}
+	return vs
+}
+
+func TestNth(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 3, 4, 7, 8} {
+		l, es := newTestList(n)
+		for i := -1; i <= n; i++ {
+			var want Element
+			if i >= 0 && i < n {
+				want = es[i]
+			}
+			if got := l.Nth(i); got != want {
+				t.Errorf("len %d: Nth(%d) = %v, want %v", n, i, got, want)
+			}
+		}
+	}
+}
+
+func TestDiverge(t *testing.T) {
+	orig, _ := newTestList(4)
+	// restored maps each entry of a reconstructed list to the original entry
+	// it was restored from.
+	restored := make(map[Element]Element)
+	reconstruct := func(values ...int) *List {
+		var l List
+		for _, v := range values {
+			e := &testEntry{value: v}
+			restored[e] = orig.Nth(v)
+			l.PushBack(e)
+		}
+		return &l
+	}
+	identity := func(a, b Element) bool { return restored[a] == b }
+
+	for _, tc := range []struct {
+		name   string
+		values []int
+		want   int
+	}{
+		{name: "match", values: []int{0, 1, 2, 3}, want: -1},
+		{name: "swapped", values: []int{0, 2, 1, 3}, want: 1},
+		{name: "short", values: []int{0, 1, 2}, want: 3},
+		{name: "long", values: []int{0, 1, 2, 3, 3}, want: 4},
+		{name: "empty", values: nil, want: 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			l := reconstruct(tc.values...)
+			if got := l.TestOnlyDiverge(orig, identity); got != tc.want {
+				t.Errorf("TestOnlyDiverge = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReverse(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, n := range []int{0, 1, 2, 3} {
+		testReverse(t, n)
+	}
+	for i := 0; i < 20; i++ {
+		testReverse(t, 4+rng.Intn(60))
+	}
+}
+
+func testReverse(t *testing.T, n int) {
+	t.Helper()
+	l, _ := newTestList(n)
+	want := listValues(t, l)
+	for i, j := 0, len(want)-1; i < j; i, j = i+1, j-1 {
+		want[i], want[j] = want[j], want[i]
+	}
+	l.Reverse()
+	if got := listValues(t, l); !reflect.DeepEqual(got, want) {
+		t.Errorf("len %d: got %v after Reverse, want %v", n, got, want)
+	}
+}
+
+func TestRotateLeft(t *testing.T) {
+	for _, tc := range []struct {
+		n    int
+		want []int
+	}{
+		{n: 0, want: []int{0, 1, 2, 3, 4}},
+		{n: 2, want: []int{2, 3, 4, 0, 1}},
+		{n: 5, want: []int{0, 1, 2, 3, 4}},
+		{n: 7, want: []int{2, 3, 4, 0, 1}},
+		{n: -1, want: []int{4, 0, 1, 2, 3}},
+		{n: -6, want: []int{4, 0, 1, 2, 3}},
+	} {
+		l, _ := newTestList(5)
+		l.RotateLeft(tc.n)
+		if got := listValues(t, l); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("RotateLeft(%d): got %v, want %v", tc.n, got, tc.want)
+		}
+	}
+
+	var l List
+	l.RotateLeft(3)
+	if !l.Empty() {
+		t.Errorf("RotateLeft on an empty list made it non-empty")
+	}
+}
+
+// valueList returns a list of new entries with the given values.
+func valueList(values ...int) *List {
+	var l List
+	for _, v := range values {
+		l.PushBack(&testEntry{value: v})
+	}
+	return &l
+}
+
+func TestEqual(t *testing.T) {
+	sameValue := func(a, b Element) bool { return a.(*testEntry).value == b.(*testEntry).value }
+	for _, tc := range []struct {
+		name string
+		l, m *List
+		want bool
+	}{
+		{name: "empty", l: valueList(), m: valueList(), want: true},
+		{name: "equal", l: valueList(1, 2, 3), m: valueList(1, 2, 3), want: true},
+		{name: "shorter", l: valueList(1, 2), m: valueList(1, 2, 3), want: false},
+		{name: "longer", l: valueList(1, 2, 3), m: valueList(1, 2), want: false},
+		{name: "mismatch", l: valueList(1, 2, 3), m: valueList(1, 4, 3), want: false},
+	} {
+		if got := tc.l.Equal(tc.m, sameValue); got != tc.want {
+			t.Errorf("%s: Equal = %t, want %t", tc.name, got, tc.want)
+		}
+	}
+
+	// Mismatches short-circuit.
+	calls := 0
+	valueList(1, 2, 3).Equal(valueList(4, 5, 6), func(a, b Element) bool {
+		calls++
+		return false
+	})
+	if calls != 1 {
+		t.Errorf("Equal called eq %d times after a mismatch, want 1", calls)
+	}
+}
+
+func TestInsertSorted(t *testing.T) {
+	// Compare tens only, so that values in the same ten sort equally.
+	less := func(a, b Element) bool { return a.(*testEntry).value/10 < b.(*testEntry).value/10 }
+	var l List
+	for _, v := range []int{30, 10, 50, 31, 0, 60, 11} {
+		l.InsertSorted(&testEntry{value: v}, less)
+	}
+	if got, want := listValues(t, &l), []int{0, 10, 11, 30, 31, 50, 60}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got list %v, want %v", got, want)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		idx  int
+		want []int
+	}{
+		{name: "head", idx: 0, want: []int{10, 1, 2}},
+		{name: "middle", idx: 1, want: []int{0, 10, 2}},
+		{name: "tail", idx: 2, want: []int{0, 1, 10}},
+	} {
+		l, es := newTestList(3)
+		old := es[tc.idx]
+		l.Replace(old, &testEntry{value: 10})
+		if got := listValues(t, l); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+		if old.Next() != nil || old.Prev() != nil {
+			t.Errorf("%s: replaced element is still linked", tc.name)
+		}
+	}
+
+	l, es := newTestList(1)
+	l.Replace(es[0], &testEntry{value: 10})
+	if got, want := listValues(t, l), []int{10}; !reflect.DeepEqual(got, want) {
+		t.Errorf("single: got %v, want %v", got, want)
+	}
+}
+
+func TestTake(t *testing.T) {
+	for _, tc := range []struct {
+		n        int
+		wantTake []int
+		wantRest []int
+	}{
+		{n: 0, wantTake: nil, wantRest: []int{0, 1, 2, 3}},
+		{n: 1, wantTake: []int{0}, wantRest: []int{1, 2, 3}},
+		{n: 3, wantTake: []int{0, 1, 2}, wantRest: []int{3}},
+		{n: 4, wantTake: []int{0, 1, 2, 3}, wantRest: nil},
+		{n: 10, wantTake: []int{0, 1, 2, 3}, wantRest: nil},
+	} {
+		l, _ := newTestList(4)
+		m := l.Take(tc.n)
+		if got := listValues(t, &m); !reflect.DeepEqual(got, tc.wantTake) {
+			t.Errorf("Take(%d) = %v, want %v", tc.n, got, tc.wantTake)
+		}
+		if got := listValues(t, l); !reflect.DeepEqual(got, tc.wantRest) {
+			t.Errorf("Take(%d) left %v, want %v", tc.n, got, tc.wantRest)
+		}
+	}
+}
+
+func TestForEach(t *testing.T) {
+	l, es := newTestList(5)
+	var visited []int
+	l.ForEach(func(i int, e Element) {
+		if e != Element(es[i]) {
+			t.Errorf("ForEach passed element %d at index %d", e.(*testEntry).value, i)
+		}
+		visited = append(visited, i)
+	})
+	if want := []int{0, 1, 2, 3, 4}; !reflect.DeepEqual(visited, want) {
+		t.Errorf("ForEach visited indices %v, want %v", visited, want)
+	}
+
+	var empty List
+	empty.ForEach(func(int, Element) {
+		t.Errorf("ForEach called fn on an empty list")
+	})
+}
+
+func TestCount(t *testing.T) {
+	l, _ := newTestList(6)
+	for _, tc := range []struct {
+		name string
+		pred func(Element) bool
+		want int
+	}{
+		{name: "none", pred: func(Element) bool { return false }, want: 0},
+		{name: "even", pred: func(e Element) bool { return e.(*testEntry).value%2 == 0 }, want: 3},
+		{name: "all", pred: func(Element) bool { return true }, want: 6},
+	} {
+		if got := l.Count(tc.pred); got != tc.want {
+			t.Errorf("%s: Count = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+	if got, want := listValues(t, l), []int{0, 1, 2, 3, 4, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Count modified the list to %v, want %v", got, want)
+	}
+}
+
+func TestRing(t *testing.T) {
+	root := &testEntry{value: -1}
+	r := NewRing(root, true)
+	if !r.Empty() {
+		t.Fatalf("new ring isn't empty")
+	}
+	es := make([]*testEntry, 4)
+	for i := range es {
+		es[i] = &testEntry{value: i}
+		// Add inserts after the root, so the ring is built in reverse.
+		r.Add(es[i])
+	}
+
+	ringValues := func() []int {
+		var vs []int
+		r.ForEach(func(e Element) { vs = append(vs, e.(*testEntry).value) })
+		return vs
+	}
+	if got, want := ringValues(), []int{3, 2, 1, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got ring %v, want %v", got, want)
+	}
+
+	r.Remove(es[2])
+	if got, want := ringValues(), []int{3, 1, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got ring %v after removing 2, want %v", got, want)
+	}
+	if !RingEmpty(es[2]) {
+		t.Errorf("removed element isn't a ring of its own")
+	}
+
+	r.ForEach(func(e Element) { r.Remove(e) })
+	if !r.Empty() {
+		t.Errorf("ring isn't empty after removing every element")
+	}
+	if r.Root() != Element(root) {
+		t.Errorf("ring root changed")
+	}
+}
+
+func TestMoveBeforeAfter(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		before  bool
+		e, mark int
+		want    []int
+	}{
+		{name: "tail before head", before: true, e: 4, mark: 0, want: []int{4, 0, 1, 2, 3}},
+		{name: "head before tail", before: true, e: 0, mark: 4, want: []int{1, 2, 3, 0, 4}},
+		{name: "already before", before: true, e: 1, mark: 2, want: []int{0, 1, 2, 3, 4}},
+		{name: "before self", before: true, e: 2, mark: 2, want: []int{0, 1, 2, 3, 4}},
+		{name: "adjacent before", before: true, e: 2, mark: 1, want: []int{0, 2, 1, 3, 4}},
+		{name: "head after tail", e: 0, mark: 4, want: []int{1, 2, 3, 4, 0}},
+		{name: "tail after head", e: 4, mark: 0, want: []int{0, 4, 1, 2, 3}},
+		{name: "already after", e: 3, mark: 2, want: []int{0, 1, 2, 3, 4}},
+		{name: "after self", e: 2, mark: 2, want: []int{0, 1, 2, 3, 4}},
+		{name: "adjacent after", e: 1, mark: 2, want: []int{0, 2, 1, 3, 4}},
+	} {
+		l, es := newTestList(5)
+		if tc.before {
+			l.MoveBefore(es[tc.e], es[tc.mark])
+		} else {
+			l.MoveAfter(es[tc.e], es[tc.mark])
+		}
+		if got := listValues(t, l); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSwapList(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		l, m []int
+	}{
+		{name: "both empty"},
+		{name: "empty and non-empty", m: []int{1, 2}},
+		{name: "non-empty and empty", l: []int{1, 2}},
+		{name: "both non-empty", l: []int{1, 2, 3}, m: []int{4}},
+	} {
+		l, m := valueList(tc.l...), valueList(tc.m...)
+		l.SwapList(m)
+		if got := listValues(t, l); !reflect.DeepEqual(got, tc.m) {
+			t.Errorf("%s: got l = %v, want %v", tc.name, got, tc.m)
+		}
+		if got := listValues(t, m); !reflect.DeepEqual(got, tc.l) {
+			t.Errorf("%s: got m = %v, want %v", tc.name, got, tc.l)
+		}
+	}
+}
+
+func TestDedup(t *testing.T) {
+	sameValue := func(a, b Element) bool { return a.(*testEntry).value == b.(*testEntry).value }
+	for _, tc := range []struct {
+		name   string
+		values []int
+		want   []int
+	}{
+		{name: "empty"},
+		{name: "no duplicates", values: []int{1, 2, 3}, want: []int{1, 2, 3}},
+		{name: "head run", values: []int{1, 1, 1, 2, 3}, want: []int{1, 2, 3}},
+		{name: "middle run", values: []int{1, 2, 2, 3}, want: []int{1, 2, 3}},
+		{name: "tail run", values: []int{1, 2, 3, 3}, want: []int{1, 2, 3}},
+		{name: "all equal", values: []int{4, 4, 4}, want: []int{4}},
+	} {
+		l := valueList(tc.values...)
+		l.Dedup(sameValue)
+		if got := listValues(t, l); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	// The first element of each run is kept.
+	l, es := valueList(), []*testEntry{{value: 1}, {value: 1}}
+	for _, e := range es {
+		l.PushBack(e)
+	}
+	l.Dedup(sameValue)
+	if l.Front() != Element(es[0]) || l.Back() != Element(es[0]) {
+		t.Errorf("Dedup didn't keep the first element of a run")
+	}
+}
+
+func TestFindFirst(t *testing.T) {
+	l, es := newTestList(5)
+	for _, tc := range []struct {
+		name  string
+		value int
+		want  Element
+	}{
+		{name: "head", value: 0, want: es[0]},
+		{name: "middle", value: 2, want: es[2]},
+		{name: "tail", value: 4, want: es[4]},
+		{name: "no match", value: 5, want: nil},
+	} {
+		got := l.FindFirst(func(e Element) bool { return e.(*testEntry).value == tc.value })
+		if got != tc.want {
+			t.Errorf("%s: FindFirst = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	// Only the first match is returned.
+	if got := l.FindFirst(func(e Element) bool { return e.(*testEntry).value > 1 }); got != Element(es[2]) {
+		t.Errorf("FindFirst returned %v, want the first match %v", got, es[2])
+	}
+}
+
+func TestInsertListAfter(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		mark int
+		m    []int
+		want []int
+	}{
+		{name: "head", mark: 0, m: []int{10, 11}, want: []int{0, 10, 11, 1, 2}},
+		{name: "middle", mark: 1, m: []int{10, 11}, want: []int{0, 1, 10, 11, 2}},
+		{name: "tail", mark: 2, m: []int{10, 11}, want: []int{0, 1, 2, 10, 11}},
+		{name: "empty", mark: 1, want: []int{0, 1, 2}},
+	} {
+		l, es := newTestList(3)
+		m := valueList(tc.m...)
+		l.InsertListAfter(es[tc.mark], m)
+		if got := listValues(t, l); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+		if !m.Empty() {
+			t.Errorf("%s: inserted list isn't empty", tc.name)
+		}
+	}
+}
@@ -176,6 +176,22 @@ func (l *openFDList) InsertBefore(a, e *OpenFD) {
 	}
 }
 
+// InsertSorted inserts e into l, which must be sorted per less, after the last
+// element that doesn't sort after e. Elements that sort equally are kept in
+// insertion order.
+//
+// NOTE: This is an O(n) operation. l is searched from the back, so inserting
+// elements in sorted order is O(1).
+func (l *openFDList) InsertSorted(e *OpenFD, less func(a, b *OpenFD) bool) {
+	for b := l.Back(); b != nil; b = (openFDElementMapper{}.linkerFor(b)).Prev() {
+		if !less(e, b) {
+			l.InsertAfter(b, e)
+			return
+		}
+	}
+	l.PushFront(e)
+}
+
 // Remove removes e from l.
 //
 //go:nosplit
@@ -200,6 +216,285 @@ func (l *openFDList) Remove(e *OpenFD) {
 	linker.SetPrev(nil)
 }
 
+// Reverse reverses the order of the elements in l in place.
+//
+//go:nosplit
+func (l *openFDList) Reverse() {
+	for e := l.head; e != nil; {
+		linker := openFDElementMapper{}.linkerFor(e)
+		next := linker.Next()
+		linker.SetNext(linker.Prev())
+		linker.SetPrev(next)
+		e = next
+	}
+	l.head, l.tail = l.tail, l.head
+}
+
+// RotateLeft rotates l so that the element n positions from the front becomes
+// the new front, moving the elements before it to the back. n may be greater
+// than the length of l, and a negative n rotates l to the right.
+//
+// NOTE: This is an O(n) operation.
+//
+//go:nosplit
+func (l *openFDList) RotateLeft(n int) {
+	count := l.Len()
+	if count == 0 {
+		return
+	}
+	n %= count
+	if n < 0 {
+		n += count
+	}
+	if n == 0 {
+		return
+	}
+
+	newHead := l.head
+	for i := 0; i < n; i++ {
+		newHead = openFDElementMapper{}.linkerFor(newHead).Next()
+	}
+	newHeadLinker := openFDElementMapper{}.linkerFor(newHead)
+	newTail := newHeadLinker.Prev()
+
+	// Join the ends of the list, then split it before newHead.
+	openFDElementMapper{}.linkerFor(l.tail).SetNext(l.head)
+	openFDElementMapper{}.linkerFor(l.head).SetPrev(l.tail)
+	newHeadLinker.SetPrev(nil)
+	openFDElementMapper{}.linkerFor(newTail).SetNext(nil)
+
+	l.head = newHead
+	l.tail = newTail
+}
+
+// Equal returns true iff l and m have the same length and eq returns true for
+// each pair of elements at the same position in l and m.
+func (l *openFDList) Equal(m *openFDList, eq func(a, b *OpenFD) bool) bool {
+	a, b := l.head, m.head
+	for a != nil && b != nil {
+		if !eq(a, b) {
+			return false
+		}
+		a = (openFDElementMapper{}.linkerFor(a)).Next()
+		b = (openFDElementMapper{}.linkerFor(b)).Next()
+	}
+	return a == nil && b == nil
+}
+
+// TestOnlyDiverge returns the index of the first position at which l and m
+// differ, either because eq returns false for their elements or because one
+// list ends before the other. If l and m are equal per Equal, TestOnlyDiverge
+// returns -1.
+//
+// TestOnlyDiverge is useful for checking that a list reconstructed by
+// save/restore matches the original, where eq maps restored elements to the
+// originals. It should only be used in tests.
+func (l *openFDList) TestOnlyDiverge(m *openFDList, eq func(a, b *OpenFD) bool) int {
+	i := 0
+	a, b := l.head, m.head
+	for a != nil && b != nil {
+		if !eq(a, b) {
+			return i
+		}
+		a = (openFDElementMapper{}.linkerFor(a)).Next()
+		b = (openFDElementMapper{}.linkerFor(b)).Next()
+		i++
+	}
+	if a != nil || b != nil {
+		return i
+	}
+	return -1
+}
+
+// Replace replaces old with new in l. old must be in l, and new must not be in
+// any list.
+//
+//go:nosplit
+func (l *openFDList) Replace(old, new *OpenFD) {
+	oldLinker := openFDElementMapper{}.linkerFor(old)
+	newLinker := openFDElementMapper{}.linkerFor(new)
+	prev := oldLinker.Prev()
+	next := oldLinker.Next()
+
+	newLinker.SetPrev(prev)
+	newLinker.SetNext(next)
+	if prev != nil {
+		openFDElementMapper{}.linkerFor(prev).SetNext(new)
+	} else {
+		l.head = new
+	}
+	if next != nil {
+		openFDElementMapper{}.linkerFor(next).SetPrev(new)
+	} else {
+		l.tail = new
+	}
+
+	oldLinker.SetNext(nil)
+	oldLinker.SetPrev(nil)
+}
+
+// Take removes up to n elements from the front of l and returns them as a new
+// list, preserving their order. If n is at least the length of l, all elements
+// are moved to the returned list.
+//
+//go:nosplit
+func (l *openFDList) Take(n int) openFDList {
+	var m openFDList
+	if n <= 0 || l.head == nil {
+		return m
+	}
+
+	last := l.head
+	for i := 1; i < n; i++ {
+		next := openFDElementMapper{}.linkerFor(last).Next()
+		if next == nil {
+			break
+		}
+		last = next
+	}
+
+	lastLinker := openFDElementMapper{}.linkerFor(last)
+	m.head = l.head
+	m.tail = last
+	l.head = lastLinker.Next()
+	if l.head != nil {
+		openFDElementMapper{}.linkerFor(l.head).SetPrev(nil)
+	} else {
+		l.tail = nil
+	}
+	lastLinker.SetNext(nil)
+	return m
+}
+
+// ForEach calls fn on each element of l from front to back, along with its
+// zero-based position in l. fn must not remove elements from l.
+func (l *openFDList) ForEach(fn func(i int, e *OpenFD)) {
+	i := 0
+	for e := l.head; e != nil; e = (openFDElementMapper{}.linkerFor(e)).Next() {
+		fn(i, e)
+		i++
+	}
+}
+
+// Count returns the number of elements in l for which pred returns true.
+//
+// NOTE: This is an O(n) operation.
+func (l *openFDList) Count(pred func(*OpenFD) bool) (count int) {
+	for e := l.head; e != nil; e = (openFDElementMapper{}.linkerFor(e)).Next() {
+		if pred(e) {
+			count++
+		}
+	}
+	return count
+}
+
+// MoveBefore moves e, which must be in l, to just before mark, which must also
+// be in l. It is a no-op if e is mark or is already just before mark.
+//
+//go:nosplit
+func (l *openFDList) MoveBefore(e, mark *OpenFD) {
+	if e == mark || (openFDElementMapper{}.linkerFor(mark)).Prev() == e {
+		return
+	}
+	l.Remove(e)
+	l.InsertBefore(mark, e)
+}
+
+// MoveAfter moves e, which must be in l, to just after mark, which must also
+// be in l. It is a no-op if e is mark or is already just after mark.
+//
+//go:nosplit
+func (l *openFDList) MoveAfter(e, mark *OpenFD) {
+	if e == mark || (openFDElementMapper{}.linkerFor(mark)).Next() == e {
+		return
+	}
+	l.Remove(e)
+	l.InsertAfter(mark, e)
+}
+
+// SwapList exchanges the contents of l and m.
+//
+//go:nosplit
+func (l *openFDList) SwapList(m *openFDList) {
+	l.head, m.head = m.head, l.head
+	l.tail, m.tail = m.tail, l.tail
+}
+
+// Dedup removes each element of l for which eq returns true when compared to
+// the preceding element, keeping the first element of each run of equal
+// elements. This is typically used on a sorted list. Removed elements are not
+// otherwise modified; the caller retains ownership of them.
+//
+// NOTE: This is an O(n) operation.
+func (l *openFDList) Dedup(eq func(a, b *OpenFD) bool) {
+	if l.head == nil {
+		return
+	}
+	prev := l.head
+	for e := (openFDElementMapper{}.linkerFor(prev)).Next(); e != nil; {
+		next := (openFDElementMapper{}.linkerFor(e)).Next()
+		if eq(prev, e) {
+			l.Remove(e)
+		} else {
+			prev = e
+		}
+		e = next
+	}
+}
+
+// FindFirst returns the first element of l for which pred returns true, or nil
+// if there is no such element.
+func (l *openFDList) FindFirst(pred func(*OpenFD) bool) *OpenFD {
+	for e := l.head; e != nil; e = (openFDElementMapper{}.linkerFor(e)).Next() {
+		if pred(e) {
+			return e
+		}
+	}
+	return nil
+}
+
+// InsertListAfter inserts list m after mark, emptying m. mark must be an
+// element of l.
+//
+//go:nosplit
+func (l *openFDList) InsertListAfter(mark *OpenFD, m *openFDList) {
+	if m.head == nil {
+		return
+	}
+
+	markLinker := openFDElementMapper{}.linkerFor(mark)
+	next := markLinker.Next()
+
+	markLinker.SetNext(m.head)
+	openFDElementMapper{}.linkerFor(m.head).SetPrev(mark)
+	openFDElementMapper{}.linkerFor(m.tail).SetNext(next)
+
+	if next != nil {
+		openFDElementMapper{}.linkerFor(next).SetPrev(m.tail)
+	} else {
+		l.tail = m.tail
+	}
+
+	m.head = nil
+	m.tail = nil
+}
+
+// Nth returns the element at index i of l, or nil if l has no such element.
+//
+// NOTE: This is an O(n) operation.
+//
+//go:nosplit
+func (l *openFDList) Nth(i int) *OpenFD {
+	if i < 0 {
+		return nil
+	}
+	e := l.Front()
+	for ; e != nil && i > 0; i-- {
+		e = (openFDElementMapper{}.linkerFor(e)).Next()
+	}
+	return e
+}
+
 // Entry is a default implementation of Linker. Users can add anonymous fields
 // of this type to their structs to make them automatically implement the
 // methods needed by List.
@@ -282,3 +577,53 @@ func openFDRingEmpty(e *OpenFD) bool {
 	linker := openFDElementMapper{}.linkerFor(e)
 	return linker.Next() == e
 }
+
+// Ring is a handle on a ring (circularly-linked list) built with RingInit and
+// RingAdd. A ring is identified by its root element, which acts as a sentinel:
+// it is never visited by ForEach and must not be removed through the handle.
+type openFDRing struct {
+	root *OpenFD
+}
+
+// NewRing returns a handle on the ring containing root. If init is true, root
+// is first initialized as the only element of a new ring.
+func openFDNewRing(root *OpenFD, init bool) openFDRing {
+	if init {
+		openFDRingInit(root)
+	}
+	return openFDRing{root: root}
+}
+
+// Root returns the root element of r.
+func (r openFDRing) Root() *OpenFD {
+	return r.root
+}
+
+// Add adds e to r, after the root.
+func (r openFDRing) Add(e *OpenFD) {
+	openFDRingAdd(r.root, e)
+}
+
+// Remove removes e from r. e must not be the root of r.
+func (r openFDRing) Remove(e *OpenFD) {
+	if e == r.root {
+		panic("Ring.Remove called on the ring's root")
+	}
+	openFDRingRemove(e)
+}
+
+// Empty returns true if r contains no elements other than its root.
+func (r openFDRing) Empty() bool {
+	return openFDRingEmpty(r.root)
+}
+
+// ForEach calls fn once on each element of r other than the root, going
+// around the ring starting after the root. fn may remove the element it is
+// passed from r.
+func (r openFDRing) ForEach(fn func(*OpenFD)) {
+	for e := (openFDElementMapper{}.linkerFor(r.root)).Next(); e != r.root; {
+		next := (openFDElementMapper{}.linkerFor(e)).Next()
+		fn(e)
+		e = next
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/context"
 	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/log"
 	"gvisor.dev/gvisor/pkg/marshal/primitive"
 	"gvisor.dev/gvisor/pkg/sentry/arch"
 	"gvisor.dev/gvisor/pkg/sentry/fsimpl/kernfs"
@@ -101,6 +102,11 @@ var _ vfs.FileDescriptionImpl = (*masterFileDescription)(nil)
 
 // Release implements vfs.FileDescriptionImpl.Release.
 func (mfd *masterFileDescription) Release(ctx context.Context) {
+	// Closing the master hangs up the replica, so its session loses its
+	// controlling terminal.
+	if err := mfd.t.replicaKTTY.Hangup(); err != nil {
+		log.Warningf("failed to hang up terminal %d: %v", mfd.t.n, err)
+	}
 	mfd.inode.root.masterClose(ctx, mfd.t)
 }
 
@@ -93,19 +93,43 @@ func (d *dentry) cacheNegativeLookupLocked(name string) {
 		// that negativeChildren increase upto max.
 		if d.negativeChildren >= maxCachedNegativeChildren {
 			d.negativeChildrenCache.init(maxCachedNegativeChildren)
+			d.negativeChildrenCache.setOnEvict(d.evictNegativeChildLocked)
+			names := make([]string, 0, d.negativeChildren)
 			for childName, child := range d.children {
 				if child == nil {
-					d.negativeChildrenCache.add(childName)
+					names = append(names, childName)
 				}
 			}
+			d.negativeChildrenCache.addAll(names)
 		}
-	} else if victim := d.negativeChildrenCache.add(name); victim != "" {
-		// If victim is a negative entry in d.children, delete it.
-		if child, ok := d.children[victim]; ok && child == nil {
-			delete(d.children, victim)
-			d.negativeChildren--
+	} else {
+		d.negativeChildrenCache.add(name)
+	}
+}
+
+// evictNegativeChildLocked deletes name from d.children if it is a negative
+// entry.
+//
+// +checklocks:d.childrenMu
+func (d *dentry) evictNegativeChildLocked(name string) {
+	if child, ok := d.children[name]; ok && child == nil {
+		delete(d.children, name)
+		d.negativeChildren--
+	}
+}
+
+// clearNegativeChildrenLocked drops all negative entries from d.children and
+// empties d.negativeChildrenCache.
+//
+// +checklocks:d.childrenMu
+func (d *dentry) clearNegativeChildrenLocked() {
+	for name, child := range d.children {
+		if child == nil {
+			delete(d.children, name)
 		}
 	}
+	d.negativeChildren = 0
+	d.negativeChildrenCache.clear(false /* evict */)
 }
 
 type createSyntheticOpts struct {
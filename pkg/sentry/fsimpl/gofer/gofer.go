@@ -105,6 +105,14 @@ const (
 // stringFixedCache is a fixed sized cache, once initialized,
 // its size never changes.
 //
+// stringFixedCache isn't synchronized. Each directory dentry owns one, which
+// is protected by the dentry's childrenMu along with the children map that it
+// indexes.
+//
+// Cached names are only as fresh as the connection to the remote filesystem
+// they were looked up on. When that connection is re-established on restore,
+// the owning dentry clears the cache.
+//
 // +stateify savable
 type stringFixedCache struct {
 	// namesList stores negative names with fifo list.
@@ -112,6 +120,11 @@ type stringFixedCache struct {
 	// at the moment you pushed it to the list.
 	namesList stringList
 	size      uint64
+
+	// onEvict, if not nil, is called with each name evicted from the cache.
+	// It is called with the owner's locks held, and must not re-enter the
+	// cache.
+	onEvict func(name string) `state:"nosave"`
 }
 
 func (cache *stringFixedCache) isInited() bool {
@@ -126,15 +139,54 @@ func (cache *stringFixedCache) init(size uint64) {
 	cache.size = size
 }
 
-// Update will push name to the front of the list,
-// and pop the tail value.
-func (cache *stringFixedCache) add(name string) string {
+// setOnEvict sets the function called with each name evicted from the cache.
+func (cache *stringFixedCache) setOnEvict(onEvict func(name string)) {
+	cache.onEvict = onEvict
+}
+
+// add pushes name to the front of the list and evicts the name at the tail.
+// cache.onEvict is called with the evicted name, if any, before its slot is
+// unlinked and reused.
+func (cache *stringFixedCache) add(name string) {
 	tail := cache.namesList.Back()
-	victimName := tail.str
+	if tail.str != "" && cache.onEvict != nil {
+		cache.onEvict(tail.str)
+	}
 	tail.str = name
 	cache.namesList.Remove(tail)
 	cache.namesList.PushFront(tail)
-	return victimName
+}
+
+// addAll adds names as if add were called for each of them in order, skipping
+// names that appear earlier in names or are already in the cache.
+func (cache *stringFixedCache) addAll(names []string) {
+	seen := make(map[string]struct{}, cache.size+uint64(len(names)))
+	for e := cache.namesList.Front(); e != nil; e = e.Next() {
+		if e.str != "" {
+			seen[e.str] = struct{}{}
+		}
+	}
+	for _, name := range names {
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		cache.add(name)
+	}
+}
+
+// clear empties the cache and returns it to its uninitialized state. If evict
+// is true, cache.onEvict is called with each cached name first.
+func (cache *stringFixedCache) clear(evict bool) {
+	if evict && cache.onEvict != nil {
+		for e := cache.namesList.Front(); e != nil; e = e.Next() {
+			if e.str != "" {
+				cache.onEvict(e.str)
+			}
+		}
+	}
+	cache.namesList.Reset()
+	cache.size = 0
 }
 
 // +stateify savable
@@ -0,0 +1,148 @@
+// Copyright 2023 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gofer
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"gvisor.dev/gvisor/pkg/sync"
+)
+
+// cachedNames returns the names in cache, most recently added first.
+func cachedNames(cache *stringFixedCache) []string {
+	var names []string
+	for e := cache.namesList.Front(); e != nil; e = e.Next() {
+		if e.str != "" {
+			names = append(names, e.str)
+		}
+	}
+	return names
+}
+
+func TestStringFixedCacheEvictCallback(t *testing.T) {
+	var cache stringFixedCache
+	cache.init(2)
+	var evicted []string
+	cache.setOnEvict(func(name string) {
+		// The name is still cached when the callback runs.
+		if got := cachedNames(&cache); got[len(got)-1] != name {
+			t.Errorf("evicted name %q isn't the least recently added of %v", name, got)
+		}
+		evicted = append(evicted, name)
+	})
+
+	cache.add("a")
+	cache.add("b")
+	if len(evicted) != 0 {
+		t.Fatalf("got evictions %v while filling the cache, want none", evicted)
+	}
+	cache.add("c")
+	cache.addAll([]string{"d", "e"})
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(evicted, want) {
+		t.Errorf("got evictions %v, want %v", evicted, want)
+	}
+	if got, want := cachedNames(&cache), []string{"e", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got cached names %v, want %v", got, want)
+	}
+}
+
+func TestStringFixedCacheAddAllDedup(t *testing.T) {
+	var cache stringFixedCache
+	cache.init(5)
+	var evicted []string
+	cache.setOnEvict(func(name string) { evicted = append(evicted, name) })
+
+	cache.add("a")
+	cache.add("b")
+	// "a" and "b" are already cached, and "c" is repeated in the batch.
+	cache.addAll([]string{"c", "a", "d", "c", "b", "e"})
+	if got, want := cachedNames(&cache), []string{"e", "d", "c", "b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got cached names %v, want %v", got, want)
+	}
+	if len(evicted) != 0 {
+		t.Errorf("got evictions %v, want none", evicted)
+	}
+}
+
+func TestStringFixedCacheClear(t *testing.T) {
+	for _, evict := range []bool{false, true} {
+		var cache stringFixedCache
+		cache.init(4)
+		var evicted []string
+		cache.setOnEvict(func(name string) { evicted = append(evicted, name) })
+		cache.addAll([]string{"a", "b", "c"})
+
+		cache.clear(evict)
+		if cache.isInited() {
+			t.Errorf("evict=%t: cache is still initialized after clear", evict)
+		}
+		if got := cachedNames(&cache); len(got) != 0 {
+			t.Errorf("evict=%t: got cached names %v after clear, want none", evict, got)
+		}
+		var want []string
+		if evict {
+			want = []string{"c", "b", "a"}
+		}
+		if !reflect.DeepEqual(evicted, want) {
+			t.Errorf("evict=%t: got evictions %v, want %v", evict, evicted, want)
+		}
+
+		// The cache can be reinitialized and used again.
+		cache.init(2)
+		cache.add("d")
+		if got, want := cachedNames(&cache), []string{"d"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("evict=%t: got cached names %v after reinit, want %v", evict, got, want)
+		}
+	}
+}
+
+func TestNegativeChildrenCacheConcurrent(t *testing.T) {
+	d := &dentry{
+		fs:   &filesystem{},
+		impl: &lisafsDentry{},
+	}
+	const (
+		goroutines = 8
+		perG       = maxCachedNegativeChildren / 2
+	)
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < perG; i++ {
+				name := fmt.Sprintf("%d-%d", g, i)
+				d.childrenMu.Lock()
+				d.cacheNegativeLookupLocked(name)
+				if child, ok := d.children[name]; !ok || child != nil {
+					t.Errorf("negative lookup of %q wasn't cached", name)
+				}
+				d.childrenMu.Unlock()
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	d.childrenMu.Lock()
+	defer d.childrenMu.Unlock()
+	if got, want := d.negativeChildren, maxCachedNegativeChildren; got != want {
+		t.Errorf("got %d negative children, want %d", got, want)
+	}
+	if got, want := len(d.children), maxCachedNegativeChildren; got != want {
+		t.Errorf("got %d cached children, want %d", got, want)
+	}
+}
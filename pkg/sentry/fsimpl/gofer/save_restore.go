@@ -227,10 +227,10 @@ func (fs *filesystem) CompleteRestore(ctx context.Context, opts vfs.CompleteRest
 func (d *dentry) restoreDescendantsRecursive(ctx context.Context, opts *vfs.CompleteRestoreOptions) error {
 	d.childrenMu.Lock()
 	defer d.childrenMu.Unlock()
+	// The remote filesystem may have changed since the checkpoint, so names
+	// that were cached as nonexistent can't be trusted.
+	d.clearNegativeChildrenLocked()
 	for _, child := range d.children {
-		if child == nil {
-			continue
-		}
 		if child.isSynthetic() {
 			continue
 		}
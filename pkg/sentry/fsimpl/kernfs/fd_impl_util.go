@@ -51,10 +51,10 @@ type GenericDirectoryFDOptions struct {
 // GenericDirectoryFD implements vfs.FileDescriptionImpl for a generic directory
 // inode that uses OrderChildren to track child nodes.
 //
-// Note that GenericDirectoryFD holds a lock over OrderedChildren while calling
-// IterDirents callback. The IterDirents callback therefore cannot hash or
-// unhash children, or recursively call IterDirents on the same underlying
-// inode.
+// Note that GenericDirectoryFD holds a lock over OrderedChildren only while
+// taking a snapshot of the children to be listed, not while calling the
+// IterDirents callback. The IterDirents callback still cannot recursively call
+// IterDirents on the same underlying FD.
 //
 // Must be initialize with Init before first use.
 //
@@ -76,7 +76,25 @@ type GenericDirectoryFD struct {
 	mu sync.Mutex `state:"nosave"`
 
 	// off is the current directory offset. Protected by "mu".
+	//
+	// Offsets 0 and 1 refer to "." and "..". Offset 2+N refers to the child
+	// of children whose slot offset is N or, if children is Sorted, to the
+	// child named names[N]. Either way, an offset returned by telldir(3)
+	// still refers to the same child after other children are inserted or
+	// removed; if that child has itself been removed, iteration resumes at the
+	// next surviving child. Offsets past the end of children are passed on to
+	// the inode's IterDirents as relative offsets.
 	off int64
+
+	// names holds the names of children in listing order if children is
+	// Sorted. It is taken when listing starts from offset 0, so children
+	// inserted into a Sorted directory are listed once the FD is rewound.
+	// Protected by "mu".
+	names []string `state:"nosave"`
+
+	// last is the name of the child most recently listed, which lets listing
+	// resume after it without searching children for off. Protected by "mu".
+	last string `state:"nosave"`
 }
 
 // NewGenericDirectoryFD creates a new GenericDirectoryFD and returns its
@@ -152,13 +170,32 @@ func (fd *GenericDirectoryFD) inode() Inode {
 	return fd.dentry().inode
 }
 
+// childSnapshot is a copy of the state of a slot, taken by
+// OrderedChildren.snapshot while holding OrderedChildren.mu.
+type childSnapshot struct {
+	name  string
+	inode Inode
+
+	// off is the slot offset of the child, or its index in
+	// GenericDirectoryFD.names if the directory is Sorted.
+	off int64
+}
+
+// direntBatchSize is the maximum number of children that IterDirents holds
+// references on at a time.
+const direntBatchSize = 64
+
 // IterDirents implements vfs.FileDescriptionImpl.IterDirents. IterDirents holds
-// o.mu when calling cb.
+// fd.mu, but not fd.children.mu, when calling cb.
 func (fd *GenericDirectoryFD) IterDirents(ctx context.Context, cb vfs.IterDirentsCallback) error {
 	fd.mu.Lock()
 	defer fd.mu.Unlock()
 
 	opts := vfs.StatOptions{Mask: linux.STATX_INO}
+	if fd.children.sorted && (fd.off == 0 || fd.names == nil) {
+		fd.names = fd.children.names()
+	}
+
 	// Handle ".".
 	if fd.off == 0 {
 		stat, err := fd.inode().Stat(ctx, fd.filesystem(), opts)
@@ -196,33 +233,73 @@ func (fd *GenericDirectoryFD) IterDirents(ctx context.Context, cb vfs.IterDirent
 		fd.off++
 	}
 
-	// Handle static children.
-	fd.children.mu.RLock()
-	defer fd.children.mu.RUnlock()
+	// Handle static children, a batch at a time. Each child's offset is
+	// stable across insertions and removals of its siblings, so concurrent
+	// insertions and removals between batches can't cause a child to be
+	// listed twice or a pre-existing child to be skipped.
+	//
 	// fd.off accounts for "." and "..", but fd.children do not track
 	// these.
-	childIdx := fd.off - 2
-	for it := fd.children.nthLocked(childIdx); it != nil; it = it.Next() {
-		stat, err := it.inode.Stat(ctx, fd.filesystem(), opts)
+	var childrenEnd int64
+	for {
+		var (
+			batch []childSnapshot
+			more  bool
+		)
+		if fd.children.sorted {
+			batch, childrenEnd, more = fd.children.snapshotNames(fd.names, fd.off-2, direntBatchSize)
+		} else {
+			batch, childrenEnd, more = fd.children.snapshot(fd.off-2, fd.last, direntBatchSize)
+		}
+		if err := fd.handleChildren(ctx, cb, opts, batch); err != nil {
+			return err
+		}
+		if !more {
+			break
+		}
+	}
+	childrenEnd += 2
+
+	// Skip over offsets of children that have since been removed.
+	if fd.off < childrenEnd {
+		fd.off = childrenEnd
+	}
+
+	var err error
+	relOffset := fd.off - childrenEnd
+	fd.off, err = fd.inode().IterDirents(ctx, fd.vfsfd.Mount(), cb, fd.off, relOffset)
+	return err
+}
+
+// handleChildren passes batch to cb, advancing fd.off past each child that cb
+// accepts, and drops the references that the snapshot of batch took.
+//
+// Precondition: fd.mu must be locked.
+func (fd *GenericDirectoryFD) handleChildren(ctx context.Context, cb vfs.IterDirentsCallback, opts vfs.StatOptions, batch []childSnapshot) error {
+	defer func() {
+		for _, c := range batch {
+			c.inode.DecRef(ctx)
+		}
+	}()
+
+	for _, c := range batch {
+		stat, err := c.inode.Stat(ctx, fd.filesystem(), opts)
 		if err != nil {
 			return err
 		}
 		dirent := vfs.Dirent{
-			Name:    it.name,
+			Name:    c.name,
 			Type:    linux.FileMode(stat.Mode).DirentType(),
 			Ino:     stat.Ino,
-			NextOff: fd.off + 1,
+			NextOff: c.off + 3,
 		}
 		if err := cb.Handle(dirent); err != nil {
 			return err
 		}
-		fd.off++
+		fd.off = c.off + 3
+		fd.last = c.name
 	}
-
-	var err error
-	relOffset := fd.off - int64(len(fd.children.set)) - 2
-	fd.off, err = fd.inode().IterDirents(ctx, fd.vfsfd.Mount(), cb, fd.off, relOffset)
-	return err
+	return nil
 }
 
 // Seek implements vfs.FileDescriptionImpl.Seek.
@@ -239,7 +316,7 @@ func (fd *GenericDirectoryFD) Seek(ctx context.Context, offset int64, whence int
 		switch fd.seekEnd {
 		case SeekEndStaticEntries:
 			fd.children.mu.RLock()
-			offset += int64(len(fd.children.set))
+			offset += fd.children.nextOff
 			offset += 2 // '.' and '..' aren't tracked in children.
 			fd.children.mu.RUnlock()
 		case SeekEndZero:
@@ -16,6 +16,7 @@ package kernfs
 
 import (
 	"fmt"
+	"sort"
 
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/atomicbitops"
@@ -394,6 +395,12 @@ type slot struct {
 	name   string
 	inode  Inode
 	static bool
+
+	// off is the offset of this slot among its siblings. It is assigned when
+	// the slot is inserted and offsets are never reused, so a slot can be
+	// found again after insertions and removals of other children. Offsets
+	// increase in listing order unless the directory is Sorted.
+	off int64
 	slotEntry
 }
 
@@ -408,6 +415,15 @@ type OrderedChildrenOptions struct {
 	//
 	// Note that writable users must implement the sticky bit (I_SVTX).
 	Writable bool
+
+	// Sorted indicates whether children are listed in lexicographic order of
+	// their names rather than in insertion order.
+	Sorted bool
+
+	// MaxEntries is the maximum number of children that may be tracked. If
+	// MaxEntries is 0, the number of children is unlimited. Insertions that
+	// would exceed the limit fail with ENOSPC.
+	MaxEntries int
 }
 
 // inodeWithOrderedChildren allows extraction of an OrderedChildren from an
@@ -442,9 +458,25 @@ type OrderedChildren struct {
 	// methods that would modify the children return EPERM. Immutable.
 	writable bool
 
-	mu    sync.RWMutex `state:"nosave"`
+	// Are children kept in lexicographic order of their names? Immutable.
+	sorted bool
+
+	// Maximum number of children, or 0 if unlimited. Immutable.
+	maxEntries int
+
+	mu sync.RWMutex `state:"nosave"`
+
+	// order is the list of children in listing order, used by readdir. If
+	// sorted is true, it is kept in order of name.
 	order slotList
-	set   map[string]*slot
+
+	// set indexes the children in order by name, so that Lookup and
+	// duplicate detection do not need to scan order. set and order always
+	// contain the same slots.
+	set map[string]*slot
+
+	// nextOff is the offset assigned to the next inserted child.
+	nextOff int64
 }
 
 // orderedChildren implements inodeWithOrderedChildren.orderedChildren.
@@ -455,6 +487,8 @@ func (o *OrderedChildren) orderedChildren() *OrderedChildren {
 // Init initializes an OrderedChildren.
 func (o *OrderedChildren) Init(opts OrderedChildrenOptions) {
 	o.writable = opts.Writable
+	o.sorted = opts.Sorted
+	o.maxEntries = opts.MaxEntries
 	o.set = make(map[string]*slot)
 }
 
@@ -485,13 +519,23 @@ func (o *OrderedChildren) Destroy(ctx context.Context) {
 //
 // Postcondition: Caller's references on inodes are transferred to o.
 func (o *OrderedChildren) Populate(children map[string]Inode) uint32 {
+	names := make([]string, 0, len(children))
+	for name := range children {
+		names = append(names, name)
+	}
+	if o.sorted {
+		// Inserting in order lets InsertSorted append each child, rather than
+		// walk the children to find the insertion point.
+		sort.Strings(names)
+	}
 	var links uint32
-	for name, child := range children {
+	for _, name := range names {
+		child := children[name]
 		if child.Mode().IsDir() {
 			links++
 		}
 		if err := o.insert(name, child, true); err != nil {
-			panic(fmt.Sprintf("Collision when attempting to insert child %q (%+v)", name, child))
+			panic(fmt.Sprintf("Failed to insert child %q (%+v): %v", name, child, err))
 		}
 	}
 	return links
@@ -521,6 +565,42 @@ func (o *OrderedChildren) ForEachChild(fn func(string, Inode)) {
 	}
 }
 
+// ForEachChildReverse calls fn on all children tracked by o, in reverse
+// listing order. If fn returns true, the child is removed from o as if by
+// Remove. fn must not call other methods on o.
+func (o *OrderedChildren) ForEachChildReverse(ctx context.Context, fn func(string, Inode) bool) {
+	var removed []Inode
+	o.mu.Lock()
+	o.forEachReverseLocked(func(s *slot) {
+		if fn(s.name, s.inode) {
+			o.unlinkLocked(s)
+			if s.static {
+				removed = append(removed, s.inode)
+			}
+		}
+	})
+	o.mu.Unlock()
+
+	// Drop the refs that o owned on removed static inodes.
+	for _, inode := range removed {
+		inode.DecRef(ctx)
+	}
+}
+
+// forEachReverseLocked calls fn on all children tracked by o, from the back to
+// the front of o.order. fn may remove the slot it is passed from o.
+//
+// Precondition: caller must hold o.mu for writing if fn modifies o, and for
+// reading otherwise.
+func (o *OrderedChildren) forEachReverseLocked(fn func(*slot)) {
+	for it := o.order.Back(); it != nil; {
+		// Advance before calling fn, since fn may unlink it.
+		prev := it.Prev()
+		fn(it)
+		it = prev
+	}
+}
+
 // IterDirents implements Inode.IterDirents.
 func (o *OrderedChildren) IterDirents(ctx context.Context, mnt *vfs.Mount, cb vfs.IterDirentsCallback, offset, relOffset int64) (newOffset int64, err error) {
 	// All entries from OrderedChildren have already been handled in
@@ -535,6 +615,17 @@ func (o *OrderedChildren) HasChildren() bool {
 	return len(o.set) > 0
 }
 
+// NumChildren returns the number of children tracked by o. Unlike walking
+// o.order, this is O(1), so it is suitable for large synthetic directories.
+//
+// This isn't the directory's link count, which only counts subdirectories and
+// is maintained by the directory with IncLinks and DecLinks.
+func (o *OrderedChildren) NumChildren() int {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return len(o.set)
+}
+
 // Insert inserts a dynamic child into o. This ignores the writability of o, as
 // this is not part of the vfs.FilesystemImpl interface, and is a lower-level operation.
 func (o *OrderedChildren) Insert(name string, child Inode) error {
@@ -551,17 +642,18 @@ func (o *OrderedChildren) Inserter(name string, makeChild func() Inode) (Inode,
 	if _, ok := o.set[name]; ok {
 		return nil, linuxerr.EEXIST
 	}
+	if err := o.checkCapacityLocked(); err != nil {
+		return nil, err
+	}
 
 	// Note: We must not fail after we call makeChild().
 
 	child := makeChild()
-	s := &slot{
+	o.linkLocked(&slot{
 		name:   name,
 		inode:  child,
 		static: false,
-	}
-	o.order.PushBack(s)
-	o.set[name] = s
+	})
 	return child, nil
 }
 
@@ -576,13 +668,72 @@ func (o *OrderedChildren) insert(name string, child Inode, static bool) error {
 	if _, ok := o.set[name]; ok {
 		return linuxerr.EEXIST
 	}
-	s := &slot{
+	if err := o.checkCapacityLocked(); err != nil {
+		return err
+	}
+	o.linkLocked(&slot{
 		name:   name,
 		inode:  child,
 		static: static,
+	})
+	return nil
+}
+
+// checkCapacityLocked returns ENOSPC if o can't track another child.
+//
+// Precondition: caller must hold o.mu for reading or writing.
+func (o *OrderedChildren) checkCapacityLocked() error {
+	if o.maxEntries > 0 && len(o.set) >= o.maxEntries {
+		return linuxerr.ENOSPC
+	}
+	return nil
+}
+
+// linkLocked adds s to o and assigns it the next offset. s is appended to
+// o.order, or inserted in order of name if o is sorted.
+//
+// Preconditions:
+//   - Caller must hold o.mu for writing.
+//   - o must not already have a child named s.name. Callers are expected to
+//     return EEXIST in this case.
+func (o *OrderedChildren) linkLocked(s *slot) {
+	if _, ok := o.set[s.name]; ok {
+		panic(fmt.Sprintf("linkLocked called with duplicate child %q", s.name))
+	}
+	o.set[s.name] = s
+	s.off = o.nextOff
+	o.nextOff++
+	if o.sorted {
+		o.order.InsertSorted(s, slotNameLess)
+	} else {
+		o.order.PushBack(s)
+	}
+}
+
+// slotNameLess orders slots by name.
+func slotNameLess(a, b *slot) bool {
+	return a.name < b.name
+}
+
+// Remove removes the child named name from o. Like Insert, this ignores the
+// writability of o, and may be used to remove children at runtime. If the
+// child was inserted by Populate, o's reference on it is dropped.
+//
+// Dentries that are already cached for the removed child are not invalidated;
+// callers that need this must arrange for the child's Valid to return false.
+func (o *OrderedChildren) Remove(ctx context.Context, name string) error {
+	o.mu.Lock()
+	s, ok := o.set[name]
+	if !ok {
+		o.mu.Unlock()
+		return linuxerr.ENOENT
+	}
+	o.unlinkLocked(s)
+	o.mu.Unlock()
+
+	if s.static {
+		s.inode.DecRef(ctx)
 	}
-	o.order.PushBack(s)
-	o.set[name] = s
 	return nil
 }
 
@@ -592,11 +743,18 @@ func (o *OrderedChildren) removeLocked(name string) {
 		if s.static {
 			panic(fmt.Sprintf("removeLocked called on a static inode: %v", s.inode))
 		}
-		delete(o.set, name)
-		o.order.Remove(s)
+		o.unlinkLocked(s)
 	}
 }
 
+// unlinkLocked removes s from o.
+//
+// Precondition: caller must hold o.mu for writing.
+func (o *OrderedChildren) unlinkLocked(s *slot) {
+	delete(o.set, s.name)
+	o.order.Remove(s)
+}
+
 // Precondition: caller must hold o.mu for reading or writing.
 func (o *OrderedChildren) checkExistingLocked(name string, child Inode) error {
 	s, ok := o.set[name]
@@ -673,32 +831,114 @@ func (o *OrderedChildren) Rename(ctx context.Context, oldname, newname string, c
 	if _, ok := dst.set[newname]; ok {
 		return linuxerr.EEXIST
 	}
+	if dst != o {
+		// Renames within a directory don't change its number of children.
+		if err := dst.checkCapacityLocked(); err != nil {
+			return err
+		}
+	}
 
 	// Remove from src.
 	o.removeLocked(oldname)
 
 	// Add to dst.
-	s := &slot{
+	dst.linkLocked(&slot{
 		name:  newname,
 		inode: child,
-	}
-	dst.order.PushBack(s)
-	dst.set[newname] = s
+	})
 
 	return nil
 }
 
-// nthLocked returns an iterator to the nth child tracked by this object. The
-// iterator is valid until the caller releases o.mu. Returns nil if the
-// requested index falls out of bounds.
+// snapshot returns up to max children tracked by o whose offset is at least
+// off, in listing order, along with the offset that the next inserted child
+// would be assigned and whether any children remain after those returned. The
+// caller must drop the reference that snapshot takes on each returned inode.
+// Children that are being destroyed are skipped.
+//
+// after is the name of the child that was listed before off, if known. If that
+// child is still present, listing resumes after it without a search.
+//
+// Precondition: o isn't sorted.
+func (o *OrderedChildren) snapshot(off int64, after string, max int) ([]childSnapshot, int64, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	var children []childSnapshot
+	it := o.resumeLocked(off, after)
+	for ; it != nil && len(children) < max; it = it.Next() {
+		if !it.inode.TryIncRef() {
+			// The child is being destroyed.
+			continue
+		}
+		children = append(children, childSnapshot{
+			name:  it.name,
+			inode: it.inode,
+			off:   it.off,
+		})
+	}
+	return children, o.nextOff, it != nil
+}
+
+// names returns the names of the children tracked by o, in listing order.
+func (o *OrderedChildren) names() []string {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	names := make([]string, 0, len(o.set))
+	for it := o.order.Front(); it != nil; it = it.Next() {
+		names = append(names, it.name)
+	}
+	return names
+}
+
+// snapshotNames is like snapshot, but for the children named by names[i] for i
+// at least off, which are returned in that order with i as their offset.
+// Children that have since been removed are skipped.
+func (o *OrderedChildren) snapshotNames(names []string, off int64, max int) ([]childSnapshot, int64, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	var children []childSnapshot
+	i := off
+	for ; i < int64(len(names)) && len(children) < max; i++ {
+		s, ok := o.set[names[i]]
+		if !ok || !s.inode.TryIncRef() {
+			// The child was removed or is being destroyed.
+			continue
+		}
+		children = append(children, childSnapshot{
+			name:  s.name,
+			inode: s.inode,
+			off:   i,
+		})
+	}
+	return children, o.nextOff, i < int64(len(names))
+}
+
+// resumeLocked returns an iterator to the first child tracked by this object
+// whose offset is at least off, like seekLocked. If after names the child
+// immediately before off, the iterator is found in constant time.
+//
+// Preconditions:
+//   - Caller must hold o.mu for reading.
+//   - o isn't sorted.
+func (o *OrderedChildren) resumeLocked(off int64, after string) *slot {
+	if s, ok := o.set[after]; ok && s.off+1 == off {
+		return s.Next()
+	}
+	return o.seekLocked(off)
+}
+
+// seekLocked returns an iterator to the first child tracked by this object
+// whose offset is at least off. The iterator is valid until the caller
+// releases o.mu. Returns nil if no such child exists.
 //
-// Preconditon: Caller must hold o.mu for reading.
-func (o *OrderedChildren) nthLocked(i int64) *slot {
-	for it := o.order.Front(); it != nil && i >= 0; it = it.Next() {
-		if i == 0 {
+// Preconditions:
+//   - Caller must hold o.mu for reading.
+//   - o isn't sorted, so offsets increase in listing order.
+func (o *OrderedChildren) seekLocked(off int64) *slot {
+	for it := o.order.Front(); it != nil; it = it.Next() {
+		if it.off >= off {
 			return it
 		}
-		i--
 	}
 	return nil
 }
@@ -0,0 +1,525 @@
+// Copyright 2023 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kernfs
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/sync"
+)
+
+// testInode is an Inode that only implements what OrderedChildren uses.
+type testInode struct {
+	Inode
+	mode linux.FileMode
+}
+
+func (i *testInode) Mode() linux.FileMode { return i.mode }
+
+func (i *testInode) IncRef() {}
+
+func (i *testInode) TryIncRef() bool { return true }
+
+func (i *testInode) DecRef(context.Context) {}
+
+// childNames returns the names of the children of o in listing order, checking
+// that their offsets are unique and, unless o is sorted, increasing.
+func childNames(t *testing.T, o *OrderedChildren) []string {
+	t.Helper()
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	var names []string
+	offs := make(map[int64]string)
+	last := int64(-1)
+	for it := o.order.Front(); it != nil; it = it.Next() {
+		if other, ok := offs[it.off]; ok {
+			t.Fatalf("children %q and %q have the same offset %d", other, it.name, it.off)
+		}
+		offs[it.off] = it.name
+		if !o.sorted && it.off <= last {
+			t.Fatalf("child %q has offset %d, not after the previous offset %d", it.name, it.off, last)
+		}
+		last = it.off
+		names = append(names, it.name)
+	}
+	return names
+}
+
+func TestOrderedChildrenSorted(t *testing.T) {
+	for _, sorted := range []bool{false, true} {
+		var o OrderedChildren
+		o.Init(OrderedChildrenOptions{Sorted: sorted})
+		for _, name := range []string{"c", "a", "d"} {
+			if err := o.Insert(name, &testInode{}); err != nil {
+				t.Fatalf("sorted=%t: Insert(%q) failed: %v", sorted, name, err)
+			}
+		}
+		if err := o.Insert("b", &testInode{}); err != nil {
+			t.Fatalf("sorted=%t: Insert(b) failed: %v", sorted, err)
+		}
+		want := []string{"c", "a", "d", "b"}
+		if sorted {
+			want = []string{"a", "b", "c", "d"}
+		}
+		if got := childNames(t, &o); !reflect.DeepEqual(got, want) {
+			t.Errorf("sorted=%t: got children %v, want %v", sorted, got, want)
+		}
+		if err := o.Insert("a", &testInode{}); err == nil {
+			t.Errorf("sorted=%t: duplicate Insert(a) succeeded", sorted)
+		}
+	}
+}
+
+func TestOrderedChildrenPopulateSorted(t *testing.T) {
+	var o OrderedChildren
+	o.Init(OrderedChildrenOptions{Sorted: true})
+	children := make(map[string]Inode)
+	for _, name := range []string{"e", "b", "d", "a", "c"} {
+		children[name] = &testInode{mode: linux.ModeDirectory}
+	}
+	if got, want := o.Populate(children), uint32(len(children)); got != want {
+		t.Errorf("Populate returned %d links, want %d", got, want)
+	}
+	if got, want := childNames(t, &o), []string{"a", "b", "c", "d", "e"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got children %v, want %v", got, want)
+	}
+}
+
+func TestOrderedChildrenSeekAfterRemove(t *testing.T) {
+	ctx := context.Background()
+	var o OrderedChildren
+	o.Init(OrderedChildrenOptions{})
+	for _, name := range []string{"a", "b", "c", "d"} {
+		if err := o.Insert(name, &testInode{}); err != nil {
+			t.Fatalf("Insert(%q) failed: %v", name, err)
+		}
+	}
+	seek := func(off int64) string {
+		o.mu.RLock()
+		defer o.mu.RUnlock()
+		if it := o.seekLocked(off); it != nil {
+			return it.name
+		}
+		return ""
+	}
+
+	// "Tell" the offsets of c and d, then remove an earlier sibling.
+	o.mu.RLock()
+	cOff, dOff := o.set["c"].off, o.set["d"].off
+	o.mu.RUnlock()
+	if err := o.Remove(ctx, "a"); err != nil {
+		t.Fatalf("Remove(a) failed: %v", err)
+	}
+	if got := seek(cOff); got != "c" {
+		t.Errorf("seek to c's offset after removing a got %q, want c", got)
+	}
+
+	// Seeking to a removed child resumes at the next surviving one.
+	if err := o.Remove(ctx, "c"); err != nil {
+		t.Fatalf("Remove(c) failed: %v", err)
+	}
+	if got := seek(cOff); got != "d" {
+		t.Errorf("seek to removed c's offset got %q, want d", got)
+	}
+
+	// New children don't reuse offsets.
+	if err := o.Insert("e", &testInode{}); err != nil {
+		t.Fatalf("Insert(e) failed: %v", err)
+	}
+	if got := seek(dOff); got != "d" {
+		t.Errorf("seek to d's offset after inserting e got %q, want d", got)
+	}
+	if got, want := childNames(t, &o), []string{"b", "d", "e"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got children %v, want %v", got, want)
+	}
+}
+
+func TestOrderedChildrenSnapshotUnderMutation(t *testing.T) {
+	ctx := context.Background()
+	var o OrderedChildren
+	o.Init(OrderedChildrenOptions{})
+	for _, name := range []string{"a", "b", "c", "d"} {
+		if err := o.Insert(name, &testInode{}); err != nil {
+			t.Fatalf("Insert(%q) failed: %v", name, err)
+		}
+	}
+
+	// Read the first two children, then mutate the directory as if between
+	// two getdents calls.
+	first, _, _ := o.snapshot(0, "", math.MaxInt)
+	var listed []string
+	for _, c := range first[:2] {
+		listed = append(listed, c.name)
+	}
+	resume := first[1].off + 1
+	for _, c := range first {
+		c.inode.DecRef(ctx)
+	}
+	if err := o.Remove(ctx, "a"); err != nil {
+		t.Fatalf("Remove(a) failed: %v", err)
+	}
+	if err := o.Insert("e", &testInode{}); err != nil {
+		t.Fatalf("Insert(e) failed: %v", err)
+	}
+	if err := o.Insert("a", &testInode{}); err != nil {
+		t.Fatalf("Insert(a) failed: %v", err)
+	}
+
+	rest, end, _ := o.snapshot(resume, "", math.MaxInt)
+	for _, c := range rest {
+		listed = append(listed, c.name)
+		c.inode.DecRef(ctx)
+	}
+	// Every pre-existing child is listed exactly once, followed by the
+	// children inserted since, including the new child named a.
+	if want := []string{"a", "b", "c", "d", "e", "a"}; !reflect.DeepEqual(listed, want) {
+		t.Errorf("got listing %v, want %v", listed, want)
+	}
+	if got, want := end, int64(6); got != want {
+		t.Errorf("got end offset %d, want %d", got, want)
+	}
+
+	// Mutations after a snapshot don't affect it.
+	snap, _, _ := o.snapshot(0, "", math.MaxInt)
+	if err := o.Remove(ctx, "b"); err != nil {
+		t.Fatalf("Remove(b) failed: %v", err)
+	}
+	var names []string
+	for _, c := range snap {
+		names = append(names, c.name)
+		c.inode.DecRef(ctx)
+	}
+	if want := []string{"b", "c", "d", "e", "a"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got snapshot %v, want %v", names, want)
+	}
+}
+
+func TestOrderedChildrenSortedSnapshotUnderMutation(t *testing.T) {
+	ctx := context.Background()
+	var o OrderedChildren
+	o.Init(OrderedChildrenOptions{Sorted: true})
+	for _, name := range []string{"d", "b", "a", "c"} {
+		if err := o.Insert(name, &testInode{}); err != nil {
+			t.Fatalf("Insert(%q) failed: %v", name, err)
+		}
+	}
+
+	// Read the first two children, then mutate the directory as if between
+	// two getdents calls.
+	names := o.names()
+	first, _, _ := o.snapshotNames(names, 0, math.MaxInt)
+	var listed []string
+	for _, c := range first[:2] {
+		listed = append(listed, c.name)
+	}
+	resume := first[1].off + 1
+	for _, c := range first {
+		c.inode.DecRef(ctx)
+	}
+	if err := o.Remove(ctx, "c"); err != nil {
+		t.Fatalf("Remove(c) failed: %v", err)
+	}
+	for _, name := range []string{"0", "bb"} {
+		if err := o.Insert(name, &testInode{}); err != nil {
+			t.Fatalf("Insert(%q) failed: %v", name, err)
+		}
+	}
+
+	// Inserting children that sort before the cookie doesn't repeat or skip
+	// any, and removed children aren't listed.
+	rest, _, _ := o.snapshotNames(names, resume, math.MaxInt)
+	for _, c := range rest {
+		listed = append(listed, c.name)
+		c.inode.DecRef(ctx)
+	}
+	if want := []string{"a", "b", "d"}; !reflect.DeepEqual(listed, want) {
+		t.Errorf("got listing %v, want %v", listed, want)
+	}
+
+	// New children are listed in name order once listing restarts.
+	if got, want := o.names(), []string{"0", "a", "b", "bb", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got names %v, want %v", got, want)
+	}
+}
+
+func TestOrderedChildrenSnapshotBatches(t *testing.T) {
+	ctx := context.Background()
+	var o OrderedChildren
+	o.Init(OrderedChildrenOptions{})
+	var want []string
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("child%d", i)
+		want = append(want, name)
+		if err := o.Insert(name, &testInode{}); err != nil {
+			t.Fatalf("Insert(%q) failed: %v", name, err)
+		}
+	}
+
+	const max = 3
+	var (
+		listed []string
+		off    int64
+		after  string
+	)
+	for {
+		batch, _, more := o.snapshot(off, after, max)
+		if len(batch) > max {
+			t.Fatalf("got batch of %d children, want at most %d", len(batch), max)
+		}
+		for _, c := range batch {
+			listed = append(listed, c.name)
+			off, after = c.off+1, c.name
+			c.inode.DecRef(ctx)
+		}
+		if !more {
+			break
+		}
+		if len(listed) == 6 {
+			// Removing the last listed child falls back to seeking by offset.
+			if err := o.Remove(ctx, after); err != nil {
+				t.Fatalf("Remove(%q) failed: %v", after, err)
+			}
+		}
+	}
+	if !reflect.DeepEqual(listed, want) {
+		t.Errorf("got listing %v, want %v", listed, want)
+	}
+}
+
+func TestOrderedChildrenInsertionOrderOffsets(t *testing.T) {
+	var o OrderedChildren
+	o.Init(OrderedChildrenOptions{})
+	for _, name := range []string{"z", "m", "a"} {
+		if err := o.Insert(name, &testInode{}); err != nil {
+			t.Fatalf("Insert(%q) failed: %v", name, err)
+		}
+	}
+	offsets := func() map[string]int64 {
+		o.mu.RLock()
+		defer o.mu.RUnlock()
+		m := make(map[string]int64)
+		for name, s := range o.set {
+			m[name] = s.off
+		}
+		return m
+	}
+	before := offsets()
+
+	// Adding a sibling lists it last and leaves existing offsets alone.
+	if err := o.Insert("b", &testInode{}); err != nil {
+		t.Fatalf("Insert(b) failed: %v", err)
+	}
+	if got, want := childNames(t, &o), []string{"z", "m", "a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got children %v, want %v", got, want)
+	}
+	after := offsets()
+	for name, off := range before {
+		if after[name] != off {
+			t.Errorf("offset of %q changed from %d to %d", name, off, after[name])
+		}
+	}
+}
+
+func TestOrderedChildrenNumChildren(t *testing.T) {
+	ctx := context.Background()
+	var o OrderedChildren
+	o.Init(OrderedChildrenOptions{})
+	const n = 100
+	for i := 0; i < n; i++ {
+		if err := o.Insert(fmt.Sprintf("child%d", i), &testInode{}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if got := o.NumChildren(); got != n {
+		t.Errorf("got %d children, want %d", got, n)
+	}
+	for i := 0; i < n; i += 2 {
+		if err := o.Remove(ctx, fmt.Sprintf("child%d", i)); err != nil {
+			t.Fatalf("Remove failed: %v", err)
+		}
+	}
+	if got, want := o.NumChildren(), n/2; got != want {
+		t.Errorf("got %d children after removals, want %d", got, want)
+	}
+	if got, want := len(childNames(t, &o)), n/2; got != want {
+		t.Errorf("got %d listed children, want %d", got, want)
+	}
+}
+
+func TestOrderedChildrenDuplicateInsert(t *testing.T) {
+	var o OrderedChildren
+	o.Init(OrderedChildrenOptions{})
+	for _, name := range []string{"a", "b"} {
+		if err := o.Insert(name, &testInode{}); err != nil {
+			t.Fatalf("Insert(%q) failed: %v", name, err)
+		}
+	}
+	if err := o.Insert("a", &testInode{}); !linuxerr.Equals(linuxerr.EEXIST, err) {
+		t.Errorf("duplicate Insert(a) got error %v, want EEXIST", err)
+	}
+	made := false
+	if _, err := o.Inserter("b", func() Inode { made = true; return &testInode{} }); !linuxerr.Equals(linuxerr.EEXIST, err) {
+		t.Errorf("duplicate Inserter(b) got error %v, want EEXIST", err)
+	}
+	if made {
+		t.Errorf("duplicate Inserter(b) made a child")
+	}
+	if got, want := childNames(t, &o), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got children %v, want %v", got, want)
+	}
+}
+
+func BenchmarkOrderedChildrenLookup(b *testing.B) {
+	ctx := context.Background()
+	const n = 10000
+	var o OrderedChildren
+	o.Init(OrderedChildrenOptions{})
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("child%d", i)
+		if err := o.Insert(names[i], &testInode{}); err != nil {
+			b.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	b.Run("Map", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := o.Lookup(ctx, names[i%n]); err != nil {
+				b.Fatalf("Lookup failed: %v", err)
+			}
+		}
+	})
+	b.Run("List", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			name := names[i%n]
+			o.mu.RLock()
+			it := o.order.FindFirst(func(s *slot) bool { return s.name == name })
+			o.mu.RUnlock()
+			if it == nil {
+				b.Fatalf("%q not found", name)
+			}
+		}
+	})
+}
+
+func TestOrderedChildrenConcurrentAddRemove(t *testing.T) {
+	ctx := context.Background()
+	var o OrderedChildren
+	o.Init(OrderedChildrenOptions{})
+	const static = 10
+	for i := 0; i < static; i++ {
+		if err := o.Insert(fmt.Sprintf("static%d", i), &testInode{}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				name := fmt.Sprintf("dynamic%d-%d", g, i)
+				if err := o.Insert(name, &testInode{}); err != nil {
+					t.Errorf("Insert(%q) failed: %v", name, err)
+					return
+				}
+				if err := o.Remove(ctx, name); err != nil {
+					t.Errorf("Remove(%q) failed: %v", name, err)
+					return
+				}
+			}
+		}(g)
+	}
+	for i := 0; i < 100; i++ {
+		children, _, _ := o.snapshot(0, "", math.MaxInt)
+		seen := make(map[string]bool)
+		statics := 0
+		for _, c := range children {
+			if seen[c.name] {
+				t.Errorf("child %q listed twice", c.name)
+			}
+			seen[c.name] = true
+			if strings.HasPrefix(c.name, "static") {
+				statics++
+			}
+		}
+		if statics != static {
+			t.Errorf("got %d static children, want %d", statics, static)
+		}
+	}
+	wg.Wait()
+	if got := o.NumChildren(); got != static {
+		t.Errorf("got %d children, want %d", got, static)
+	}
+}
+
+func TestOrderedChildrenForEachChildReverse(t *testing.T) {
+	ctx := context.Background()
+	var o OrderedChildren
+	o.Init(OrderedChildrenOptions{})
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		if err := o.Insert(name, &testInode{}); err != nil {
+			t.Fatalf("Insert(%q) failed: %v", name, err)
+		}
+	}
+	var visited []string
+	o.ForEachChildReverse(ctx, func(name string, _ Inode) bool {
+		visited = append(visited, name)
+		// Remove every other child, starting from the back.
+		return len(visited)%2 == 1
+	})
+	if want := []string{"e", "d", "c", "b", "a"}; !reflect.DeepEqual(visited, want) {
+		t.Errorf("visited %v, want %v", visited, want)
+	}
+	if got, want := childNames(t, &o), []string{"b", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got survivors %v, want %v", got, want)
+	}
+}
+
+func TestOrderedChildrenMaxEntries(t *testing.T) {
+	ctx := context.Background()
+	const max = 3
+	var o OrderedChildren
+	o.Init(OrderedChildrenOptions{MaxEntries: max})
+	for i := 0; i < max; i++ {
+		if err := o.Insert(fmt.Sprintf("child%d", i), &testInode{}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if err := o.Insert("extra", &testInode{}); !linuxerr.Equals(linuxerr.ENOSPC, err) {
+		t.Errorf("Insert beyond the limit got error %v, want ENOSPC", err)
+	}
+	if got := o.NumChildren(); got != max {
+		t.Errorf("got %d children, want %d", got, max)
+	}
+
+	if err := o.Remove(ctx, "child0"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if err := o.Insert("extra", &testInode{}); err != nil {
+		t.Errorf("Insert after a removal failed: %v", err)
+	}
+	if got := o.NumChildren(); got != max {
+		t.Errorf("got %d children, want %d", got, max)
+	}
+}
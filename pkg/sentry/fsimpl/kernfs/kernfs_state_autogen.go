@@ -380,6 +380,7 @@ func (s *slot) StateFields() []string {
 		"name",
 		"inode",
 		"static",
+		"off",
 		"slotEntry",
 	}
 }
@@ -392,7 +393,8 @@ func (s *slot) StateSave(stateSinkObject state.Sink) {
 	stateSinkObject.Save(0, &s.name)
 	stateSinkObject.Save(1, &s.inode)
 	stateSinkObject.Save(2, &s.static)
-	stateSinkObject.Save(3, &s.slotEntry)
+	stateSinkObject.Save(3, &s.off)
+	stateSinkObject.Save(4, &s.slotEntry)
 }
 
 func (s *slot) afterLoad() {}
@@ -402,7 +404,8 @@ func (s *slot) StateLoad(stateSourceObject state.Source) {
 	stateSourceObject.Load(0, &s.name)
 	stateSourceObject.Load(1, &s.inode)
 	stateSourceObject.Load(2, &s.static)
-	stateSourceObject.Load(3, &s.slotEntry)
+	stateSourceObject.Load(3, &s.off)
+	stateSourceObject.Load(4, &s.slotEntry)
 }
 
 func (o *OrderedChildrenOptions) StateTypeName() string {
@@ -412,6 +415,8 @@ func (o *OrderedChildrenOptions) StateTypeName() string {
 func (o *OrderedChildrenOptions) StateFields() []string {
 	return []string{
 		"Writable",
+		"Sorted",
+		"MaxEntries",
 	}
 }
 
@@ -421,6 +426,8 @@ func (o *OrderedChildrenOptions) beforeSave() {}
 func (o *OrderedChildrenOptions) StateSave(stateSinkObject state.Sink) {
 	o.beforeSave()
 	stateSinkObject.Save(0, &o.Writable)
+	stateSinkObject.Save(1, &o.Sorted)
+	stateSinkObject.Save(2, &o.MaxEntries)
 }
 
 func (o *OrderedChildrenOptions) afterLoad() {}
@@ -428,6 +435,8 @@ func (o *OrderedChildrenOptions) afterLoad() {}
 // +checklocksignore
 func (o *OrderedChildrenOptions) StateLoad(stateSourceObject state.Source) {
 	stateSourceObject.Load(0, &o.Writable)
+	stateSourceObject.Load(1, &o.Sorted)
+	stateSourceObject.Load(2, &o.MaxEntries)
 }
 
 func (o *OrderedChildren) StateTypeName() string {
@@ -437,8 +446,11 @@ func (o *OrderedChildren) StateTypeName() string {
 func (o *OrderedChildren) StateFields() []string {
 	return []string{
 		"writable",
+		"sorted",
+		"maxEntries",
 		"order",
 		"set",
+		"nextOff",
 	}
 }
 
@@ -448,8 +460,11 @@ func (o *OrderedChildren) beforeSave() {}
 func (o *OrderedChildren) StateSave(stateSinkObject state.Sink) {
 	o.beforeSave()
 	stateSinkObject.Save(0, &o.writable)
-	stateSinkObject.Save(1, &o.order)
-	stateSinkObject.Save(2, &o.set)
+	stateSinkObject.Save(1, &o.sorted)
+	stateSinkObject.Save(2, &o.maxEntries)
+	stateSinkObject.Save(3, &o.order)
+	stateSinkObject.Save(4, &o.set)
+	stateSinkObject.Save(5, &o.nextOff)
 }
 
 func (o *OrderedChildren) afterLoad() {}
@@ -457,8 +472,11 @@ func (o *OrderedChildren) afterLoad() {}
 // +checklocksignore
 func (o *OrderedChildren) StateLoad(stateSourceObject state.Source) {
 	stateSourceObject.Load(0, &o.writable)
-	stateSourceObject.Load(1, &o.order)
-	stateSourceObject.Load(2, &o.set)
+	stateSourceObject.Load(1, &o.sorted)
+	stateSourceObject.Load(2, &o.maxEntries)
+	stateSourceObject.Load(3, &o.order)
+	stateSourceObject.Load(4, &o.set)
+	stateSourceObject.Load(5, &o.nextOff)
 }
 
 func (i *InodeSymlink) StateTypeName() string {
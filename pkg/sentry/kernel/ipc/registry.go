@@ -87,7 +87,8 @@ func (r *Registry) Find(ctx context.Context, key Key, mode linux.FileMode, creat
 }
 
 // Register adds the given object into Registry.Objects, and assigns it a new
-// ID. It returns an error if all IDs are exhausted.
+// ID. Unless the object is private, it also becomes discoverable by its key
+// through Find. It returns an error if all IDs are exhausted.
 func (r *Registry) Register(m Mechanism) error {
 	id, err := r.newID()
 	if err != nil {
@@ -98,7 +99,11 @@ func (r *Registry) Register(m Mechanism) error {
 	obj.ID = id
 
 	r.objects[id] = m
-	r.keysToIDs[obj.Key] = id
+	// Objects created with IPC_PRIVATE can only be reached through their ID,
+	// and must never be returned by Find.
+	if obj.Key != linux.IPC_PRIVATE {
+		r.keysToIDs[obj.Key] = id
+	}
 
 	return nil
 }
@@ -149,7 +154,10 @@ func (r *Registry) Remove(id ID, creds *auth.Credentials) error {
 	}
 
 	delete(r.objects, obj.ID)
-	delete(r.keysToIDs, obj.Key)
+	// The key may have been dissociated and reused by a newer object.
+	if id, ok := r.keysToIDs[obj.Key]; ok && id == obj.ID {
+		delete(r.keysToIDs, obj.Key)
+	}
 	mech.Destroy()
 
 	return nil
@@ -0,0 +1,123 @@
+// Copyright 2023 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipc
+
+import (
+	"sync"
+	"testing"
+
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+)
+
+// testMechanism is a Mechanism without any mechanism-specific state.
+type testMechanism struct {
+	mu        sync.Mutex
+	obj       *Object
+	destroyed bool
+}
+
+// Lock implements Mechanism.Lock.
+func (m *testMechanism) Lock() {
+	m.mu.Lock()
+}
+
+// Unlock implements Mechanism.Unlock.
+func (m *testMechanism) Unlock() {
+	m.mu.Unlock()
+}
+
+// Object implements Mechanism.Object.
+func (m *testMechanism) Object() *Object {
+	return m.obj
+}
+
+// Destroy implements Mechanism.Destroy.
+func (m *testMechanism) Destroy() {
+	m.destroyed = true
+}
+
+func register(t *testing.T, r *Registry, creds *auth.Credentials, key Key) *testMechanism {
+	t.Helper()
+	m := &testMechanism{obj: NewObject(r.UserNS, key, creds, creds, 0600)}
+	if err := r.Register(m); err != nil {
+		t.Fatalf("Register(key %d): %v", key, err)
+	}
+	return m
+}
+
+func TestRegistryPrivateKeys(t *testing.T) {
+	creds := auth.NewRootCredentials(auth.NewRootUserNamespace())
+	ctx := auth.ContextWithCredentials(context.Background(), creds)
+	r := NewRegistry(creds.UserNamespace)
+
+	const key = 5
+	private1 := register(t, r, creds, linux.IPC_PRIVATE)
+	keyed := register(t, r, creds, key)
+	private2 := register(t, r, creds, linux.IPC_PRIVATE)
+
+	// Private objects are only reachable by ID.
+	if m, err := r.Find(ctx, linux.IPC_PRIVATE, 0600, false /* create */, false /* exclusive */); err != linuxerr.ENOENT {
+		t.Errorf("Find(IPC_PRIVATE) = (%v, %v), want (nil, %v)", m, err, linuxerr.ENOENT)
+	}
+	for _, m := range []*testMechanism{private1, keyed, private2} {
+		if got := r.FindByID(m.obj.ID); got != m {
+			t.Errorf("FindByID(%d) = %v, want %v", m.obj.ID, got, m)
+		}
+	}
+	if m, err := r.Find(ctx, key, 0600, false /* create */, false /* exclusive */); err != nil || m != keyed {
+		t.Errorf("Find(%d) = (%v, %v), want (%v, nil)", key, m, err, keyed)
+	}
+
+	// Removing a private object leaves keyed objects alone.
+	if err := r.Remove(private1.obj.ID, creds); err != nil {
+		t.Fatalf("Remove(%d): %v", private1.obj.ID, err)
+	}
+	if !private1.destroyed {
+		t.Errorf("removed object wasn't destroyed")
+	}
+	if m, err := r.Find(ctx, key, 0600, false /* create */, false /* exclusive */); err != nil || m != keyed {
+		t.Errorf("Find(%d) = (%v, %v), want (%v, nil)", key, m, err, keyed)
+	}
+
+	if err := r.Remove(keyed.obj.ID, creds); err != nil {
+		t.Fatalf("Remove(%d): %v", keyed.obj.ID, err)
+	}
+	if m, err := r.Find(ctx, key, 0600, false /* create */, false /* exclusive */); err != linuxerr.ENOENT {
+		t.Errorf("Find(%d) = (%v, %v) after removal, want (nil, %v)", key, m, err, linuxerr.ENOENT)
+	}
+}
+
+func TestRegistryRemoveReusedKey(t *testing.T) {
+	creds := auth.NewRootCredentials(auth.NewRootUserNamespace())
+	ctx := auth.ContextWithCredentials(context.Background(), creds)
+	r := NewRegistry(creds.UserNamespace)
+
+	// The key now refers to the newer object.
+	const key = 5
+	older := register(t, r, creds, key)
+	newer := register(t, r, creds, key)
+
+	// Removing the older object doesn't dissociate the key from the newer
+	// one.
+	if err := r.Remove(older.obj.ID, creds); err != nil {
+		t.Fatalf("Remove(%d): %v", older.obj.ID, err)
+	}
+	if m, err := r.Find(ctx, key, 0600, false /* create */, false /* exclusive */); err != nil || m != newer {
+		t.Errorf("Find(%d) = (%v, %v), want (%v, nil)", key, m, err, newer)
+	}
+}
@@ -69,6 +69,7 @@ func (i *IPCNamespace) Type() string {
 // Destroy implements nsfs.Namespace.Destroy.
 func (i *IPCNamespace) Destroy(ctx context.Context) {
 	i.shms.Release(ctx)
+	i.semaphores.Release()
 	if i.posixQueues != nil {
 		i.posixQueues.Destroy(ctx)
 	}
@@ -146,6 +146,7 @@ type Kernel struct {
 	rootNetworkNamespace        *inet.Namespace
 	applicationCores            uint
 	useHostCores                bool
+	rtSignalQueueLimit          int
 	extraAuxv                   []arch.AuxEntry
 	vdso                        *loader.VDSO
 	rootUTSNamespace            *UTSNamespace
@@ -371,6 +372,12 @@ type InitKernelArgs struct {
 
 	// PIDNamespace is the root PID namespace.
 	PIDNamespace *PIDNamespace
+
+	// RTSignalQueueLimit is the maximum number of instances of each realtime
+	// signal number that may be queued. Sending a realtime signal whose queue
+	// is full fails with EAGAIN. If RTSignalQueueLimit is 0, a default limit
+	// is used.
+	RTSignalQueueLimit int
 }
 
 // Init initialize the Kernel with no tasks.
@@ -406,6 +413,10 @@ func (k *Kernel) Init(args InitKernelArgs) error {
 	k.cpuClockTickerWakeCh = make(chan struct{}, 1)
 	k.cpuClockTickerStopCond.L = &k.runningTasksMu
 	k.applicationCores = args.ApplicationCores
+	k.rtSignalQueueLimit = args.RTSignalQueueLimit
+	if k.rtSignalQueueLimit == 0 {
+		k.rtSignalQueueLimit = rtSignalCap
+	}
 	if args.UseHostCores {
 		k.useHostCores = true
 		maxCPU, err := hostcpu.MaxPossibleCPU()
@@ -23,6 +23,14 @@ func (k *Kernel) saveDanglingEndpoints() []tcpip.Endpoint {
 	return tcpip.GetDanglingEndpoints()
 }
 
+// afterLoad is invoked by stateify.
+func (k *Kernel) afterLoad() {
+	// Kernels saved before the limit was configurable used the default.
+	if k.rtSignalQueueLimit == 0 {
+		k.rtSignalQueueLimit = rtSignalCap
+	}
+}
+
 // loadDanglingEndpoints is invoked by stateify.
 func (k *Kernel) loadDanglingEndpoints(es []tcpip.Endpoint) {
 	for _, e := range es {
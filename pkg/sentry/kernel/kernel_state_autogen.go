@@ -418,6 +418,7 @@ func (k *Kernel) StateFields() []string {
 		"rootNetworkNamespace",
 		"applicationCores",
 		"useHostCores",
+		"rtSignalQueueLimit",
 		"extraAuxv",
 		"vdso",
 		"rootUTSNamespace",
@@ -458,7 +459,7 @@ func (k *Kernel) StateSave(stateSinkObject state.Sink) {
 	k.beforeSave()
 	var danglingEndpointsValue []tcpip.Endpoint
 	danglingEndpointsValue = k.saveDanglingEndpoints()
-	stateSinkObject.SaveValue(21, danglingEndpointsValue)
+	stateSinkObject.SaveValue(22, danglingEndpointsValue)
 	stateSinkObject.Save(0, &k.featureSet)
 	stateSinkObject.Save(1, &k.timekeeper)
 	stateSinkObject.Save(2, &k.tasks)
@@ -466,38 +467,37 @@ func (k *Kernel) StateSave(stateSinkObject state.Sink) {
 	stateSinkObject.Save(4, &k.rootNetworkNamespace)
 	stateSinkObject.Save(5, &k.applicationCores)
 	stateSinkObject.Save(6, &k.useHostCores)
-	stateSinkObject.Save(7, &k.extraAuxv)
-	stateSinkObject.Save(8, &k.vdso)
-	stateSinkObject.Save(9, &k.rootUTSNamespace)
-	stateSinkObject.Save(10, &k.rootIPCNamespace)
-	stateSinkObject.Save(11, &k.rootAbstractSocketNamespace)
-	stateSinkObject.Save(12, &k.futexes)
-	stateSinkObject.Save(13, &k.globalInit)
-	stateSinkObject.Save(14, &k.syslog)
-	stateSinkObject.Save(15, &k.runningTasks)
-	stateSinkObject.Save(16, &k.cpuClock)
-	stateSinkObject.Save(17, &k.cpuClockTickerRunning)
-	stateSinkObject.Save(18, &k.uniqueID)
-	stateSinkObject.Save(19, &k.nextInotifyCookie)
-	stateSinkObject.Save(20, &k.netlinkPorts)
-	stateSinkObject.Save(22, &k.sockets)
-	stateSinkObject.Save(23, &k.nextSocketRecord)
-	stateSinkObject.Save(24, &k.SpecialOpts)
-	stateSinkObject.Save(25, &k.vfs)
-	stateSinkObject.Save(26, &k.hostMount)
-	stateSinkObject.Save(27, &k.pipeMount)
-	stateSinkObject.Save(28, &k.nsfsMount)
-	stateSinkObject.Save(29, &k.shmMount)
-	stateSinkObject.Save(30, &k.socketMount)
-	stateSinkObject.Save(31, &k.sysVShmDevID)
-	stateSinkObject.Save(32, &k.SleepForAddressSpaceActivation)
-	stateSinkObject.Save(33, &k.ptraceExceptions)
-	stateSinkObject.Save(34, &k.YAMAPtraceScope)
-	stateSinkObject.Save(35, &k.cgroupRegistry)
-	stateSinkObject.Save(36, &k.userCountersMap)
-}
-
-func (k *Kernel) afterLoad() {}
+	stateSinkObject.Save(7, &k.rtSignalQueueLimit)
+	stateSinkObject.Save(8, &k.extraAuxv)
+	stateSinkObject.Save(9, &k.vdso)
+	stateSinkObject.Save(10, &k.rootUTSNamespace)
+	stateSinkObject.Save(11, &k.rootIPCNamespace)
+	stateSinkObject.Save(12, &k.rootAbstractSocketNamespace)
+	stateSinkObject.Save(13, &k.futexes)
+	stateSinkObject.Save(14, &k.globalInit)
+	stateSinkObject.Save(15, &k.syslog)
+	stateSinkObject.Save(16, &k.runningTasks)
+	stateSinkObject.Save(17, &k.cpuClock)
+	stateSinkObject.Save(18, &k.cpuClockTickerRunning)
+	stateSinkObject.Save(19, &k.uniqueID)
+	stateSinkObject.Save(20, &k.nextInotifyCookie)
+	stateSinkObject.Save(21, &k.netlinkPorts)
+	stateSinkObject.Save(23, &k.sockets)
+	stateSinkObject.Save(24, &k.nextSocketRecord)
+	stateSinkObject.Save(25, &k.SpecialOpts)
+	stateSinkObject.Save(26, &k.vfs)
+	stateSinkObject.Save(27, &k.hostMount)
+	stateSinkObject.Save(28, &k.pipeMount)
+	stateSinkObject.Save(29, &k.nsfsMount)
+	stateSinkObject.Save(30, &k.shmMount)
+	stateSinkObject.Save(31, &k.socketMount)
+	stateSinkObject.Save(32, &k.sysVShmDevID)
+	stateSinkObject.Save(33, &k.SleepForAddressSpaceActivation)
+	stateSinkObject.Save(34, &k.ptraceExceptions)
+	stateSinkObject.Save(35, &k.YAMAPtraceScope)
+	stateSinkObject.Save(36, &k.cgroupRegistry)
+	stateSinkObject.Save(37, &k.userCountersMap)
+}
 
 // +checklocksignore
 func (k *Kernel) StateLoad(stateSourceObject state.Source) {
@@ -508,36 +508,38 @@ func (k *Kernel) StateLoad(stateSourceObject state.Source) {
 	stateSourceObject.Load(4, &k.rootNetworkNamespace)
 	stateSourceObject.Load(5, &k.applicationCores)
 	stateSourceObject.Load(6, &k.useHostCores)
-	stateSourceObject.Load(7, &k.extraAuxv)
-	stateSourceObject.Load(8, &k.vdso)
-	stateSourceObject.Load(9, &k.rootUTSNamespace)
-	stateSourceObject.Load(10, &k.rootIPCNamespace)
-	stateSourceObject.Load(11, &k.rootAbstractSocketNamespace)
-	stateSourceObject.Load(12, &k.futexes)
-	stateSourceObject.Load(13, &k.globalInit)
-	stateSourceObject.Load(14, &k.syslog)
-	stateSourceObject.Load(15, &k.runningTasks)
-	stateSourceObject.Load(16, &k.cpuClock)
-	stateSourceObject.Load(17, &k.cpuClockTickerRunning)
-	stateSourceObject.Load(18, &k.uniqueID)
-	stateSourceObject.Load(19, &k.nextInotifyCookie)
-	stateSourceObject.Load(20, &k.netlinkPorts)
-	stateSourceObject.Load(22, &k.sockets)
-	stateSourceObject.Load(23, &k.nextSocketRecord)
-	stateSourceObject.Load(24, &k.SpecialOpts)
-	stateSourceObject.Load(25, &k.vfs)
-	stateSourceObject.Load(26, &k.hostMount)
-	stateSourceObject.Load(27, &k.pipeMount)
-	stateSourceObject.Load(28, &k.nsfsMount)
-	stateSourceObject.Load(29, &k.shmMount)
-	stateSourceObject.Load(30, &k.socketMount)
-	stateSourceObject.Load(31, &k.sysVShmDevID)
-	stateSourceObject.Load(32, &k.SleepForAddressSpaceActivation)
-	stateSourceObject.Load(33, &k.ptraceExceptions)
-	stateSourceObject.Load(34, &k.YAMAPtraceScope)
-	stateSourceObject.Load(35, &k.cgroupRegistry)
-	stateSourceObject.Load(36, &k.userCountersMap)
-	stateSourceObject.LoadValue(21, new([]tcpip.Endpoint), func(y any) { k.loadDanglingEndpoints(y.([]tcpip.Endpoint)) })
+	stateSourceObject.Load(7, &k.rtSignalQueueLimit)
+	stateSourceObject.Load(8, &k.extraAuxv)
+	stateSourceObject.Load(9, &k.vdso)
+	stateSourceObject.Load(10, &k.rootUTSNamespace)
+	stateSourceObject.Load(11, &k.rootIPCNamespace)
+	stateSourceObject.Load(12, &k.rootAbstractSocketNamespace)
+	stateSourceObject.Load(13, &k.futexes)
+	stateSourceObject.Load(14, &k.globalInit)
+	stateSourceObject.Load(15, &k.syslog)
+	stateSourceObject.Load(16, &k.runningTasks)
+	stateSourceObject.Load(17, &k.cpuClock)
+	stateSourceObject.Load(18, &k.cpuClockTickerRunning)
+	stateSourceObject.Load(19, &k.uniqueID)
+	stateSourceObject.Load(20, &k.nextInotifyCookie)
+	stateSourceObject.Load(21, &k.netlinkPorts)
+	stateSourceObject.Load(23, &k.sockets)
+	stateSourceObject.Load(24, &k.nextSocketRecord)
+	stateSourceObject.Load(25, &k.SpecialOpts)
+	stateSourceObject.Load(26, &k.vfs)
+	stateSourceObject.Load(27, &k.hostMount)
+	stateSourceObject.Load(28, &k.pipeMount)
+	stateSourceObject.Load(29, &k.nsfsMount)
+	stateSourceObject.Load(30, &k.shmMount)
+	stateSourceObject.Load(31, &k.socketMount)
+	stateSourceObject.Load(32, &k.sysVShmDevID)
+	stateSourceObject.Load(33, &k.SleepForAddressSpaceActivation)
+	stateSourceObject.Load(34, &k.ptraceExceptions)
+	stateSourceObject.Load(35, &k.YAMAPtraceScope)
+	stateSourceObject.Load(36, &k.cgroupRegistry)
+	stateSourceObject.Load(37, &k.userCountersMap)
+	stateSourceObject.LoadValue(22, new([]tcpip.Endpoint), func(y any) { k.loadDanglingEndpoints(y.([]tcpip.Endpoint)) })
+	stateSourceObject.AfterLoad(k.afterLoad)
 }
 
 func (s *SocketRecord) StateTypeName() string {
@@ -2175,6 +2177,7 @@ func (ns *PIDNamespace) StateFields() []string {
 		"sids",
 		"processGroups",
 		"pgids",
+		"foregroundPGIDs",
 		"exiting",
 		"extra",
 	}
@@ -2197,8 +2200,9 @@ func (ns *PIDNamespace) StateSave(stateSinkObject state.Sink) {
 	stateSinkObject.Save(9, &ns.sids)
 	stateSinkObject.Save(10, &ns.processGroups)
 	stateSinkObject.Save(11, &ns.pgids)
-	stateSinkObject.Save(12, &ns.exiting)
-	stateSinkObject.Save(13, &ns.extra)
+	stateSinkObject.Save(12, &ns.foregroundPGIDs)
+	stateSinkObject.Save(13, &ns.exiting)
+	stateSinkObject.Save(14, &ns.extra)
 }
 
 func (ns *PIDNamespace) afterLoad() {}
@@ -2217,8 +2221,9 @@ func (ns *PIDNamespace) StateLoad(stateSourceObject state.Source) {
 	stateSourceObject.Load(9, &ns.sids)
 	stateSourceObject.Load(10, &ns.processGroups)
 	stateSourceObject.Load(11, &ns.pgids)
-	stateSourceObject.Load(12, &ns.exiting)
-	stateSourceObject.Load(13, &ns.extra)
+	stateSourceObject.Load(12, &ns.foregroundPGIDs)
+	stateSourceObject.Load(13, &ns.exiting)
+	stateSourceObject.Load(14, &ns.extra)
 }
 
 func (t *threadGroupNode) StateTypeName() string {
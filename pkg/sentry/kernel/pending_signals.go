@@ -26,8 +26,9 @@ const (
 	// one instance is queued.") - signal(7)
 	stdSignalCap = 1
 
-	// rtSignalCap is the maximum number of instances of a given realtime
-	// signal that may be pending.
+	// rtSignalCap is the default maximum number of instances of a given
+	// realtime signal that may be pending. It can be changed with
+	// InitKernelArgs.RTSignalQueueLimit.
 	//
 	// TODO(igudger): In Linux, the minimum signal queue size is
 	// RLIMIT_SIGPENDING, which is by default max_threads/2.
@@ -71,17 +72,19 @@ type pendingSignal struct {
 }
 
 // enqueue enqueues the given signal. enqueue returns true on success and false
-// on failure (if the given signal's queue is full).
+// on failure (if the given signal's queue is full). rtCap is the maximum number
+// of instances of a realtime signal that may be pending; it doesn't apply to
+// standard signals, which are never queued more than once.
 //
 // Preconditions: info represents a valid signal.
-func (p *pendingSignals) enqueue(info *linux.SignalInfo, timer *IntervalTimer) bool {
+func (p *pendingSignals) enqueue(info *linux.SignalInfo, timer *IntervalTimer, rtCap int) bool {
 	sig := linux.Signal(info.Signo)
 	q := &p.signals[sig.Index()]
 	if sig.IsStandard() {
 		if q.length >= stdSignalCap {
 			return false
 		}
-	} else if q.length >= rtSignalCap {
+	} else if q.length >= rtCap {
 		return false
 	}
 	q.pendingSignalList.PushBack(&pendingSignal{SignalInfo: info, timer: timer})
@@ -90,6 +93,18 @@ func (p *pendingSignals) enqueue(info *linux.SignalInfo, timer *IntervalTimer) b
 	return true
 }
 
+// count returns the number of queued instances of the given signal.
+func (p *pendingSignals) count(sig linux.Signal) int {
+	return p.signals[sig.Index()].length
+}
+
+// unblocked returns the set of pending signals not masked by mask. It only
+// consults pendingSet, which is kept in sync with the signal queues, so it
+// doesn't need to walk any pendingSignalList.
+func (p *pendingSignals) unblocked(mask linux.SignalSet) linux.SignalSet {
+	return p.pendingSet &^ mask
+}
+
 // dequeue dequeues and returns any pending signal not masked by mask. If no
 // unmasked signals are pending, dequeue returns nil.
 func (p *pendingSignals) dequeue(mask linux.SignalSet) *linux.SignalInfo {
@@ -103,7 +118,7 @@ func (p *pendingSignals) dequeue(mask linux.SignalSet) *linux.SignalInfo {
 	// process, POSIX leaves it unspecified which is delivered first. Linux,
 	// like many other implementations, gives priority to standard signals in
 	// this case." - signal(7)
-	lowestPendingUnblockedBit := bits.TrailingZeros64(uint64(p.pendingSet &^ mask))
+	lowestPendingUnblockedBit := bits.TrailingZeros64(uint64(p.unblocked(mask)))
 	if lowestPendingUnblockedBit >= linux.SignalMaximum {
 		return nil
 	}
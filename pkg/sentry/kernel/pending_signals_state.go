@@ -14,7 +14,11 @@
 
 package kernel
 
-import "gvisor.dev/gvisor/pkg/abi/linux"
+import (
+	"math"
+
+	"gvisor.dev/gvisor/pkg/abi/linux"
+)
 
 // +stateify savable
 type savedPendingSignal struct {
@@ -39,6 +43,8 @@ func (p *pendingSignals) saveSignals() []savedPendingSignal {
 // loadSignals is invoked by stateify.
 func (p *pendingSignals) loadSignals(pending []savedPendingSignal) {
 	for _, sps := range pending {
-		p.enqueue(sps.si, sps.timer)
+		// These signals were already admitted when they were sent, so
+		// don't apply the realtime signal limit again.
+		p.enqueue(sps.si, sps.timer, math.MaxInt)
 	}
 }
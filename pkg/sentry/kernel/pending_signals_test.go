@@ -0,0 +1,120 @@
+// Copyright 2023 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kernel
+
+import (
+	"testing"
+
+	"gvisor.dev/gvisor/pkg/abi/linux"
+)
+
+func TestPendingSignalsRealtimeCap(t *testing.T) {
+	const rtCap = 4
+	var p pendingSignals
+	sig := linux.Signal(linux.FirstRTSignal)
+	for i := 0; i < rtCap; i++ {
+		if !p.enqueue(&linux.SignalInfo{Signo: int32(sig)}, nil, rtCap) {
+			t.Fatalf("enqueue #%d of %v failed, want success", i, sig)
+		}
+	}
+	if p.enqueue(&linux.SignalInfo{Signo: int32(sig)}, nil, rtCap) {
+		t.Errorf("enqueue of %v beyond the cap succeeded, want failure", sig)
+	}
+	if got := p.count(sig); got != rtCap {
+		t.Errorf("count(%v) = %d, want %d", sig, got, rtCap)
+	}
+
+	// Other realtime signals have their own queue.
+	other := sig + 1
+	if !p.enqueue(&linux.SignalInfo{Signo: int32(other)}, nil, rtCap) {
+		t.Errorf("enqueue of %v failed after %v reached the cap", other, sig)
+	}
+
+	// Dequeuing an instance makes room for another.
+	if info := p.dequeueSpecific(sig); info == nil {
+		t.Fatalf("dequeueSpecific(%v) = nil, want a signal", sig)
+	}
+	if !p.enqueue(&linux.SignalInfo{Signo: int32(sig)}, nil, rtCap) {
+		t.Errorf("enqueue of %v failed after dequeue", sig)
+	}
+}
+
+func TestPendingSignalsStandardIgnoresCap(t *testing.T) {
+	var p pendingSignals
+	// Standard signals coalesce regardless of the realtime cap.
+	for _, rtCap := range []int{0, 1, 32} {
+		if !p.enqueue(&linux.SignalInfo{Signo: int32(linux.SIGUSR1)}, nil, rtCap) {
+			t.Fatalf("enqueue of SIGUSR1 with rtCap=%d failed, want success", rtCap)
+		}
+		if p.enqueue(&linux.SignalInfo{Signo: int32(linux.SIGUSR1)}, nil, rtCap) {
+			t.Errorf("second enqueue of SIGUSR1 with rtCap=%d succeeded, want failure", rtCap)
+		}
+		p.dequeueSpecific(linux.SIGUSR1)
+	}
+}
+
+func TestPendingSignalsCount(t *testing.T) {
+	const rtCap = 32
+	var p pendingSignals
+	rt := linux.Signal(linux.FirstRTSignal)
+	for i := 0; i < 3; i++ {
+		p.enqueue(&linux.SignalInfo{Signo: int32(rt)}, nil, rtCap)
+		p.enqueue(&linux.SignalInfo{Signo: int32(linux.SIGUSR1)}, nil, rtCap)
+	}
+	// Standard signals coalesce into a single instance.
+	if got := p.count(linux.SIGUSR1); got != 1 {
+		t.Errorf("count(SIGUSR1) = %d, want 1", got)
+	}
+	if got := p.count(rt); got != 3 {
+		t.Errorf("count(%v) = %d, want 3", rt, got)
+	}
+	if got := p.count(linux.SIGUSR2); got != 0 {
+		t.Errorf("count(SIGUSR2) = %d, want 0", got)
+	}
+
+	p.dequeueSpecific(rt)
+	if got := p.count(rt); got != 2 {
+		t.Errorf("count(%v) = %d after dequeue, want 2", rt, got)
+	}
+	p.discardSpecific(rt)
+	if got := p.count(rt); got != 0 {
+		t.Errorf("count(%v) = %d after discard, want 0", rt, got)
+	}
+}
+
+func TestPendingSignalsUnblocked(t *testing.T) {
+	var p pendingSignals
+	if got := p.unblocked(0); got != 0 {
+		t.Errorf("unblocked(0) = %#x with no pending signals, want 0", got)
+	}
+	rt := linux.Signal(linux.FirstRTSignal)
+	for _, sig := range []linux.Signal{linux.SIGUSR1, linux.SIGUSR2, rt} {
+		p.enqueue(&linux.SignalInfo{Signo: int32(sig)}, nil, 1)
+	}
+	all := linux.MakeSignalSet(linux.SIGUSR1, linux.SIGUSR2, rt)
+	if got := p.unblocked(0); got != all {
+		t.Errorf("unblocked(0) = %#x, want %#x", got, all)
+	}
+	mask := linux.MakeSignalSet(linux.SIGUSR1, linux.SIGHUP)
+	if got, want := p.unblocked(mask), linux.MakeSignalSet(linux.SIGUSR2, rt); got != want {
+		t.Errorf("unblocked(%#x) = %#x, want %#x", mask, got, want)
+	}
+
+	// Dequeued signals are no longer reported.
+	p.dequeueSpecific(rt)
+	if got, want := p.unblocked(mask), linux.SignalSetOf(linux.SIGUSR2); got != want {
+		t.Errorf("unblocked(%#x) = %#x after dequeue, want %#x", mask, got, want)
+	}
+}
@@ -17,10 +17,12 @@ package semaphore
 
 import (
 	"fmt"
+	"time"
 
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/context"
 	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/metric"
 	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
 	"gvisor.dev/gvisor/pkg/sentry/kernel/ipc"
 	ktime "gvisor.dev/gvisor/pkg/sentry/kernel/time"
@@ -40,6 +42,18 @@ const (
 
 	// Maximum number of semaphores in all semaphore sets.
 	semsTotalMax = linux.SEMMNS
+
+	// OpsMax is the maximum number of operations in a single semop(2) call.
+	OpsMax = linux.SEMOPM
+)
+
+// Metrics for semaphore contention. The per-set counts are available from
+// Set.ContentionStats.
+var (
+	Blocks          = metric.MustCreateNewUint64Metric("/semaphore/blocks", false /* sync */, "Number of semaphore operations that blocked.")
+	Wakeups         = metric.MustCreateNewUint64Metric("/semaphore/wakeups", false /* sync */, "Number of semaphore waiters woken by a change in semaphore value.")
+	SpuriousWakeups = metric.MustCreateNewUint64Metric("/semaphore/spurious_wakeups", false /* sync */, "Number of woken semaphore waiters that had to block again.")
+	BlockWait       = metric.MustCreateNewUint64NanosecondsMetric("/semaphore/block_wait", false /* sync */, "Time blocked on semaphore operations, in nanoseconds.")
 )
 
 // Registry maintains a set of semaphores that can be found by key or ID.
@@ -79,6 +93,29 @@ type Set struct {
 	// dead is set to true when the set is removed and can't be reached anymore.
 	// All waiters must wake up and fail when set is dead.
 	dead bool
+
+	// blocks, wakeups, spuriousWakeups and blockWait count contention on this
+	// set, like the Blocks, Wakeups, SpuriousWakeups and BlockWait metrics do
+	// for all sets.
+	blocks          uint64
+	wakeups         uint64
+	spuriousWakeups uint64
+	blockWait       time.Duration
+}
+
+// ContentionStats counts contention on a semaphore set.
+type ContentionStats struct {
+	// Blocks is the number of operations that blocked.
+	Blocks uint64
+
+	// Wakeups is the number of waiters woken by a change in semaphore value.
+	Wakeups uint64
+
+	// SpuriousWakeups is the number of woken waiters that had to block again.
+	SpuriousWakeups uint64
+
+	// BlockWait is the total time spent blocked on operations.
+	BlockWait time.Duration
 }
 
 // sem represents a single semaphore from a set.
@@ -101,6 +138,19 @@ type waiter struct {
 	// The value is either 0 or negative.
 	value int16
 	ch    chan struct{}
+
+	// ops is the full set of operations that the waiter is trying to
+	// execute. A waiter is only woken if all of them can be applied.
+	ops []linux.Sembuf
+
+	// pid is the PID of the process that is waiting.
+	pid int32
+
+	// interrupted is set when the waiter has been woken by WakeWaiterByPID
+	// rather than by a change in the semaphore's value. An interrupted waiter
+	// remains in the waiter list until the waiting process observes the
+	// interruption in Interrupted.
+	interrupted bool
 }
 
 // NewRegistry creates a new semaphore set registry.
@@ -160,18 +210,20 @@ func (r *Registry) FindOrCreate(ctx context.Context, key ipc.Key, nsems int32, m
 	return r.newSetLocked(ctx, key, auth.CredentialsFromContext(ctx), mode, nsems)
 }
 
-// IPCInfo returns information about system-wide semaphore limits and parameters.
+// IPCInfo returns information about system-wide semaphore limits and
+// parameters. The limits reported are the ones enforced by FindOrCreate and
+// semop(2).
 func (r *Registry) IPCInfo() *linux.SemInfo {
 	return &linux.SemInfo{
 		SemMap: linux.SEMMAP,
-		SemMni: linux.SEMMNI,
-		SemMns: linux.SEMMNS,
+		SemMni: setsMax,
+		SemMns: semsTotalMax,
 		SemMnu: linux.SEMMNU,
-		SemMsl: linux.SEMMSL,
-		SemOpm: linux.SEMOPM,
+		SemMsl: semsMax,
+		SemOpm: OpsMax,
 		SemUme: linux.SEMUME,
 		SemUsz: linux.SEMUSZ,
-		SemVmx: linux.SEMVMX,
+		SemVmx: valueMax,
 		SemAem: linux.SEMAEM,
 	}
 }
@@ -224,6 +276,43 @@ func (r *Registry) Remove(id ipc.ID, creds *auth.Credentials) error {
 	return nil
 }
 
+// Release destroys all semaphore sets in the registry, waking up any waiters.
+// It is called when the kernel.IPCNamespace containing r is being destroyed.
+func (r *Registry) Release() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.reg.ForAllObjects(
+		func(o ipc.Mechanism) {
+			set := o.(*Set)
+			set.mu.Lock()
+			set.Destroy()
+			set.mu.Unlock()
+			r.reg.DissociateKey(set.obj.Key)
+			r.reg.DissociateID(set.obj.ID)
+		},
+	)
+	r.indexes = make(map[int32]ipc.ID)
+}
+
+// WakeWaiterByPID wakes up a waiter blocked on behalf of the process with the
+// given pid on any set in the registry. Returns true if such a waiter was
+// found.
+func (r *Registry) WakeWaiterByPID(pid int32) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	found := false
+	r.reg.ForAllObjects(
+		func(o ipc.Mechanism) {
+			if !found {
+				found = o.(*Set).WakeWaiterByPID(pid)
+			}
+		},
+	)
+	return found
+}
+
 // newSetLocked creates a new Set using given fields. An error is returned if there
 // are no more available identifiers.
 //
@@ -407,7 +496,7 @@ func (s *Set) SetVal(ctx context.Context, num int32, val int16, creds *auth.Cred
 	sem.value = val
 	sem.pid = pid
 	s.changeTime = ktime.NowFromContext(ctx)
-	sem.wakeWaiters()
+	s.wakeWaiters()
 	return nil
 }
 
@@ -440,8 +529,8 @@ func (s *Set) SetValAll(ctx context.Context, vals []uint16, creds *auth.Credenti
 		// TODO(gvisor.dev/issue/137): Clear undo entries in all processes.
 		sem.value = int16(val)
 		sem.pid = pid
-		sem.wakeWaiters()
 	}
+	s.wakeWaiters()
 	s.changeTime = ktime.NowFromContext(ctx)
 	return nil
 }
@@ -590,8 +679,10 @@ func (s *Set) executeOps(ctx context.Context, ops []linux.Sembuf, pid int32) (ch
 					return nil, 0, linuxerr.ErrWouldBlock
 				}
 
-				w := newWaiter(op.SemOp)
+				w := newWaiter(op.SemOp, ops, pid)
 				sem.waiters.PushBack(w)
+				s.blocks++
+				Blocks.Increment()
 				return w.ch, int32(op.SemNum), nil
 			}
 		} else {
@@ -606,8 +697,10 @@ func (s *Set) executeOps(ctx context.Context, ops []linux.Sembuf, pid int32) (ch
 						return nil, 0, linuxerr.ErrWouldBlock
 					}
 
-					w := newWaiter(op.SemOp)
+					w := newWaiter(op.SemOp, ops, pid)
 					sem.waiters.PushBack(w)
+					s.blocks++
+					Blocks.Increment()
 					return w.ch, int32(op.SemNum), nil
 				}
 			} else {
@@ -625,13 +718,42 @@ func (s *Set) executeOps(ctx context.Context, ops []linux.Sembuf, pid int32) (ch
 	// TODO(gvisor.dev/issue/137): handle undo operations.
 	for i, v := range tmpVals {
 		s.sems[i].value = v
-		s.sems[i].wakeWaiters()
 		s.sems[i].pid = pid
 	}
+	s.wakeWaiters()
 	s.opTime = ktime.NowFromContext(ctx)
 	return nil, 0, nil
 }
 
+// RecordSpuriousWakeup records that a waiter woken by a change in semaphore
+// value had to block again.
+func (s *Set) RecordSpuriousWakeup() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.spuriousWakeups++
+	SpuriousWakeups.Increment()
+}
+
+// RecordBlockWait records that a waiter spent d blocked on the set.
+func (s *Set) RecordBlockWait(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blockWait += d
+	BlockWait.IncrementBy(uint64(d.Nanoseconds()))
+}
+
+// ContentionStats returns the contention counts of the set.
+func (s *Set) ContentionStats() ContentionStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return ContentionStats{
+		Blocks:          s.blocks,
+		Wakeups:         s.wakeups,
+		SpuriousWakeups: s.spuriousWakeups,
+		BlockWait:       s.blockWait,
+	}
+}
+
 // AbortWait notifies that a waiter is giving up and will not wait on the
 // channel anymore.
 func (s *Set) AbortWait(num int32, ch chan struct{}) {
@@ -648,6 +770,45 @@ func (s *Set) AbortWait(num int32, ch chan struct{}) {
 	// Waiter may not be found in case it raced with wakeWaiters().
 }
 
+// WakeWaiterByPID wakes up a waiter blocked on behalf of the process with the
+// given pid. The woken process will observe the wakeup in Interrupted and fail
+// with EINTR. Returns true if such a waiter was found.
+func (s *Set) WakeWaiterByPID(pid int32) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.sems {
+		for w := s.sems[i].waiters.Front(); w != nil; w = w.Next() {
+			if w.pid == pid && !w.interrupted {
+				w.interrupted = true
+				w.ch <- struct{}{}
+				return true
+			}
+		}
+	}
+	// Waiter may not be found in case it raced with wakeWaiters().
+	return false
+}
+
+// Interrupted checks whether the waiter that was waiting on ch was woken by
+// WakeWaiterByPID. If so, the waiter is removed and true is returned.
+func (s *Set) Interrupted(num int32, ch chan struct{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sem := &s.sems[num]
+	for w := sem.waiters.Front(); w != nil; w = w.Next() {
+		if w.ch == ch {
+			if !w.interrupted {
+				return false
+			}
+			sem.waiters.Remove(w)
+			return true
+		}
+	}
+	return false
+}
+
 // Destroy implements ipc.Mechanism.Destroy.
 //
 // Preconditions: Caller must hold 's.mu'.
@@ -657,38 +818,101 @@ func (s *Set) Destroy() {
 	s.dead = true
 	for _, s := range s.sems {
 		for w := s.waiters.Front(); w != nil; w = w.Next() {
+			if w.interrupted {
+				// Already notified.
+				continue
+			}
 			w.ch <- struct{}{}
 		}
 		s.waiters.Reset()
 	}
 }
 
-func abs(val int16) int16 {
-	if val < 0 {
-		return -val
+// wakeWaiters goes over all waiters and checks which of them can be notified.
+//
+// A waiter is only notified if its full set of operations can be applied to
+// the semaphore values left after applying the operations of the waiters
+// notified before it. This avoids waking up waiters that would lose the race
+// for the resource to another waiter, only to block again. Woken waiters still
+// re-execute their operations, so this doesn't affect correctness.
+//
+// Preconditions: Caller must hold 's.mu'.
+func (s *Set) wakeWaiters() {
+	if !s.hasWaitersLocked() {
+		return
+	}
+	vals := make([]int16, len(s.sems))
+	for i := range s.sems {
+		vals[i] = s.sems[i].value
+	}
+	for i := range s.sems {
+		sem := &s.sems[i]
+		for w := sem.waiters.Front(); w != nil; {
+			next := w.Next()
+			if !w.interrupted && simulateOps(vals, w.ops) {
+				w.ch <- struct{}{}
+				s.wakeups++
+				Wakeups.Increment()
+				sem.waiters.Remove(w)
+			}
+			w = next
+		}
+	}
+}
+
+// hasWaitersLocked returns true if any semaphore in the set has waiters.
+//
+// Preconditions: Caller must hold 's.mu'.
+func (s *Set) hasWaitersLocked() bool {
+	for i := range s.sems {
+		if !s.sems[i].waiters.Empty() {
+			return true
+		}
 	}
-	return val
+	return false
 }
 
-// wakeWaiters goes over all waiters and checks which of them can be notified.
-func (s *sem) wakeWaiters() {
-	// Note that this will release all waiters waiting for 0 too.
-	for w := s.waiters.Front(); w != nil; {
-		if s.value < abs(w.value) {
-			// Still blocked, skip it.
-			w = w.Next()
-			continue
+// simulateOps returns true if ops can be applied to vals without blocking, in
+// which case vals is updated to reflect their application. Otherwise, vals is
+// left unchanged.
+func simulateOps(vals []int16, ops []linux.Sembuf) bool {
+	for i, op := range ops {
+		switch {
+		case op.SemOp == 0:
+			if vals[op.SemNum] != 0 {
+				undoOps(vals, ops[:i])
+				return false
+			}
+		case op.SemOp < 0:
+			if -op.SemOp > vals[op.SemNum] {
+				undoOps(vals, ops[:i])
+				return false
+			}
+		default:
+			if vals[op.SemNum] > valueMax-op.SemOp {
+				// The operations will fail with ERANGE, which the waiter
+				// must be woken up to observe.
+				undoOps(vals, ops[:i])
+				return true
+			}
 		}
-		w.ch <- struct{}{}
-		old := w
-		w = w.Next()
-		s.waiters.Remove(old)
+		vals[op.SemNum] += op.SemOp
+	}
+	return true
+}
+
+// undoOps reverts the application of ops to vals by simulateOps.
+func undoOps(vals []int16, ops []linux.Sembuf) {
+	for _, op := range ops {
+		vals[op.SemNum] -= op.SemOp
 	}
 }
 
-func newWaiter(val int16) *waiter {
+func newWaiter(val int16, ops []linux.Sembuf, pid int32) *waiter {
 	return &waiter{
 		value: val,
 		ch:    make(chan struct{}, 1),
+		ops:   ops,
+		pid:   pid,
 	}
 }
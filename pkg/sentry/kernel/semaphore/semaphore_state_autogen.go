@@ -46,6 +46,10 @@ func (s *Set) StateFields() []string {
 		"changeTime",
 		"sems",
 		"dead",
+		"blocks",
+		"wakeups",
+		"spuriousWakeups",
+		"blockWait",
 	}
 }
 
@@ -60,6 +64,10 @@ func (s *Set) StateSave(stateSinkObject state.Sink) {
 	stateSinkObject.Save(3, &s.changeTime)
 	stateSinkObject.Save(4, &s.sems)
 	stateSinkObject.Save(5, &s.dead)
+	stateSinkObject.Save(6, &s.blocks)
+	stateSinkObject.Save(7, &s.wakeups)
+	stateSinkObject.Save(8, &s.spuriousWakeups)
+	stateSinkObject.Save(9, &s.blockWait)
 }
 
 func (s *Set) afterLoad() {}
@@ -72,6 +80,10 @@ func (s *Set) StateLoad(stateSourceObject state.Source) {
 	stateSourceObject.Load(3, &s.changeTime)
 	stateSourceObject.Load(4, &s.sems)
 	stateSourceObject.Load(5, &s.dead)
+	stateSourceObject.Load(6, &s.blocks)
+	stateSourceObject.Load(7, &s.wakeups)
+	stateSourceObject.Load(8, &s.spuriousWakeups)
+	stateSourceObject.Load(9, &s.blockWait)
 }
 
 func (s *sem) StateTypeName() string {
@@ -114,6 +126,9 @@ func (w *waiter) StateFields() []string {
 		"waiterEntry",
 		"value",
 		"ch",
+		"ops",
+		"pid",
+		"interrupted",
 	}
 }
 
@@ -125,6 +140,9 @@ func (w *waiter) StateSave(stateSinkObject state.Sink) {
 	stateSinkObject.Save(0, &w.waiterEntry)
 	stateSinkObject.Save(1, &w.value)
 	stateSinkObject.Save(2, &w.ch)
+	stateSinkObject.Save(3, &w.ops)
+	stateSinkObject.Save(4, &w.pid)
+	stateSinkObject.Save(5, &w.interrupted)
 }
 
 func (w *waiter) afterLoad() {}
@@ -134,6 +152,9 @@ func (w *waiter) StateLoad(stateSourceObject state.Source) {
 	stateSourceObject.Load(0, &w.waiterEntry)
 	stateSourceObject.Load(1, &w.value)
 	stateSourceObject.Load(2, &w.ch)
+	stateSourceObject.Load(3, &w.ops)
+	stateSourceObject.Load(4, &w.pid)
+	stateSourceObject.Load(5, &w.interrupted)
 }
 
 func (l *waiterList) StateTypeName() string {
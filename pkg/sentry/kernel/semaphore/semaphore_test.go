@@ -0,0 +1,414 @@
+// Copyright 2023 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semaphore
+
+import (
+	"testing"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/ipc"
+	ktime "gvisor.dev/gvisor/pkg/sentry/kernel/time"
+)
+
+// testClock is a realtime clock that always reads zero.
+type testClock struct {
+	ktime.WallRateClock
+	ktime.NoClockEvents
+}
+
+// Now implements ktime.Clock.Now.
+func (*testClock) Now() ktime.Time {
+	return ktime.ZeroTime
+}
+
+// testContext provides the credentials and realtime clock used by semaphore
+// operations.
+type testContext struct {
+	context.Context
+	creds *auth.Credentials
+}
+
+// Value implements context.Context.Value.
+func (ctx *testContext) Value(key any) any {
+	switch key {
+	case auth.CtxCredentials:
+		return ctx.creds
+	case ktime.CtxRealtimeClock:
+		return &testClock{}
+	default:
+		return ctx.Context.Value(key)
+	}
+}
+
+func newTestContext() *testContext {
+	return &testContext{
+		Context: context.Background(),
+		creds:   auth.NewRootCredentials(auth.NewRootUserNamespace()),
+	}
+}
+
+func newTestSet(t *testing.T, ctx *testContext, r *Registry, key ipc.Key, nsems int32) *Set {
+	t.Helper()
+	set, err := r.FindOrCreate(ctx, key, nsems, linux.FileMode(0600), false /* private */, true /* create */, true /* exclusive */)
+	if err != nil {
+		t.Fatalf("FindOrCreate(%d, %d): %v", key, nsems, err)
+	}
+	return set
+}
+
+// block executes a decrement of semaphore num on behalf of pid, which must
+// block.
+func block(t *testing.T, ctx *testContext, set *Set, num uint16, pid int32) chan struct{} {
+	t.Helper()
+	ops := []linux.Sembuf{{SemNum: num, SemOp: -1}}
+	ch, _, err := set.ExecuteOps(ctx, ops, ctx.creds, pid)
+	if err != nil {
+		t.Fatalf("ExecuteOps(%+v, %d): %v", ops, pid, err)
+	}
+	if ch == nil {
+		t.Fatalf("ExecuteOps(%+v, %d) didn't block", ops, pid)
+	}
+	return ch
+}
+
+func signaled(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestWakeWaiterByPID(t *testing.T) {
+	ctx := newTestContext()
+	r := NewRegistry(auth.NewRootUserNamespace())
+	set1 := newTestSet(t, ctx, r, 1, 2)
+	set2 := newTestSet(t, ctx, r, 2, 1)
+
+	chs := map[int32]chan struct{}{
+		10: block(t, ctx, set1, 0, 10),
+		11: block(t, ctx, set1, 1, 11),
+		12: block(t, ctx, set2, 0, 12),
+	}
+
+	if !r.WakeWaiterByPID(11) {
+		t.Fatalf("WakeWaiterByPID(11) = false, want true")
+	}
+	for pid, ch := range chs {
+		if got, want := signaled(ch), pid == 11; got != want {
+			t.Errorf("waiter of PID %d woken = %t, want %t", pid, got, want)
+		}
+	}
+	if !set1.Interrupted(1, chs[11]) {
+		t.Errorf("Interrupted(1, _) = false for the woken waiter, want true")
+	}
+	if set1.Interrupted(0, chs[10]) {
+		t.Errorf("Interrupted(0, _) = true for a waiter that wasn't woken, want false")
+	}
+
+	// The interrupted waiter is gone, so it can't be woken again.
+	if r.WakeWaiterByPID(11) {
+		t.Errorf("WakeWaiterByPID(11) = true after the waiter left, want false")
+	}
+	if r.WakeWaiterByPID(13) {
+		t.Errorf("WakeWaiterByPID(13) = true for a PID without waiters, want false")
+	}
+
+	// Waiters in other sets are found too.
+	if !r.WakeWaiterByPID(12) {
+		t.Errorf("WakeWaiterByPID(12) = false, want true")
+	}
+	if !signaled(chs[12]) {
+		t.Errorf("waiter of PID 12 wasn't woken")
+	}
+}
+
+func TestWakeWaiterByPIDRacesWithWakeup(t *testing.T) {
+	ctx := newTestContext()
+	r := NewRegistry(auth.NewRootUserNamespace())
+	set := newTestSet(t, ctx, r, 1, 1)
+	ch := block(t, ctx, set, 0, 10)
+
+	// A natural wakeup removes the waiter before it can be interrupted.
+	ops := []linux.Sembuf{{SemNum: 0, SemOp: 1}}
+	if _, _, err := set.ExecuteOps(ctx, ops, ctx.creds, 20); err != nil {
+		t.Fatalf("ExecuteOps(%+v, 20): %v", ops, err)
+	}
+	if r.WakeWaiterByPID(10) {
+		t.Errorf("WakeWaiterByPID(10) = true after a natural wakeup, want false")
+	}
+	if !signaled(ch) {
+		t.Fatalf("waiter of PID 10 wasn't woken")
+	}
+	if set.Interrupted(0, ch) {
+		t.Errorf("Interrupted(0, _) = true after a natural wakeup, want false")
+	}
+}
+
+func TestCompetingWaitersWakeOnce(t *testing.T) {
+	ctx := newTestContext()
+	r := NewRegistry(auth.NewRootUserNamespace())
+	set := newTestSet(t, ctx, r, 1, 1)
+	ch1 := block(t, ctx, set, 0, 10)
+	ch2 := block(t, ctx, set, 0, 11)
+
+	// A single increment can only satisfy one of the decrements.
+	ops := []linux.Sembuf{{SemNum: 0, SemOp: 1}}
+	if _, _, err := set.ExecuteOps(ctx, ops, ctx.creds, 20); err != nil {
+		t.Fatalf("ExecuteOps(%+v, 20): %v", ops, err)
+	}
+	if !signaled(ch1) {
+		t.Errorf("first waiter wasn't woken")
+	}
+	if signaled(ch2) {
+		t.Errorf("second waiter was woken, want it to keep waiting")
+	}
+}
+
+func TestSimulateOps(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		ops      []linux.Sembuf
+		want     bool
+		wantVals []int16
+	}{
+		{
+			name:     "all succeed",
+			ops:      []linux.Sembuf{{SemNum: 0, SemOp: -1}, {SemNum: 1, SemOp: 2}},
+			want:     true,
+			wantVals: []int16{0, 2},
+		},
+		{
+			name:     "later op blocks",
+			ops:      []linux.Sembuf{{SemNum: 0, SemOp: -1}, {SemNum: 1, SemOp: -1}},
+			want:     false,
+			wantVals: []int16{1, 0},
+		},
+		{
+			name:     "wait for zero blocks after increment",
+			ops:      []linux.Sembuf{{SemNum: 1, SemOp: 1}, {SemNum: 1, SemOp: 0}},
+			want:     false,
+			wantVals: []int16{1, 0},
+		},
+		{
+			name:     "out of range",
+			ops:      []linux.Sembuf{{SemNum: 0, SemOp: -1}, {SemNum: 1, SemOp: valueMax}, {SemNum: 1, SemOp: 1}},
+			want:     true,
+			wantVals: []int16{1, 0},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			vals := []int16{1, 0}
+			if got := simulateOps(vals, tc.ops); got != tc.want {
+				t.Errorf("simulateOps(_, %+v) = %t, want %t", tc.ops, got, tc.want)
+			}
+			for i := range vals {
+				if vals[i] != tc.wantVals[i] {
+					t.Errorf("got vals %v, want %v", vals, tc.wantVals)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestWakeWaitersNoAllocs(t *testing.T) {
+	ctx := newTestContext()
+	r := NewRegistry(auth.NewRootUserNamespace())
+	set := newTestSet(t, ctx, r, 1, 4)
+
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	if allocs := testing.AllocsPerRun(100, set.wakeWaiters); allocs != 0 {
+		t.Errorf("wakeWaiters without waiters allocated %v times, want 0", allocs)
+	}
+	vals := []int16{1, 1}
+	ops := []linux.Sembuf{{SemNum: 0, SemOp: -1}, {SemNum: 1, SemOp: -2}}
+	if allocs := testing.AllocsPerRun(100, func() { simulateOps(vals, ops) }); allocs != 0 {
+		t.Errorf("simulateOps allocated %v times, want 0", allocs)
+	}
+}
+
+func TestContentionMetrics(t *testing.T) {
+	ctx := newTestContext()
+	r := NewRegistry(auth.NewRootUserNamespace())
+	set := newTestSet(t, ctx, r, 1, 1)
+
+	blocks, wakeups := Blocks.Value(), Wakeups.Value()
+	ch := block(t, ctx, set, 0, 10)
+	if got, want := Blocks.Value(), blocks+1; got != want {
+		t.Errorf("got %d blocks, want %d", got, want)
+	}
+	ops := []linux.Sembuf{{SemNum: 0, SemOp: 1}}
+	if _, _, err := set.ExecuteOps(ctx, ops, ctx.creds, 20); err != nil {
+		t.Fatalf("ExecuteOps(%+v, 20): %v", ops, err)
+	}
+	if !signaled(ch) {
+		t.Fatalf("waiter wasn't woken")
+	}
+	if got, want := Wakeups.Value(), wakeups+1; got != want {
+		t.Errorf("got %d wakeups, want %d", got, want)
+	}
+}
+
+func TestSetContentionStats(t *testing.T) {
+	ctx := newTestContext()
+	r := NewRegistry(auth.NewRootUserNamespace())
+	set := newTestSet(t, ctx, r, 1, 1)
+	other := newTestSet(t, ctx, r, 2, 1)
+
+	ch := block(t, ctx, set, 0, 10)
+	ops := []linux.Sembuf{{SemNum: 0, SemOp: 1}}
+	if _, _, err := set.ExecuteOps(ctx, ops, ctx.creds, 20); err != nil {
+		t.Fatalf("ExecuteOps(%+v, 20): %v", ops, err)
+	}
+	if !signaled(ch) {
+		t.Fatalf("waiter wasn't woken")
+	}
+	spurious, blockWait := SpuriousWakeups.Value(), BlockWait.Value()
+	set.RecordSpuriousWakeup()
+	const wait = 5 * time.Millisecond
+	set.RecordBlockWait(wait)
+	set.RecordBlockWait(wait)
+
+	want := ContentionStats{
+		Blocks:          1,
+		Wakeups:         1,
+		SpuriousWakeups: 1,
+		BlockWait:       2 * wait,
+	}
+	if got := set.ContentionStats(); got != want {
+		t.Errorf("got set.ContentionStats() = %+v, want %+v", got, want)
+	}
+	if got, want := SpuriousWakeups.Value(), spurious+1; got != want {
+		t.Errorf("got %d spurious wakeups, want %d", got, want)
+	}
+	if got, want := BlockWait.Value(), blockWait+uint64((2*wait).Nanoseconds()); got != want {
+		t.Errorf("got block wait %d, want %d", got, want)
+	}
+
+	// Contention on one set isn't counted against another.
+	if got := other.ContentionStats(); got != (ContentionStats{}) {
+		t.Errorf("got other.ContentionStats() = %+v, want zero", got)
+	}
+}
+
+func TestRegistriesIsolated(t *testing.T) {
+	ctx := newTestContext()
+	r1 := NewRegistry(auth.NewRootUserNamespace())
+	r2 := NewRegistry(auth.NewRootUserNamespace())
+
+	// Sets with the same key in different registries are distinct.
+	const key = 1
+	set1 := newTestSet(t, ctx, r1, key, 1)
+	set2 := newTestSet(t, ctx, r2, key, 1)
+	if set1 == set2 {
+		t.Fatalf("registries returned the same set for key %d", key)
+	}
+	if err := set2.SetVal(ctx, 0, 5, ctx.creds, 10); err != nil {
+		t.Fatalf("SetVal(0, 5): %v", err)
+	}
+	if got, err := set1.GetVal(0, ctx.creds); err != nil || got != 0 {
+		t.Errorf("GetVal(0) = %d, %v in the other registry, want 0, nil", got, err)
+	}
+	if err := set2.SetVal(ctx, 0, 0, ctx.creds, 10); err != nil {
+		t.Fatalf("SetVal(0, 0): %v", err)
+	}
+
+	// Tearing down one registry wakes its waiters and leaves the other's
+	// waiting.
+	ch1 := block(t, ctx, set1, 0, 10)
+	ch2 := block(t, ctx, set2, 0, 11)
+	r1.Release()
+	if !signaled(ch1) {
+		t.Errorf("waiter in the released registry wasn't woken")
+	}
+	if signaled(ch2) {
+		t.Errorf("waiter in the other registry was woken")
+	}
+	if got := r1.FindByID(set1.obj.ID); got != nil {
+		t.Errorf("FindByID(%d) found a set in the released registry", set1.obj.ID)
+	}
+	if got := r2.FindByID(set2.obj.ID); got != set2 {
+		t.Errorf("FindByID(%d) = %p in the other registry, want %p", set2.obj.ID, got, set2)
+	}
+	ops := []linux.Sembuf{{SemNum: 0, SemOp: -1}}
+	if _, _, err := set1.ExecuteOps(ctx, ops, ctx.creds, 10); err != linuxerr.EIDRM {
+		t.Errorf("ExecuteOps(%+v) on a released set = %v, want %v", ops, err, linuxerr.EIDRM)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	ctx := newTestContext()
+	r := NewRegistry(auth.NewRootUserNamespace())
+
+	// The reported limits are the enforced ones.
+	info := r.IPCInfo()
+	if info.SemMsl != semsMax || info.SemMni != setsMax || info.SemMns != semsTotalMax || info.SemOpm != OpsMax || info.SemVmx != valueMax {
+		t.Errorf("got limits {SemMsl: %d, SemMni: %d, SemMns: %d, SemOpm: %d, SemVmx: %d}, want {%d, %d, %d, %d, %d}",
+			info.SemMsl, info.SemMni, info.SemMns, info.SemOpm, info.SemVmx, semsMax, setsMax, semsTotalMax, OpsMax, valueMax)
+	}
+
+	if _, err := r.FindOrCreate(ctx, 1, int32(info.SemMsl)+1, linux.FileMode(0600), false /* private */, true /* create */, true /* exclusive */); err != linuxerr.EINVAL {
+		t.Errorf("FindOrCreate with %d semaphores = %v, want %v", info.SemMsl+1, err, linuxerr.EINVAL)
+	}
+	set := newTestSet(t, ctx, r, 1, int32(info.SemMsl))
+	if got := set.Size(); got != int(info.SemMsl) {
+		t.Errorf("got set of %d semaphores, want %d", got, info.SemMsl)
+	}
+
+	// Values are limited to SemVmx.
+	if err := set.SetVal(ctx, 0, int16(info.SemVmx), ctx.creds, 10); err != nil {
+		t.Errorf("SetVal(0, %d): %v", info.SemVmx, err)
+	}
+	ops := []linux.Sembuf{{SemNum: 0, SemOp: 1}}
+	if _, _, err := set.ExecuteOps(ctx, ops, ctx.creds, 10); err != linuxerr.ERANGE {
+		t.Errorf("ExecuteOps(%+v) beyond SemVmx = %v, want %v", ops, err, linuxerr.ERANGE)
+	}
+}
+
+func TestStatByIndex(t *testing.T) {
+	ctx := newTestContext()
+	userns := auth.NewRootUserNamespace()
+	r := NewRegistry(userns)
+	set := newTestSet(t, ctx, r, 1, 1)
+
+	// Sets are found by their index in the registry, as with SEM_STAT and
+	// SEM_STAT_ANY.
+	if got := r.FindByIndex(0); got != set {
+		t.Fatalf("FindByIndex(0) = %p, want %p", got, set)
+	}
+	if got := r.FindByIndex(1); got != nil {
+		t.Errorf("FindByIndex(1) = %p, want nil", got)
+	}
+
+	// Only SEM_STAT_ANY works without read permission.
+	creds := auth.NewUserCredentials(1000, 1000, nil, &auth.TaskCapabilities{}, userns)
+	if _, err := set.GetStat(creds); err != linuxerr.EACCES {
+		t.Errorf("GetStat() without read permission = %v, want %v", err, linuxerr.EACCES)
+	}
+	ds, err := set.GetStatAny(creds)
+	if err != nil {
+		t.Fatalf("GetStatAny() without read permission: %v", err)
+	}
+	if ds.SemPerm.Key != 1 || ds.SemNSems != 1 {
+		t.Errorf("got {Key: %d, SemNSems: %d}, want {Key: 1, SemNSems: 1}", ds.SemPerm.Key, ds.SemNSems)
+	}
+}
@@ -64,6 +64,9 @@ type Session struct {
 // Precondition: callers must hold TaskSet.mu for writing.
 func (s *Session) DecRef() {
 	s.SessionRefs.DecRef(func() {
+		// Release the IDs of an empty foreground process group.
+		s.setForegroundLocked(nil)
+
 		// Remove translations from the leader.
 		for ns := s.leader.pidns; ns != nil; ns = ns.parent {
 			id := ns.sids[s]
@@ -76,6 +79,52 @@ func (s *Session) DecRef() {
 	})
 }
 
+// setForegroundLocked sets the foreground process group of s to pg. If the
+// previous foreground process group has no remaining members, its IDs are
+// released for reuse.
+//
+// Precondition: callers must hold TaskSet.mu for writing.
+func (s *Session) setForegroundLocked(pg *ProcessGroup) {
+	old := s.foreground
+	s.foreground = pg
+	if old == nil || old == pg {
+		return
+	}
+	for ns := old.originator.pidns; ns != nil; ns = ns.parent {
+		id, ok := ns.pgids[old]
+		if !ok || ns.foregroundPGIDs[id] != old {
+			continue
+		}
+		delete(ns.pgids, old)
+		delete(ns.foregroundPGIDs, id)
+	}
+}
+
+// processGroupLocked returns the process group in s with the given ID, or nil
+// if no such process group exists. IDs are those of the PID namespace in which
+// each process group was created.
+//
+// Precondition: callers must hold TaskSet.mu.
+func (s *Session) processGroupLocked(id ProcessGroupID) *ProcessGroup {
+	for pg := s.processGroups.Front(); pg != nil; pg = pg.Next() {
+		if pg.id == id {
+			return pg
+		}
+	}
+	return nil
+}
+
+// ProcessGroupWithID returns the process group in s with the given ID, or nil
+// if no such process group exists.
+//
+// A reference is not taken on the process group.
+func (s *Session) ProcessGroupWithID(id ProcessGroupID) *ProcessGroup {
+	ts := s.leader.pidns.owner
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	return s.processGroupLocked(id)
+}
+
 // ProcessGroup contains an originator threadgroup and a parent Session.
 //
 // +stateify savable
@@ -159,11 +208,18 @@ func (pg *ProcessGroup) decRefWithParent(parentPG *ProcessGroup) {
 	pg.refs.DecRef(func() {
 		alive = false // don't bother with handleOrphan.
 
-		// Remove translations from the originator.
+		// Remove translations from the originator. If pg is still the
+		// foreground process group, keep its IDs reserved until it is
+		// replaced; see Session.setForegroundLocked.
+		foreground := pg.session.foreground == pg
 		for ns := pg.originator.pidns; ns != nil; ns = ns.parent {
 			id := ns.pgids[pg]
-			delete(ns.pgids, pg)
 			delete(ns.processGroups, id)
+			if foreground {
+				ns.foregroundPGIDs[id] = pg
+			} else {
+				delete(ns.pgids, pg)
+			}
 		}
 
 		// Remove the list of process groups.
@@ -283,10 +339,8 @@ func (tg *ThreadGroup) createSession() error {
 		if s.id == SessionID(id) {
 			return linuxerr.EPERM
 		}
-		for pg := s.processGroups.Front(); pg != nil; pg = pg.Next() {
-			if pg.id == ProcessGroupID(id) {
-				return linuxerr.EPERM
-			}
+		if s.processGroupLocked(ProcessGroupID(id)) != nil {
+			return linuxerr.EPERM
 		}
 	}
 
@@ -383,10 +437,8 @@ func (tg *ThreadGroup) CreateProcessGroup() error {
 		if s.leader == tg {
 			return linuxerr.EPERM
 		}
-		for pg := s.processGroups.Front(); pg != nil; pg = pg.Next() {
-			if pg.id == ProcessGroupID(id) {
-				return linuxerr.EPERM
-			}
+		if s.processGroupLocked(ProcessGroupID(id)) != nil {
+			return linuxerr.EPERM
 		}
 	}
 
@@ -0,0 +1,143 @@
+// Copyright 2023 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kernel
+
+import (
+	"testing"
+
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+)
+
+// newTestProcessGroup adds a process group to s with the given IDs in the
+// child and root PID namespaces.
+func newTestProcessGroup(s *Session, child, root *PIDNamespace, childID, rootID ProcessGroupID) *ProcessGroup {
+	pg := &ProcessGroup{
+		id:         childID,
+		originator: s.leader,
+		session:    s,
+		ancestors:  1,
+	}
+	pg.refs.InitRefs()
+	s.processGroups.PushBack(pg)
+	child.pgids[pg] = childID
+	child.processGroups[childID] = pg
+	root.pgids[pg] = rootID
+	root.processGroups[rootID] = pg
+	return pg
+}
+
+func TestEmptyForegroundProcessGroupIDReserved(t *testing.T) {
+	userns := auth.NewRootUserNamespace()
+	root := NewRootPIDNamespace(userns)
+	ts := newTaskSet(root)
+	child := root.NewChild(userns)
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	leader := &ThreadGroup{}
+	leader.pidns = child
+	s := &Session{leader: leader}
+	s.InitRefs()
+	ts.sessions.PushBack(s)
+	child.sids[s] = 2
+	child.sessions[2] = s
+	root.sids[s] = 102
+	root.sessions[102] = s
+	// Each process group holds a reference on the session.
+	pg1 := newTestProcessGroup(s, child, root, 5, 105)
+	s.IncRef()
+	pg2 := newTestProcessGroup(s, child, root, 6, 106)
+
+	nextTID := func(ns *PIDNamespace, last ThreadID) ThreadID {
+		t.Helper()
+		ns.last = last
+		tid, err := ns.allocateTID()
+		if err != nil {
+			t.Fatalf("allocateTID(): %v", err)
+		}
+		return tid
+	}
+
+	// The ID of an empty foreground process group isn't reused in any
+	// namespace it is visible in.
+	s.setForegroundLocked(pg1)
+	pg1.decRefWithParent(nil)
+	if _, ok := child.processGroups[5]; ok {
+		t.Errorf("empty process group is still in the namespace")
+	}
+	if got, want := nextTID(child, 4), ThreadID(7); got != want {
+		t.Errorf("got TID %d in the child namespace, want %d", got, want)
+	}
+	if got, want := nextTID(root, 104), ThreadID(107); got != want {
+		t.Errorf("got TID %d in the root namespace, want %d", got, want)
+	}
+	if got, want := child.pgids[pg1], ProcessGroupID(5); got != want {
+		t.Errorf("got foreground process group ID %d, want %d", got, want)
+	}
+
+	// Replacing the foreground process group releases the IDs.
+	s.setForegroundLocked(pg2)
+	if got, want := nextTID(child, 4), ThreadID(5); got != want {
+		t.Errorf("got TID %d in the child namespace, want %d", got, want)
+	}
+	if got, want := nextTID(root, 104), ThreadID(105); got != want {
+		t.Errorf("got TID %d in the root namespace, want %d", got, want)
+	}
+	if _, ok := root.pgids[pg1]; ok {
+		t.Errorf("replaced foreground process group is still in the namespace")
+	}
+
+	// So does the end of the session.
+	pg2.decRefWithParent(nil)
+	for _, ns := range []*PIDNamespace{child, root} {
+		if len(ns.foregroundPGIDs) != 0 || len(ns.pgids) != 0 || len(ns.sessions) != 0 {
+			t.Errorf("got foregroundPGIDs %v, pgids %v, sessions %v after the session ended, want none", ns.foregroundPGIDs, ns.pgids, ns.sessions)
+		}
+	}
+}
+
+func TestSessionProcessGroupWithID(t *testing.T) {
+	userns := auth.NewRootUserNamespace()
+	root := NewRootPIDNamespace(userns)
+	newTaskSet(root)
+	child := root.NewChild(userns)
+
+	leader := &ThreadGroup{}
+	leader.pidns = child
+	s := &Session{leader: leader}
+	s.InitRefs()
+	other := &Session{leader: leader}
+	other.InitRefs()
+	pg1 := newTestProcessGroup(s, child, root, 5, 105)
+	pg2 := newTestProcessGroup(s, child, root, 6, 106)
+	newTestProcessGroup(other, child, root, 7, 107)
+
+	for _, tc := range []struct {
+		id   ProcessGroupID
+		want *ProcessGroup
+	}{
+		{id: 5, want: pg1},
+		{id: 6, want: pg2},
+		// Process groups are found by the ID in the namespace they were
+		// created in.
+		{id: 105},
+		// Process groups in other sessions aren't found.
+		{id: 7},
+	} {
+		if got := s.ProcessGroupWithID(tc.id); got != tc.want {
+			t.Errorf("s.ProcessGroupWithID(%d) = %p, want %p", tc.id, got, tc.want)
+		}
+	}
+}
@@ -95,7 +95,7 @@ func (t *Task) killLocked() {
 		// enqueueing an actual siginfo, such that
 		// kernel/signal.c:collect_signal() initializes si_code to SI_USER.
 		Code: linux.SI_USER,
-	}, nil)
+	}, nil, t.k.rtSignalQueueLimit)
 	t.interrupt()
 }
 
@@ -451,7 +451,7 @@ func (t *Task) sendSignalTimerLocked(info *linux.SignalInfo, group bool, timer *
 	if group {
 		q = &t.tg.pendingSignals
 	}
-	if !q.enqueue(info, timer) {
+	if !q.enqueue(info, timer, t.k.rtSignalQueueLimit) {
 		if sig.IsRealtime() {
 			return linuxerr.EAGAIN
 		}
@@ -549,7 +549,9 @@ func (t *Task) canReceiveSignalLocked(sig linux.Signal) bool {
 // receive the given signal. If no such task exists, findSignalReceiverLocked
 // returns nil.
 //
-// Linux actually records curr_target to balance the group signal targets.
+// tg.tasks is ordered by TID, so the eligible task with the lowest TID is
+// chosen and the receiver is deterministic. Linux instead records curr_target
+// to balance the group signal targets.
 //
 // Preconditions: The signal mutex must be locked.
 func (tg *ThreadGroup) findSignalReceiverLocked(sig linux.Signal) *Task {
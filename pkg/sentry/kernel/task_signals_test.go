@@ -0,0 +1,115 @@
+// Copyright 2023 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kernel
+
+import (
+	"testing"
+
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+)
+
+// newTestThreadGroup returns an empty thread group in a new root PID
+// namespace.
+func newTestThreadGroup() *ThreadGroup {
+	tg := &ThreadGroup{}
+	tg.pidns = NewRootPIDNamespace(auth.NewRootUserNamespace())
+	return tg
+}
+
+// addTestTask adds a task with the given TID to tg.
+func addTestTask(tg *ThreadGroup, tid ThreadID) *Task {
+	t := &Task{interruptChan: make(chan struct{}, 1)}
+	t.tg = tg
+	tg.pidns.tids[t] = tid
+	tg.pidns.tasks[tid] = t
+	tg.insertTaskLocked(t)
+	return t
+}
+
+func TestFindSignalReceiverLowestTID(t *testing.T) {
+	tg := newTestThreadGroup()
+	tasks := make(map[ThreadID]*Task)
+	for _, tid := range []ThreadID{5, 3, 7, 4} {
+		tasks[tid] = addTestTask(tg, tid)
+	}
+
+	var got []ThreadID
+	for task := tg.tasks.Front(); task != nil; task = task.Next() {
+		got = append(got, tg.pidns.tids[task])
+	}
+	want := []ThreadID{3, 4, 5, 7}
+	if len(got) != len(want) {
+		t.Fatalf("got tasks with TIDs %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("got tasks with TIDs %v, want %v", got, want)
+		}
+	}
+
+	const sig = linux.SIGUSR1
+	if got := tg.findSignalReceiverLocked(sig); got != tasks[3] {
+		t.Errorf("got receiver with TID %d, want 3", tg.pidns.tids[got])
+	}
+	// Tasks that block the signal or have already been interrupted are
+	// skipped.
+	tasks[3].signalMask.Store(uint64(linux.SignalSetOf(sig)))
+	if got := tg.findSignalReceiverLocked(sig); got != tasks[4] {
+		t.Errorf("got receiver with TID %d, want 4", tg.pidns.tids[got])
+	}
+	tasks[4].interruptChan <- struct{}{}
+	if got := tg.findSignalReceiverLocked(sig); got != tasks[5] {
+		t.Errorf("got receiver with TID %d, want 5", tg.pidns.tids[got])
+	}
+	for _, task := range []*Task{tasks[5], tasks[7]} {
+		task.signalMask.Store(uint64(linux.SignalSetOf(sig)))
+	}
+	if got := tg.findSignalReceiverLocked(sig); got != nil {
+		t.Errorf("got receiver with TID %d, want none", tg.pidns.tids[got])
+	}
+}
+
+func TestSIGCONTDiscardsStopSignals(t *testing.T) {
+	tg := newTestThreadGroup()
+	task := addTestTask(tg, 1)
+	enqueue := func(p *pendingSignals, sig linux.Signal) {
+		t.Helper()
+		if !p.enqueue(&linux.SignalInfo{Signo: int32(sig)}, nil, 0) {
+			t.Fatalf("enqueue of %v failed", sig)
+		}
+	}
+	enqueue(&tg.pendingSignals, linux.SIGSTOP)
+	enqueue(&tg.pendingSignals, linux.SIGUSR1)
+	enqueue(&task.pendingSignals, linux.SIGTSTP)
+	enqueue(&task.pendingSignals, linux.SIGTTIN)
+
+	// SIGCONT ends any group stop, and endGroupStopLocked discards pending
+	// stop signals.
+	tg.applySignalSideEffectsLocked(linux.SIGCONT)
+	if got, want := tg.pendingSignals.pendingSet, linux.SignalSetOf(linux.SIGUSR1); got != want {
+		t.Errorf("got thread group pending signals %#x, want %#x", got, want)
+	}
+	if got := task.pendingSignals.pendingSet; got != 0 {
+		t.Errorf("got task pending signals %#x, want none", got)
+	}
+
+	// Conversely, stop signals discard pending SIGCONT.
+	enqueue(&task.pendingSignals, linux.SIGCONT)
+	tg.applySignalSideEffectsLocked(linux.SIGTTOU)
+	if got := task.pendingSignals.pendingSet; got != 0 {
+		t.Errorf("got task pending signals %#x, want none", got)
+	}
+}
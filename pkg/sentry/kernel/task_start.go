@@ -266,7 +266,7 @@ func (ts *TaskSet) newTask(ctx context.Context, cfg *TaskConfig) (*Task, error)
 			tg.hasChildSubreaper = t.parent.tg.isChildSubreaper || t.parent.tg.hasChildSubreaper
 		}
 	}
-	tg.tasks.PushBack(t)
+	tg.insertTaskLocked(t)
 	tg.tasksCount++
 	tg.liveTasks++
 	tg.activeTasks++
@@ -288,6 +288,26 @@ func (ts *TaskSet) newTask(ctx context.Context, cfg *TaskConfig) (*Task, error)
 	return t, nil
 }
 
+// insertTaskLocked adds t to tg.tasks, keeping tg.tasks ordered by TID so
+// that group signal receivers are chosen deterministically; see
+// ThreadGroup.findSignalReceiverLocked. TIDs are usually allocated in
+// increasing order, so t is usually inserted at the back.
+//
+// Preconditions:
+//   - The TaskSet mutex must be locked for writing.
+//   - The signal mutex must be locked.
+//   - t must have been assigned TIDs.
+func (tg *ThreadGroup) insertTaskLocked(t *Task) {
+	tid := tg.pidns.tids[t]
+	for prev := tg.tasks.Back(); prev != nil; prev = prev.Prev() {
+		if tg.pidns.tids[prev] < tid {
+			tg.tasks.InsertAfter(prev, t)
+			return
+		}
+	}
+	tg.tasks.PushFront(t)
+}
+
 // assignTIDsLocked ensures that new task t is visible in all PID namespaces in
 // which it should be visible.
 //
@@ -351,7 +371,11 @@ func (ns *PIDNamespace) allocateTID() (ThreadID, error) {
 			if _, ok := ns.sessions[SessionID(tid)]; ok {
 				return true
 			}
-			return false
+			// An empty foreground process group's ID is still in use;
+			// otherwise signals sent to the foreground process group
+			// would reach an unrelated process.
+			_, ok := ns.foregroundPGIDs[ProcessGroupID(tid)]
+			return ok
 		}()
 
 		if !tidInUse {
@@ -416,7 +416,7 @@ func (tg *ThreadGroup) SetControllingTTY(tty *TTY, steal bool, isReadable bool)
 
 	// Set the controlling terminal and foreground process group.
 	tg.tty = tty
-	tg.processGroup.session.foreground = tg.processGroup
+	tg.processGroup.session.setForegroundLocked(tg.processGroup)
 	// Set this as the controlling process of the terminal.
 	tty.tg = tg
 
@@ -543,7 +543,7 @@ func (tg *ThreadGroup) SetForegroundProcessGroupID(tty *TTY, pgid ProcessGroupID
 		return linuxerr.ERESTARTSYS
 	}
 
-	tg.processGroup.session.foreground = pg
+	tg.processGroup.session.setForegroundLocked(pg)
 	return nil
 }
 
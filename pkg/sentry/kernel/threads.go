@@ -188,6 +188,13 @@ type PIDNamespace struct {
 	// their identifiers in this namespace.
 	pgids map[*ProcessGroup]ProcessGroupID
 
+	// foregroundPGIDs is a mapping from ProcessGroupIDs in this namespace to
+	// process groups that have no remaining members but are still the
+	// foreground process group of their session. Such process groups remain
+	// in pgids, and their IDs must not be reused until they are replaced as
+	// the foreground process group.
+	foregroundPGIDs map[ProcessGroupID]*ProcessGroup
+
 	// exiting indicates that the namespace's init process is exiting or has
 	// exited.
 	exiting bool
@@ -198,18 +205,19 @@ type PIDNamespace struct {
 
 func newPIDNamespace(ts *TaskSet, parent *PIDNamespace, userns *auth.UserNamespace) *PIDNamespace {
 	return &PIDNamespace{
-		owner:         ts,
-		parent:        parent,
-		userns:        userns,
-		id:            lastPIDNSID.Add(1),
-		tasks:         make(map[ThreadID]*Task),
-		tids:          make(map[*Task]ThreadID),
-		tgids:         make(map[*ThreadGroup]ThreadID),
-		sessions:      make(map[SessionID]*Session),
-		sids:          make(map[*Session]SessionID),
-		processGroups: make(map[ProcessGroupID]*ProcessGroup),
-		pgids:         make(map[*ProcessGroup]ProcessGroupID),
-		extra:         newPIDNamespaceData(),
+		owner:           ts,
+		parent:          parent,
+		userns:          userns,
+		id:              lastPIDNSID.Add(1),
+		tasks:           make(map[ThreadID]*Task),
+		tids:            make(map[*Task]ThreadID),
+		tgids:           make(map[*ThreadGroup]ThreadID),
+		sessions:        make(map[SessionID]*Session),
+		sids:            make(map[*Session]SessionID),
+		processGroups:   make(map[ProcessGroupID]*ProcessGroup),
+		pgids:           make(map[*ProcessGroup]ProcessGroupID),
+		foregroundPGIDs: make(map[ProcessGroupID]*ProcessGroup),
+		extra:           newPIDNamespaceData(),
 	}
 }
 
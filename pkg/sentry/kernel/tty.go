@@ -76,3 +76,52 @@ func (tty *TTY) SignalForegroundProcessGroup(info *linux.SignalInfo) {
 		log.Warningf("failed to signal foreground process group (pgid=%d): %v", fg.id, err)
 	}
 }
+
+// Hangup hangs up the terminal, e.g. because the master end of a
+// pseudoterminal was closed. SIGHUP and SIGCONT are sent to the foreground
+// process group of the session controlled by the terminal, and every process
+// in the session loses the terminal as its controlling terminal.
+func (tty *TTY) Hangup() error {
+	tty.mu.Lock()
+	tg := tty.tg
+	if tg == nil {
+		// The terminal doesn't control a session. There is nothing to
+		// signal or detach.
+		tty.mu.Unlock()
+		return nil
+	}
+
+	tg.pidns.owner.mu.RLock()
+	session := tg.processGroup.session
+	fg := session.foreground
+	for othertg := range tg.pidns.owner.Root.tgids {
+		if othertg.processGroup == nil || othertg.processGroup.session != session {
+			continue
+		}
+		othertg.signalHandlers.mu.Lock()
+		if othertg.tty == tty {
+			othertg.tty = nil
+		}
+		othertg.signalHandlers.mu.Unlock()
+	}
+	tty.tg = nil
+	tg.pidns.owner.mu.RUnlock()
+	tty.mu.Unlock()
+
+	if fg == nil {
+		// Nothing to signal.
+		return nil
+	}
+
+	// SendSignal will take TaskSet.mu and signalHandlers.mu, so we cannot
+	// hold them here. Hangup is called when the master end is released, so
+	// don't hold tty.mu either.
+	var lastErr error
+	if err := fg.SendSignal(SignalInfoPriv(linux.SIGHUP)); err != nil {
+		lastErr = err
+	}
+	if err := fg.SendSignal(SignalInfoPriv(linux.SIGCONT)); err != nil {
+		lastErr = err
+	}
+	return lastErr
+}
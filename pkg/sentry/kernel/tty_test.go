@@ -0,0 +1,67 @@
+// Copyright 2023 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kernel
+
+import (
+	"testing"
+
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+)
+
+func TestHangupDetachesSession(t *testing.T) {
+	root := NewRootPIDNamespace(auth.NewRootUserNamespace())
+	newTaskSet(root)
+
+	newTG := func(s *Session) *ThreadGroup {
+		tg := &ThreadGroup{signalHandlers: &SignalHandlers{}}
+		tg.pidns = root
+		tg.processGroup = &ProcessGroup{originator: tg, session: s}
+		root.tgids[tg] = ThreadID(len(root.tgids) + 1)
+		return tg
+	}
+	s := &Session{}
+	leader := newTG(s)
+	s.leader = leader
+	member := newTG(s)
+	other := newTG(&Session{})
+
+	tty := &TTY{Index: 0}
+	otherTTY := &TTY{Index: 1}
+	tty.tg = leader
+	leader.tty = tty
+	member.tty = tty
+	otherTTY.tg = other
+	other.tty = otherTTY
+
+	if err := tty.Hangup(); err != nil {
+		t.Fatalf("Hangup(): %v", err)
+	}
+	for _, tg := range []*ThreadGroup{leader, member} {
+		if got := tg.TTY(); got != nil {
+			t.Errorf("got controlling terminal %d after hangup, want none", got.Index)
+		}
+	}
+	if got := other.TTY(); got != otherTTY {
+		t.Errorf("hangup detached another session's terminal")
+	}
+	if tty.tg != nil {
+		t.Errorf("terminal still controls a session after hangup")
+	}
+
+	// Hanging up again is a no-op.
+	if err := tty.Hangup(); err != nil {
+		t.Errorf("second Hangup(): %v", err)
+	}
+}
@@ -24,6 +24,7 @@ import (
 
 	"golang.org/x/sys/unix"
 	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/metric"
 	"gvisor.dev/gvisor/pkg/sentry/platform"
 	"gvisor.dev/gvisor/pkg/sentry/platform/systrap/sysmsg"
 	"gvisor.dev/gvisor/pkg/syncevent"
@@ -57,8 +58,11 @@ type sharedContext struct {
 
 	// sync is used by the context go-routine to wait for events from the
 	// dispatcher.
-	sync           syncevent.Waiter
-	startWaitingTS int64
+	sync syncevent.Waiter
+	// startWaitingTS is the time stamp, in CPU ticks, when the context
+	// started waiting for a stub thread. It is atomic so that it can be
+	// read by appendContexts.
+	startWaitingTS atomic.Int64
 	kicked         bool
 	// The task associated with the context fell asleep.
 	sleeping bool
@@ -90,7 +94,7 @@ func (s *subprocess) getSharedContext() (*sharedContext, error) {
 		return nil, fmt.Errorf("subprocess has too many active tasks (%d); failed to create a new one", maxGuestContexts)
 	}
 	s.IncRef()
-	sc := sharedContext{
+	sc := &sharedContext{
 		subprocess: s,
 		contextID:  uint32(id),
 		shared:     s.getThreadContextFromID(id),
@@ -98,8 +102,9 @@ func (s *subprocess) getSharedContext() (*sharedContext, error) {
 	sc.shared.Init(invalidThreadID)
 	sc.sync.Init()
 	sc.sleeping = true
+	s.sharedContexts[id].Store(sc)
 
-	return &sc, nil
+	return sc, nil
 }
 
 func (sc *sharedContext) release() {
@@ -110,6 +115,7 @@ func (sc *sharedContext) release() {
 		sc.subprocess.decAwakeContexts()
 
 	}
+	sc.subprocess.sharedContexts[sc.contextID].Store(nil)
 	sc.subprocess.threadContextPool.Put(uint64(sc.contextID))
 	sc.subprocess.DecRef(sc.subprocess.release)
 }
@@ -190,6 +196,38 @@ func (sc *sharedContext) resetAcked() {
 	atomic.StoreUint32(&sc.shared.Acked, ackReset)
 }
 
+// appendContexts appends a description of every context of s to b and
+// returns the extended buffer. Each context is described by its state, the
+// sysmsg thread working on it and, if it is waiting for a stub thread, for how
+// many CPU ticks.
+//
+// appendContexts takes no locks and only reads context state atomically, and
+// it doesn't allocate if b has enough capacity. So it can be called from a
+// signal handler or while the platform is wedged.
+func (s *subprocess) appendContexts(b []byte) []byte {
+	now := cputicks()
+	for i := range s.sharedContexts {
+		sc := s.sharedContexts[i].Load()
+		if sc == nil {
+			continue
+		}
+		state := sc.state()
+		b = append(b, "context "...)
+		b = strconv.AppendUint(b, uint64(sc.contextID), 10)
+		b = append(b, ": state "...)
+		b = strconv.AppendUint(b, uint64(state), 10)
+		b = append(b, " thread "...)
+		b = strconv.AppendUint(b, uint64(sc.threadID()), 10)
+		if state == sysmsg.ContextStateNone {
+			b = append(b, " waiting "...)
+			b = strconv.AppendInt(b, now-sc.startWaitingTS.Load(), 10)
+			b = append(b, " ticks"...)
+		}
+		b = append(b, '\n')
+	}
+	return b
+}
+
 const (
 	contextPreemptTimeoutNsec = 10 * 1000 * 1000 // 10ms
 	contextCheckupTimeoutSec  = 5
@@ -213,6 +251,7 @@ func (sc *sharedContext) sleepOnState(state sysmsg.ContextState) {
 		}
 		if time.Now().After(deadline) {
 			log.Warningf("Systrap task goroutine has been waiting on ThreadContext.State futex too long. ThreadContext: %v", sc)
+			log.Warningf("Contexts of the subprocess:\n%s", sc.subprocess.appendContexts(nil))
 		}
 		if sentInterruptOnce {
 			log.Warningf("The context is still running: %v", sc)
@@ -247,10 +286,30 @@ type fastPathDispatcher struct {
 	// fastPathDisabledTS is the time stamp when the stub fast path was
 	// disabled. It is zero if the fast path is enabled.
 	fastPathDisabledTS atomic.Uint64
+
+	// fastPathOff is set when the stub fast path is switched off by
+	// SetStubFastPathEnabled.
+	fastPathOff atomic.Bool
 }
 
 var dispatcher fastPathDispatcher
 
+func init() {
+	metric.MustRegisterCustomUint64Metric("/systrap/dispatcher_queue_depth",
+		false /* cumulative */, false /* sync */, "The number of contexts waiting in the systrap dispatcher queue.",
+		func(...*metric.FieldValue) uint64 {
+			return uint64(dispatcher.queueDepth())
+		})
+}
+
+// queueDepth returns the number of contexts waiting to be dispatched. A
+// persistently deep queue indicates that stub threads are CPU-starved.
+func (q *fastPathDispatcher) queueDepth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.nr
+}
+
 // fastPathContextLimit is the maximum number of contexts after which the fast
 // path in stub threads is disabled. Its value can be higher than the number of
 // CPU-s, because the Sentry is running with higher priority than stub threads,
@@ -271,6 +330,9 @@ var nrMaxAwakeStubThreads atomic.Uint32
 // enabled. If the fast path is disabled, it revises whether it has to be
 // re-enabled or not.
 func (q *fastPathDispatcher) stubFastPathEnabled() bool {
+	if q.fastPathOff.Load() {
+		return false
+	}
 	ts := q.fastPathDisabledTS.Load()
 	if ts != 0 {
 		if uint64(cputicks())-ts < fastPathDisabledTimeout {
@@ -285,6 +347,15 @@ func (q *fastPathDispatcher) stubFastPathEnabled() bool {
 	return true
 }
 
+// SetStubFastPathEnabled switches the fast path in stub processes on or off.
+// It is on by default, in which case it is still disabled temporarily when
+// there are more awake contexts than fastPathContextLimit. The change applies
+// to contexts dispatched afterwards, so both modes can be compared without
+// restarting the sandbox.
+func SetStubFastPathEnabled(enabled bool) {
+	dispatcher.fastPathOff.Store(!enabled)
+}
+
 // disableStubFastPath disables the fast path over all subprocesses with active
 // contexts.
 func (q *fastPathDispatcher) disableStubFastPath() {
@@ -338,7 +409,7 @@ func (q *fastPathDispatcher) loop(target *sharedContext) {
 			if ctx.state() == sysmsg.ContextStateNone {
 				if slowPath {
 					event = sharedContextSlowPath
-				} else if !ctx.kicked && uint64(now-ctx.startWaitingTS) > handshakeTimeout {
+				} else if !ctx.kicked && uint64(now-ctx.startWaitingTS.Load()) > handshakeTimeout {
 					if ctx.isAcked() {
 						ctx.kicked = true
 						continue
@@ -367,16 +438,21 @@ func (q *fastPathDispatcher) loop(target *sharedContext) {
 	}
 }
 
-func (q *fastPathDispatcher) waitFor(ctx *sharedContext) syncevent.Set {
-	events := syncevent.Set(0)
-
+// enqueue adds ctx to the queue. It returns true if ctx is the only context in
+// the queue, in which case the caller has to run the loop.
+func (q *fastPathDispatcher) enqueue(ctx *sharedContext) bool {
 	q.mu.Lock()
+	defer q.mu.Unlock()
 	q.entrants.PushBack(ctx)
 	q.nr++
-	if q.nr == 1 {
+	return q.nr == 1
+}
+
+func (q *fastPathDispatcher) waitFor(ctx *sharedContext) syncevent.Set {
+	events := syncevent.Set(0)
+	if q.enqueue(ctx) {
 		events = sharedContextDispatch
 	}
-	q.mu.Unlock()
 
 	for {
 		if events&sharedContextDispatch != 0 {
@@ -0,0 +1,117 @@
+// Copyright 2023 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package systrap
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"gvisor.dev/gvisor/pkg/sentry/platform/systrap/sysmsg"
+)
+
+func TestAppendContexts(t *testing.T) {
+	s := &subprocess{
+		sharedContexts: make([]atomic.Pointer[sharedContext], maxGuestContexts),
+	}
+	add := func(id uint32, state sysmsg.ContextState, tid uint32) *sharedContext {
+		sc := &sharedContext{
+			subprocess: s,
+			contextID:  id,
+			shared:     &sysmsg.ThreadContext{},
+		}
+		sc.shared.State.Set(state)
+		sc.shared.ThreadID = tid
+		s.sharedContexts[id].Store(sc)
+		return sc
+	}
+	waiting := add(1, sysmsg.ContextStateNone, 0)
+	waiting.startWaitingTS.Store(cputicks())
+	add(3, sysmsg.ContextStateSyscall, 7)
+
+	got := string(s.appendContexts(nil))
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got dump %q, want 2 lines", got)
+	}
+	if want := regexp.MustCompile(`^context 1: state 0 thread 0 waiting [0-9]+ ticks$`); !want.MatchString(lines[0]) {
+		t.Errorf("got line %q, want match for %q", lines[0], want)
+	}
+	if want := "context 3: state " + stateString(sysmsg.ContextStateSyscall) + " thread 7"; lines[1] != want {
+		t.Errorf("got line %q, want %q", lines[1], want)
+	}
+
+	// Released contexts are left out, and appending doesn't allocate once
+	// the buffer is large enough.
+	s.sharedContexts[1].Store(nil)
+	buf := make([]byte, 0, 256)
+	if allocs := testing.AllocsPerRun(10, func() { buf = s.appendContexts(buf[:0]) }); allocs != 0 {
+		t.Errorf("appendContexts allocated %v times, want 0", allocs)
+	}
+	if got, want := string(buf), "context 3: state "+stateString(sysmsg.ContextStateSyscall)+" thread 7\n"; got != want {
+		t.Errorf("got dump %q, want %q", got, want)
+	}
+}
+
+func stateString(state sysmsg.ContextState) string {
+	return strconv.FormatUint(uint64(state), 10)
+}
+
+func TestDispatcherQueueDepth(t *testing.T) {
+	var q fastPathDispatcher
+	if got := q.queueDepth(); got != 0 {
+		t.Fatalf("got queue depth %d for an empty queue, want 0", got)
+	}
+
+	ctxs := make([]*sharedContext, 3)
+	for i := range ctxs {
+		ctxs[i] = &sharedContext{shared: &sysmsg.ThreadContext{}}
+		ctxs[i].sync.Init()
+		// The context is ready to be handed back to its task goroutine.
+		ctxs[i].setState(sysmsg.ContextStateSyscall)
+		if first := q.enqueue(ctxs[i]); first != (i == 0) {
+			t.Errorf("enqueue of context %d returned %t, want %t", i, first, i == 0)
+		}
+		if got, want := q.queueDepth(), i+1; got != want {
+			t.Errorf("got queue depth %d after enqueuing %d contexts, want %d", got, i+1, want)
+		}
+	}
+
+	// Dispatching the contexts drains the queue.
+	q.loop(ctxs[len(ctxs)-1])
+	if got := q.queueDepth(); got != 0 {
+		t.Errorf("got queue depth %d after dispatching every context, want 0", got)
+	}
+	for i, ctx := range ctxs {
+		if events := ctx.sync.Pending(); events&sharedContextReady == 0 {
+			t.Errorf("context %d wasn't notified that it is ready, got events %#x", i, events)
+		}
+	}
+}
+
+func TestSetStubFastPathEnabled(t *testing.T) {
+	defer SetStubFastPathEnabled(true)
+
+	SetStubFastPathEnabled(false)
+	if dispatcher.stubFastPathEnabled() {
+		t.Errorf("stub fast path is enabled after switching it off")
+	}
+	SetStubFastPathEnabled(true)
+	if !dispatcher.stubFastPathEnabled() {
+		t.Errorf("stub fast path is disabled after switching it back on")
+	}
+}
@@ -135,6 +135,11 @@ type subprocess struct {
 	// context.lastFaultSP == this subprocess.
 	faultedContexts map[*context]struct{}
 
+	// sharedContexts maps the ID of each ThreadContext in this subprocess to
+	// the context that holds it, if any. Its elements are accessed
+	// atomically so that contexts can be dumped without taking mu.
+	sharedContexts []atomic.Pointer[sharedContext]
+
 	// sysmsgStackPool is a pool of available sysmsg stacks.
 	sysmsgStackPool pool.Pool
 
@@ -277,6 +282,7 @@ func newSubprocess(create func() (*thread, error), memoryFile *pgalloc.MemoryFil
 	sp := &subprocess{
 		requests:          requests,
 		faultedContexts:   make(map[*context]struct{}),
+		sharedContexts:    make([]atomic.Pointer[sharedContext], maxGuestContexts),
 		sysmsgStackPool:   pool.Pool{Start: 0, Limit: maxSystemThreads},
 		threadContextPool: pool.Pool{Start: 0, Limit: maxGuestContexts},
 		memoryFile:        memoryFile,
@@ -780,7 +786,7 @@ func (s *subprocess) waitOnState(ctx *sharedContext, stubFastPathEnabled bool) {
 	ctx.kicked = false
 	slowPath := false
 	start := cputicks()
-	ctx.startWaitingTS = start
+	ctx.startWaitingTS.Store(start)
 	if !stubFastPathEnabled || atomic.LoadUint32(&s.contextQueue.numActiveThreads) == 0 {
 		ctx.kicked = s.kickSysmsgThread()
 	}
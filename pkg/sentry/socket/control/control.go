@@ -207,6 +207,27 @@ func PackTimestamp(t *kernel.Task, timestamp time.Time, buf []byte) []byte {
 	)
 }
 
+// PackTimestamping packs an SCM_TIMESTAMPING socket control message holding a
+// software timestamp. The remaining timestamps, which hold hardware
+// timestamps, are zero since they are unavailable.
+func PackTimestamping(t *kernel.Task, timestamp time.Time, buf []byte) []byte {
+	return packTimestamping(timestamp, t.Arch().Width(), buf)
+}
+
+func packTimestamping(timestamp time.Time, align uint, buf []byte) []byte {
+	var data [linux.SizeOfControlMessageTimestamping]byte
+	ts := linux.NsecToTimespec(timestamp.UnixNano())
+	ts.MarshalBytes(data[:])
+	timestampingP := primitive.ByteSlice(data[:])
+	return putCmsgStruct(
+		buf,
+		linux.SOL_SOCKET,
+		linux.SCM_TIMESTAMPING,
+		align,
+		&timestampingP,
+	)
+}
+
 // PackInq packs a TCP_INQ socket control message.
 func PackInq(t *kernel.Task, inq int32, buf []byte) []byte {
 	return putCmsgStruct(
@@ -251,6 +272,17 @@ func PackTTL(t *kernel.Task, ttl uint32, buf []byte) []byte {
 	)
 }
 
+// PackDropCount packs an SO_RXQ_OVFL socket control message.
+func PackDropCount(t *kernel.Task, dropCount uint32, buf []byte) []byte {
+	return putCmsgStruct(
+		buf,
+		linux.SOL_SOCKET,
+		linux.SO_RXQ_OVFL,
+		t.Arch().Width(),
+		primitive.AllocateUint32(dropCount),
+	)
+}
+
 // PackHopLimit packs an IPV6_HOPLIMIT socket control message.
 func PackHopLimit(t *kernel.Task, hoplimit uint32, buf []byte) []byte {
 	return putCmsgStruct(
@@ -324,6 +356,16 @@ func PackControlMessages(t *kernel.Task, cmsgs socket.ControlMessages, buf []byt
 		buf = PackTimestamp(t, cmsgs.IP.Timestamp, buf)
 	}
 
+	if cmsgs.IP.HasTimestamping {
+		// In Linux, SCM_TIMESTAMPING is added after SO_TIMESTAMP.
+		buf = PackTimestamping(t, cmsgs.IP.Timestamp, buf)
+	}
+
+	if cmsgs.IP.HasDropCount {
+		// In Linux, SO_RXQ_OVFL is added after SO_TIMESTAMP.
+		buf = PackDropCount(t, cmsgs.IP.DropCount, buf)
+	}
+
 	if cmsgs.IP.HasInq {
 		// In Linux, TCP_CM_INQ is added after SO_TIMESTAMP.
 		buf = PackInq(t, cmsgs.IP.Inq, buf)
@@ -378,6 +420,14 @@ func CmsgsSpace(t *kernel.Task, cmsgs socket.ControlMessages) int {
 		space += cmsgSpace(t, linux.SizeOfTimeval)
 	}
 
+	if cmsgs.IP.HasTimestamping {
+		space += cmsgSpace(t, linux.SizeOfControlMessageTimestamping)
+	}
+
+	if cmsgs.IP.HasDropCount {
+		space += cmsgSpace(t, linux.SizeOfControlMessageDropCount)
+	}
+
 	if cmsgs.IP.HasInq {
 		space += cmsgSpace(t, linux.SizeOfControlMessageInq)
 	}
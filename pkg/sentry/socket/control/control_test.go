@@ -0,0 +1,76 @@
+// Copyright 2023 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package control
+
+import (
+	"testing"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/abi/linux"
+)
+
+func TestPackTimestamping(t *testing.T) {
+	const align = 8
+	timestamp := time.Unix(5, 6)
+	buf := packTimestamping(timestamp, align, make([]byte, 0, 128))
+
+	wantLen := linux.SizeOfControlMessageHeader + linux.SizeOfControlMessageTimestamping
+	if len(buf) != wantLen {
+		t.Fatalf("got control message of %d bytes, want %d", len(buf), wantLen)
+	}
+	var hdr linux.ControlMessageHeader
+	hdr.UnmarshalBytes(buf)
+	want := linux.ControlMessageHeader{
+		Length: uint64(wantLen),
+		Level:  linux.SOL_SOCKET,
+		Type:   linux.SCM_TIMESTAMPING,
+	}
+	if hdr != want {
+		t.Errorf("got header %+v, want %+v", hdr, want)
+	}
+
+	// The software timestamp comes first, followed by the unavailable
+	// hardware timestamps.
+	data := buf[linux.SizeOfControlMessageHeader:]
+	var ts linux.Timespec
+	ts.UnmarshalBytes(data)
+	if got, want := ts, linux.NsecToTimespec(timestamp.UnixNano()); got != want {
+		t.Errorf("got software timestamp %+v, want %+v", got, want)
+	}
+	for i, b := range data[ts.SizeBytes():] {
+		if b != 0 {
+			t.Errorf("got non-zero byte %#x at offset %d of the hardware timestamps, want zero", b, ts.SizeBytes()+i)
+			break
+		}
+	}
+}
+
+func TestPackTimestampingTruncated(t *testing.T) {
+	// Without room for the timestamps, only the header is written.
+	buf := packTimestamping(time.Unix(5, 6), 8, make([]byte, 0, linux.SizeOfControlMessageHeader+1))
+	if len(buf) != linux.SizeOfControlMessageHeader {
+		t.Fatalf("got control message of %d bytes, want %d", len(buf), linux.SizeOfControlMessageHeader)
+	}
+	var hdr linux.ControlMessageHeader
+	hdr.UnmarshalBytes(buf)
+	if hdr.Length != uint64(linux.SizeOfControlMessageHeader) {
+		t.Errorf("got length %d, want %d", hdr.Length, linux.SizeOfControlMessageHeader)
+	}
+
+	// Without room for the header, nothing is written.
+	if buf := packTimestamping(time.Unix(5, 6), 8, make([]byte, 0, linux.SizeOfControlMessageHeader-1)); len(buf) != 0 {
+		t.Errorf("got control message of %d bytes, want none", len(buf))
+	}
+}
@@ -223,12 +223,16 @@ var Metrics = tcpip.Stats{
 		OutgoingPacketErrors:                mustCreateMetric("/netstack/ip/outgoing_packet_errors", "Number of IP packets which failed to write to a link-layer endpoint."),
 		MalformedPacketsReceived:            mustCreateMetric("/netstack/ip/malformed_packets_received", "Number of IP packets which failed IP header validation checks."),
 		MalformedFragmentsReceived:          mustCreateMetric("/netstack/ip/malformed_fragments_received", "Number of IP fragments which failed IP fragment validation checks."),
+		DuplicateFragmentsReceived:          mustCreateMetric("/netstack/ip/duplicate_fragments_received", "Number of IP fragments dropped because their payload was already received."),
+		PacketsReassembled:                  mustCreateMetric("/netstack/ip/packets_reassembled", "Number of IP packets reassembled from fragments."),
+		FragmentsReassembled:                mustCreateMetric("/netstack/ip/fragments_reassembled", "Number of IP fragments combined into reassembled packets."),
 		IPTablesPreroutingDropped:           mustCreateMetric("/netstack/ip/iptables/prerouting_dropped", "Number of IP packets dropped in the Prerouting chain."),
 		IPTablesInputDropped:                mustCreateMetric("/netstack/ip/iptables/input_dropped", "Number of IP packets dropped in the Input chain."),
 		IPTablesOutputDropped:               mustCreateMetric("/netstack/ip/iptables/output_dropped", "Number of IP packets dropped in the Output chain."),
 		OptionTimestampReceived:             mustCreateMetric("/netstack/ip/options/timestamp_received", "Number of timestamp options found in received IP packets."),
 		OptionRecordRouteReceived:           mustCreateMetric("/netstack/ip/options/record_route_received", "Number of record route options found in received IP packets."),
 		OptionRouterAlertReceived:           mustCreateMetric("/netstack/ip/options/router_alert_received", "Number of router alert options found in received IP packets."),
+		OptionJumboPayloadReceived:          mustCreateMetric("/netstack/ip/options/jumbo_payload_received", "Number of IPv6 jumbo payload options found in received IP packets."),
 		OptionUnknownReceived:               mustCreateMetric("/netstack/ip/options/unknown_received", "Number of unknown options found in received IP packets."),
 		Forwarding: tcpip.IPForwardingStats{
 			Unrouteable:            mustCreateMetric("/netstack/ip/forwarding/unrouteable", "Number of IP packets received which couldn't be routed and thus were not forwarded."),
@@ -377,6 +381,10 @@ type sock struct {
 	// timestamp holds the timestamp to use with SIOCTSTAMP. It is only
 	// valid when timestampValid is true. It is protected by readMu.
 	timestamp time.Time `state:".(int64)"`
+	// sockOptTimestamping holds the SOF_TIMESTAMPING_* flags set with
+	// SO_TIMESTAMPING. Only software receive timestamps are generated;
+	// hardware timestamps are never available. It is protected by readMu.
+	sockOptTimestamping uint32
 
 	// TODO(b/153685824): Move this to SocketOptions.
 	// sockOptInq corresponds to TCP_INQ.
@@ -562,6 +570,15 @@ func (s *sock) GetSockOpt(t *kernel.Task, level, name int, outPtr hostarch.Addr,
 		}
 		return &val, nil
 	}
+	if level == linux.SOL_SOCKET && name == linux.SO_TIMESTAMPING {
+		if outLen < sizeOfInt32 {
+			return nil, syserr.ErrInvalidArgument
+		}
+		s.readMu.Lock()
+		defer s.readMu.Unlock()
+		val := primitive.Int32(s.sockOptTimestamping)
+		return &val, nil
+	}
 	if level == linux.SOL_TCP && name == linux.TCP_INQ {
 		if outLen < sizeOfInt32 {
 			return nil, syserr.ErrInvalidArgument
@@ -595,6 +612,19 @@ func (s *sock) SetSockOpt(t *kernel.Task, level int, name int, optVal []byte) *s
 		s.sockOptTimestamp = hostarch.ByteOrder.Uint32(optVal) != 0
 		return nil
 	}
+	if level == linux.SOL_SOCKET && name == linux.SO_TIMESTAMPING {
+		if len(optVal) < sizeOfInt32 {
+			return syserr.ErrInvalidArgument
+		}
+		flags := hostarch.ByteOrder.Uint32(optVal)
+		if flags&^linux.SOF_TIMESTAMPING_MASK != 0 {
+			return syserr.ErrInvalidArgument
+		}
+		s.readMu.Lock()
+		defer s.readMu.Unlock()
+		s.sockOptTimestamping = flags
+		return nil
+	}
 	if level == linux.SOL_TCP && name == linux.TCP_INQ {
 		if len(optVal) < sizeOfInt32 {
 			return syserr.ErrInvalidArgument
@@ -866,9 +896,11 @@ func GetSockOpt(t *kernel.Task, s socket.Socket, ep commonEndpoint, family int,
 	case linux.SOL_ICMPV6:
 		return getSockOptICMPv6(t, s, ep, name, outLen)
 
+	case linux.SOL_PACKET:
+		return getSockOptPacket(t, s, ep, name, outLen)
+
 	case linux.SOL_UDP,
-		linux.SOL_RAW,
-		linux.SOL_PACKET:
+		linux.SOL_RAW:
 		// Not supported.
 	}
 
@@ -883,7 +915,7 @@ func boolToInt32(v bool) int32 {
 }
 
 // getSockOptSocket implements GetSockOpt when level is SOL_SOCKET.
-func getSockOptSocket(t *kernel.Task, s socket.Socket, ep commonEndpoint, family int, _ linux.SockType, name, outLen int) (marshal.Marshallable, *syserr.Error) {
+func getSockOptSocket(t *kernel.Task, s socket.Socket, ep commonEndpoint, family int, skType linux.SockType, name, outLen int) (marshal.Marshallable, *syserr.Error) {
 	// TODO(b/124056281): Stop rejecting short optLen values in getsockopt.
 	switch name {
 	case linux.SO_ERROR:
@@ -997,6 +1029,17 @@ func getSockOptSocket(t *kernel.Task, s socket.Socket, ep commonEndpoint, family
 		v := primitive.Int32(boolToInt32(ep.SocketOptions().GetBroadcast()))
 		return &v, nil
 
+	case linux.SO_RXQ_OVFL:
+		if !supportsRxqOvfl(family, skType) {
+			return nil, syserr.ErrProtocolNotAvailable
+		}
+		if outLen < sizeOfInt32 {
+			return nil, syserr.ErrInvalidArgument
+		}
+
+		v := primitive.Int32(boolToInt32(ep.SocketOptions().GetRxqOvfl()))
+		return &v, nil
+
 	case linux.SO_KEEPALIVE:
 		if outLen < sizeOfInt32 {
 			return nil, syserr.ErrInvalidArgument
@@ -1332,6 +1375,32 @@ func getSockOptICMPv6(t *kernel.Task, s socket.Socket, ep commonEndpoint, name i
 	return nil, syserr.ErrProtocolNotAvailable
 }
 
+// getSockOptPacket implements GetSockOpt when level is SOL_PACKET.
+func getSockOptPacket(t *kernel.Task, s socket.Socket, ep commonEndpoint, name int, outLen int) (marshal.Marshallable, *syserr.Error) {
+	if family, _, _ := s.Type(); family != linux.AF_PACKET {
+		return nil, syserr.ErrProtocolNotAvailable
+	}
+
+	switch name {
+	case linux.PACKET_IGNORE_OUTGOING:
+		if outLen < sizeOfInt32 {
+			return nil, syserr.ErrInvalidArgument
+		}
+
+		v := primitive.Int32(boolToInt32(ep.SocketOptions().GetIgnoreOutgoing()))
+		return &v, nil
+
+	case linux.PACKET_QDISC_BYPASS:
+		if outLen < sizeOfInt32 {
+			return nil, syserr.ErrInvalidArgument
+		}
+
+		v := primitive.Int32(boolToInt32(ep.SocketOptions().GetQdiscBypass()))
+		return &v, nil
+	}
+	return nil, syserr.ErrProtocolNotAvailable
+}
+
 func defaultTTL(t *kernel.Task, network tcpip.NetworkProtocolNumber) (primitive.Int32, tcpip.Error) {
 	var opt tcpip.DefaultTTLOption
 	stack := inet.StackFromContext(t)
@@ -1761,10 +1830,7 @@ func SetSockOpt(t *kernel.Task, s socket.Socket, ep commonEndpoint, level int, n
 		return setSockOptIP(t, s, ep, name, optVal)
 
 	case linux.SOL_PACKET:
-		// gVisor doesn't support any SOL_PACKET options just return not
-		// supported. Returning nil here will result in tcpdump thinking AF_PACKET
-		// features are supported and proceed to use them and break.
-		return syserr.ErrProtocolNotAvailable
+		return setSockOptPacket(t, s, ep, name, optVal)
 
 	case linux.SOL_UDP,
 		linux.SOL_RAW:
@@ -1794,6 +1860,12 @@ func clampBufSize(newSz, min, max int64, ignoreMax bool) int64 {
 	return newSz
 }
 
+// supportsRxqOvfl returns true if sockets of the given family and type report
+// the number of packets they dropped via SO_RXQ_OVFL.
+func supportsRxqOvfl(family int, skType linux.SockType) bool {
+	return (family == linux.AF_INET || family == linux.AF_INET6) && (skType == linux.SOCK_DGRAM || skType == linux.SOCK_RAW)
+}
+
 // setSockOptSocket implements SetSockOpt when level is SOL_SOCKET.
 func setSockOptSocket(t *kernel.Task, s socket.Socket, ep commonEndpoint, name int, optVal []byte) *syserr.Error {
 	switch name {
@@ -1881,6 +1953,18 @@ func setSockOptSocket(t *kernel.Task, s socket.Socket, ep commonEndpoint, name i
 		ep.SocketOptions().SetBroadcast(v != 0)
 		return nil
 
+	case linux.SO_RXQ_OVFL:
+		if family, skType, _ := s.Type(); !supportsRxqOvfl(family, skType) {
+			return syserr.ErrProtocolNotAvailable
+		}
+		if len(optVal) < sizeOfInt32 {
+			return syserr.ErrInvalidArgument
+		}
+
+		v := hostarch.ByteOrder.Uint32(optVal)
+		ep.SocketOptions().SetRxqOvfl(v != 0)
+		return nil
+
 	case linux.SO_PASSCRED:
 		if len(optVal) < sizeOfInt32 {
 			return syserr.ErrInvalidArgument
@@ -2140,6 +2224,91 @@ func setSockOptICMPv6(t *kernel.Task, s socket.Socket, ep commonEndpoint, name i
 	return nil
 }
 
+// setSockOptPacket implements SetSockOpt when level is SOL_PACKET.
+func setSockOptPacket(t *kernel.Task, s socket.Socket, ep commonEndpoint, name int, optVal []byte) *syserr.Error {
+	if family, _, _ := s.Type(); family != linux.AF_PACKET {
+		return syserr.ErrProtocolNotAvailable
+	}
+
+	switch name {
+	case linux.PACKET_IGNORE_OUTGOING:
+		if len(optVal) < sizeOfInt32 {
+			return syserr.ErrInvalidArgument
+		}
+
+		v := hostarch.ByteOrder.Uint32(optVal)
+		ep.SocketOptions().SetIgnoreOutgoing(v != 0)
+		return nil
+
+	case linux.PACKET_QDISC_BYPASS:
+		if len(optVal) < sizeOfInt32 {
+			return syserr.ErrInvalidArgument
+		}
+
+		v := hostarch.ByteOrder.Uint32(optVal)
+		ep.SocketOptions().SetQdiscBypass(v != 0)
+		return nil
+
+	case linux.PACKET_ADD_MEMBERSHIP, linux.PACKET_DROP_MEMBERSHIP:
+		m, err := copyInPacketMembership(optVal)
+		if err != nil {
+			return err
+		}
+		var opt tcpip.SettableSocketOption
+		if name == linux.PACKET_ADD_MEMBERSHIP {
+			v := tcpip.AddPacketMembershipOption(m)
+			opt = &v
+		} else {
+			v := tcpip.RemovePacketMembershipOption(m)
+			opt = &v
+		}
+		return syserr.TranslateNetstackError(ep.SetSockOpt(opt))
+	}
+
+	// gVisor doesn't support the remaining SOL_PACKET options, so return not
+	// supported. Returning nil here will result in tcpdump thinking AF_PACKET
+	// features are supported and proceed to use them and break.
+	return syserr.ErrProtocolNotAvailable
+}
+
+// copyInPacketMembership copies in a struct packet_mreq for
+// PACKET_ADD_MEMBERSHIP and PACKET_DROP_MEMBERSHIP.
+func copyInPacketMembership(optVal []byte) (tcpip.PacketMembershipOption, *syserr.Error) {
+	if len(optVal) < linux.SizeOfPacketMreq {
+		return tcpip.PacketMembershipOption{}, syserr.ErrInvalidArgument
+	}
+
+	var req linux.PacketMreq
+	req.IfIndex = int32(hostarch.ByteOrder.Uint32(optVal))
+	req.Type = hostarch.ByteOrder.Uint16(optVal[4:])
+	req.ALen = hostarch.ByteOrder.Uint16(optVal[6:])
+	copy(req.Address[:], optVal[8:linux.SizeOfPacketMreq])
+	if int(req.ALen) > len(req.Address) {
+		return tcpip.PacketMembershipOption{}, syserr.ErrInvalidArgument
+	}
+
+	var typ tcpip.PacketMembershipType
+	switch req.Type {
+	case linux.PACKET_MR_PROMISC:
+		typ = tcpip.PacketMembershipPromiscuous
+	case linux.PACKET_MR_MULTICAST:
+		typ = tcpip.PacketMembershipMulticast
+	case linux.PACKET_MR_ALLMULTI:
+		typ = tcpip.PacketMembershipAllMulticast
+	case linux.PACKET_MR_UNICAST:
+		typ = tcpip.PacketMembershipUnicast
+	default:
+		return tcpip.PacketMembershipOption{}, syserr.ErrInvalidArgument
+	}
+	// Like Linux, memberships are told apart by their address whatever their
+	// type.
+	return tcpip.PacketMembershipOption{
+		NIC:  tcpip.NICID(req.IfIndex),
+		Type: typ,
+		Addr: tcpip.LinkAddress(req.Address[:req.ALen]),
+	}, nil
+}
+
 // setSockOptIPv6 implements SetSockOpt when level is SOL_IPV6.
 func setSockOptIPv6(t *kernel.Task, s socket.Socket, ep commonEndpoint, name int, optVal []byte) *syserr.Error {
 	if _, ok := ep.(tcpip.Endpoint); !ok {
@@ -2637,6 +2806,25 @@ func toLinuxPacketType(pktType tcpip.PacketType) uint8 {
 	}
 }
 
+// setLinkHardwareType fills in the hardware type of the device that addr
+// refers to. Devices without a hardware address (ARPHRD_NONE) report an empty
+// address.
+func (s *sock) setLinkHardwareType(addr *linux.SockAddrLink) {
+	stk, ok := s.namespace.Stack().(*Stack)
+	if !ok {
+		return
+	}
+	hwType, err := stk.Stack.NICARPHardwareType(tcpip.NICID(addr.InterfaceIndex))
+	if err != nil {
+		return
+	}
+	addr.ARPHardwareType = toLinuxARPHardwareType(hwType)
+	if addr.ARPHardwareType == linux.ARPHRD_NONE {
+		addr.HardwareAddrLen = 0
+		addr.HardwareAddr = [8]byte{}
+	}
+}
+
 // nonBlockingRead issues a non-blocking read.
 //
 // TODO(b/78348848): Support timestamps for stream sockets.
@@ -2688,9 +2876,19 @@ func (s *sock) nonBlockingRead(ctx context.Context, dst usermem.IOSequence, peek
 			case *linux.SockAddrLink:
 				v.Protocol = socket.Htons(uint16(res.LinkPacketInfo.Protocol))
 				v.PacketType = toLinuxPacketType(res.LinkPacketInfo.PktType)
+				s.setLinkHardwareType(v)
 			}
 		}
 
+		// Datagrams larger than the buffer are truncated and flagged with
+		// MSG_TRUNC. If MSG_TRUNC was passed in, the full length of the
+		// datagram is returned even though only part of it was copied.
+		//
+		// "MSG_TRUNC (since Linux 2.2): For raw (AF_PACKET), Internet
+		// datagram (since Linux 2.4.27/2.6.8), netlink (since Linux 2.6.22),
+		// and UNIX datagram (since Linux 3.4) sockets: return the real length
+		// of the packet or datagram, even when it was longer than the passed
+		// buffer." - recv(2)
 		msgLen := res.Count
 		if trunc {
 			msgLen = res.Total
@@ -2735,6 +2933,7 @@ func (s *sock) netstackToLinuxControlMessages(cm tcpip.ReceivableControlMessages
 		IP: socket.IPControlMessages{
 			HasTimestamp:       readCM.HasTimestamp && s.sockOptTimestamp,
 			Timestamp:          readCM.Timestamp,
+			HasTimestamping:    readCM.HasTimestamp && s.softwareRxTimestamping(),
 			HasInq:             readCM.HasInq,
 			Inq:                readCM.Inq,
 			HasTOS:             readCM.HasTOS,
@@ -2751,6 +2950,8 @@ func (s *sock) netstackToLinuxControlMessages(cm tcpip.ReceivableControlMessages
 			IPv6PacketInfo:     readCM.IPv6PacketInfo,
 			OriginalDstAddress: readCM.OriginalDstAddress,
 			SockErr:            readCM.SockErr,
+			HasDropCount:       readCM.HasDropCount,
+			DropCount:          readCM.DropCount,
 		},
 	}
 }
@@ -2764,6 +2965,15 @@ func (s *sock) linuxToNetstackControlMessages(cm socket.ControlMessages) tcpip.S
 	}
 }
 
+// softwareRxTimestamping returns true if SO_TIMESTAMPING requests that
+// software receive timestamps be generated and reported.
+//
+// Precondition: s.readMu must be locked.
+func (s *sock) softwareRxTimestamping() bool {
+	const want = linux.SOF_TIMESTAMPING_RX_SOFTWARE | linux.SOF_TIMESTAMPING_SOFTWARE
+	return s.sockOptTimestamping&want == want
+}
+
 // updateTimestamp sets the timestamp for SIOCGSTAMP. It should be called after
 // successfully writing packet data out to userspace.
 //
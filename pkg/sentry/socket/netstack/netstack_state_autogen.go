@@ -26,6 +26,7 @@ func (s *sock) StateFields() []string {
 		"sockOptTimestamp",
 		"timestampValid",
 		"timestamp",
+		"sockOptTimestamping",
 		"sockOptInq",
 	}
 }
@@ -51,7 +52,8 @@ func (s *sock) StateSave(stateSinkObject state.Sink) {
 	stateSinkObject.Save(10, &s.namespace)
 	stateSinkObject.Save(11, &s.sockOptTimestamp)
 	stateSinkObject.Save(12, &s.timestampValid)
-	stateSinkObject.Save(14, &s.sockOptInq)
+	stateSinkObject.Save(14, &s.sockOptTimestamping)
+	stateSinkObject.Save(15, &s.sockOptInq)
 }
 
 func (s *sock) afterLoad() {}
@@ -71,7 +73,8 @@ func (s *sock) StateLoad(stateSourceObject state.Source) {
 	stateSourceObject.Load(10, &s.namespace)
 	stateSourceObject.Load(11, &s.sockOptTimestamp)
 	stateSourceObject.Load(12, &s.timestampValid)
-	stateSourceObject.Load(14, &s.sockOptInq)
+	stateSourceObject.Load(14, &s.sockOptTimestamping)
+	stateSourceObject.Load(15, &s.sockOptInq)
 	stateSourceObject.LoadValue(13, new(int64), func(y any) { s.loadTimestamp(y.(int64)) })
 }
 
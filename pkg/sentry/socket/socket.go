@@ -141,6 +141,8 @@ func NewIPControlMessages(family int, cmgs tcpip.ReceivableControlMessages) IPCo
 		HasIPv6PacketInfo:  cmgs.HasIPv6PacketInfo,
 		OriginalDstAddress: orgDstAddr,
 		SockErr:            sockErrCmsgToLinux(cmgs.SockErr),
+		HasDropCount:       cmgs.HasDropCount,
+		DropCount:          cmgs.DropCount,
 	}
 
 	if cm.HasIPv6PacketInfo {
@@ -162,6 +164,10 @@ type IPControlMessages struct {
 	// was received.
 	Timestamp time.Time `state:".(int64)"`
 
+	// HasTimestamping indicates whether Timestamp should also be delivered
+	// as a software timestamp in an SCM_TIMESTAMPING control message.
+	HasTimestamping bool
+
 	// HasInq indicates whether Inq is valid/set.
 	HasInq bool
 
@@ -210,6 +216,13 @@ type IPControlMessages struct {
 
 	// SockErr is the dequeued socket error on recvmsg(MSG_ERRQUEUE).
 	SockErr linux.SockErrCMsg
+
+	// HasDropCount indicates whether DropCount is valid/set.
+	HasDropCount bool
+
+	// DropCount is the number of packets dropped by the socket before the
+	// associated packet was received.
+	DropCount uint32
 }
 
 // Release releases Unix domain socket credentials and rights.
@@ -14,6 +14,7 @@ func (i *IPControlMessages) StateFields() []string {
 	return []string{
 		"HasTimestamp",
 		"Timestamp",
+		"HasTimestamping",
 		"HasInq",
 		"Inq",
 		"HasTOS",
@@ -30,6 +31,8 @@ func (i *IPControlMessages) StateFields() []string {
 		"IPv6PacketInfo",
 		"OriginalDstAddress",
 		"SockErr",
+		"HasDropCount",
+		"DropCount",
 	}
 }
 
@@ -42,22 +45,25 @@ func (i *IPControlMessages) StateSave(stateSinkObject state.Sink) {
 	TimestampValue = i.saveTimestamp()
 	stateSinkObject.SaveValue(1, TimestampValue)
 	stateSinkObject.Save(0, &i.HasTimestamp)
-	stateSinkObject.Save(2, &i.HasInq)
-	stateSinkObject.Save(3, &i.Inq)
-	stateSinkObject.Save(4, &i.HasTOS)
-	stateSinkObject.Save(5, &i.TOS)
-	stateSinkObject.Save(6, &i.HasTTL)
-	stateSinkObject.Save(7, &i.TTL)
-	stateSinkObject.Save(8, &i.HasHopLimit)
-	stateSinkObject.Save(9, &i.HopLimit)
-	stateSinkObject.Save(10, &i.HasTClass)
-	stateSinkObject.Save(11, &i.TClass)
-	stateSinkObject.Save(12, &i.HasIPPacketInfo)
-	stateSinkObject.Save(13, &i.PacketInfo)
-	stateSinkObject.Save(14, &i.HasIPv6PacketInfo)
-	stateSinkObject.Save(15, &i.IPv6PacketInfo)
-	stateSinkObject.Save(16, &i.OriginalDstAddress)
-	stateSinkObject.Save(17, &i.SockErr)
+	stateSinkObject.Save(2, &i.HasTimestamping)
+	stateSinkObject.Save(3, &i.HasInq)
+	stateSinkObject.Save(4, &i.Inq)
+	stateSinkObject.Save(5, &i.HasTOS)
+	stateSinkObject.Save(6, &i.TOS)
+	stateSinkObject.Save(7, &i.HasTTL)
+	stateSinkObject.Save(8, &i.TTL)
+	stateSinkObject.Save(9, &i.HasHopLimit)
+	stateSinkObject.Save(10, &i.HopLimit)
+	stateSinkObject.Save(11, &i.HasTClass)
+	stateSinkObject.Save(12, &i.TClass)
+	stateSinkObject.Save(13, &i.HasIPPacketInfo)
+	stateSinkObject.Save(14, &i.PacketInfo)
+	stateSinkObject.Save(15, &i.HasIPv6PacketInfo)
+	stateSinkObject.Save(16, &i.IPv6PacketInfo)
+	stateSinkObject.Save(17, &i.OriginalDstAddress)
+	stateSinkObject.Save(18, &i.SockErr)
+	stateSinkObject.Save(19, &i.HasDropCount)
+	stateSinkObject.Save(20, &i.DropCount)
 }
 
 func (i *IPControlMessages) afterLoad() {}
@@ -65,22 +71,25 @@ func (i *IPControlMessages) afterLoad() {}
 // +checklocksignore
 func (i *IPControlMessages) StateLoad(stateSourceObject state.Source) {
 	stateSourceObject.Load(0, &i.HasTimestamp)
-	stateSourceObject.Load(2, &i.HasInq)
-	stateSourceObject.Load(3, &i.Inq)
-	stateSourceObject.Load(4, &i.HasTOS)
-	stateSourceObject.Load(5, &i.TOS)
-	stateSourceObject.Load(6, &i.HasTTL)
-	stateSourceObject.Load(7, &i.TTL)
-	stateSourceObject.Load(8, &i.HasHopLimit)
-	stateSourceObject.Load(9, &i.HopLimit)
-	stateSourceObject.Load(10, &i.HasTClass)
-	stateSourceObject.Load(11, &i.TClass)
-	stateSourceObject.Load(12, &i.HasIPPacketInfo)
-	stateSourceObject.Load(13, &i.PacketInfo)
-	stateSourceObject.Load(14, &i.HasIPv6PacketInfo)
-	stateSourceObject.Load(15, &i.IPv6PacketInfo)
-	stateSourceObject.Load(16, &i.OriginalDstAddress)
-	stateSourceObject.Load(17, &i.SockErr)
+	stateSourceObject.Load(2, &i.HasTimestamping)
+	stateSourceObject.Load(3, &i.HasInq)
+	stateSourceObject.Load(4, &i.Inq)
+	stateSourceObject.Load(5, &i.HasTOS)
+	stateSourceObject.Load(6, &i.TOS)
+	stateSourceObject.Load(7, &i.HasTTL)
+	stateSourceObject.Load(8, &i.TTL)
+	stateSourceObject.Load(9, &i.HasHopLimit)
+	stateSourceObject.Load(10, &i.HopLimit)
+	stateSourceObject.Load(11, &i.HasTClass)
+	stateSourceObject.Load(12, &i.TClass)
+	stateSourceObject.Load(13, &i.HasIPPacketInfo)
+	stateSourceObject.Load(14, &i.PacketInfo)
+	stateSourceObject.Load(15, &i.HasIPv6PacketInfo)
+	stateSourceObject.Load(16, &i.IPv6PacketInfo)
+	stateSourceObject.Load(17, &i.OriginalDstAddress)
+	stateSourceObject.Load(18, &i.SockErr)
+	stateSourceObject.Load(19, &i.HasDropCount)
+	stateSourceObject.Load(20, &i.DropCount)
 	stateSourceObject.LoadValue(1, new(int64), func(y any) { i.loadTimestamp(y.(int64)) })
 }
 
@@ -26,10 +26,9 @@ import (
 	"gvisor.dev/gvisor/pkg/sentry/kernel"
 	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
 	"gvisor.dev/gvisor/pkg/sentry/kernel/ipc"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/semaphore"
 )
 
-const opsMax = 500 // SEMOPM
-
 // Semget handles: semget(key_t key, int nsems, int semflg)
 func Semget(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
 	key := ipc.Key(args[0].Int())
@@ -63,7 +62,7 @@ func Semtimedop(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uint
 	if nsops <= 0 {
 		return 0, nil, linuxerr.EINVAL
 	}
-	if nsops > opsMax {
+	if nsops > semaphore.OpsMax {
 		return 0, nil, linuxerr.E2BIG
 	}
 
@@ -98,7 +97,7 @@ func Semop(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr,
 	if nsops <= 0 {
 		return 0, nil, linuxerr.EINVAL
 	}
-	if nsops > opsMax {
+	if nsops > semaphore.OpsMax {
 		return 0, nil, linuxerr.E2BIG
 	}
 
@@ -117,15 +116,26 @@ func semTimedOp(t *kernel.Task, id ipc.ID, ops []linux.Sembuf, haveTimeout bool,
 	}
 	creds := auth.CredentialsFromContext(t)
 	pid := t.Kernel().GlobalInit().PIDNamespace().IDOfThreadGroup(t.ThreadGroup())
-	for {
+	for woken := false; ; woken = true {
 		ch, num, err := set.ExecuteOps(t, ops, creds, int32(pid))
 		if ch == nil || err != nil {
 			return err
 		}
-		if _, err = t.BlockWithTimeout(ch, haveTimeout, timeout); err != nil {
+		if woken {
+			// Another waiter consumed the resource we were woken for.
+			set.RecordSpuriousWakeup()
+		}
+		clock := t.Kernel().MonotonicClock()
+		start := clock.Now()
+		_, err = t.BlockWithTimeout(ch, haveTimeout, timeout)
+		set.RecordBlockWait(clock.Now().Sub(start))
+		if err != nil {
 			set.AbortWait(num, ch)
 			return err
 		}
+		if set.Interrupted(num, ch) {
+			return linuxerr.EINTR
+		}
 	}
 }
 
@@ -209,7 +219,7 @@ func Semctl(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr,
 	case linux.SEM_STAT:
 		arg := args[3].Pointer()
 		// id is an index in SEM_STAT.
-		semid, ds, err := semStat(t, int32(id))
+		semid, ds, err := semStat(t, int32(id), false /* statAny */)
 		if err != nil {
 			return 0, nil, err
 		}
@@ -220,8 +230,8 @@ func Semctl(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintptr,
 
 	case linux.SEM_STAT_ANY:
 		arg := args[3].Pointer()
-		// id is an index in SEM_STAT.
-		semid, ds, err := semStatAny(t, int32(id))
+		// id is an index in SEM_STAT_ANY, as in SEM_STAT.
+		semid, ds, err := semStat(t, int32(id), true /* statAny */)
 		if err != nil {
 			return 0, nil, err
 		}
@@ -260,27 +270,25 @@ func ipcStat(t *kernel.Task, id ipc.ID) (*linux.SemidDS, error) {
 	return set.GetStat(creds)
 }
 
-func semStat(t *kernel.Task, index int32) (int32, *linux.SemidDS, error) {
+// semStat returns the ID and semid_ds of the set at index in the registry's
+// internal array. If statAny is true, as for SEM_STAT_ANY, read permission on
+// the set is not required.
+func semStat(t *kernel.Task, index int32, statAny bool) (int32, *linux.SemidDS, error) {
 	r := t.IPCNamespace().SemaphoreRegistry()
 	set := r.FindByIndex(index)
 	if set == nil {
 		return 0, nil, linuxerr.EINVAL
 	}
 	creds := auth.CredentialsFromContext(t)
-	ds, err := set.GetStat(creds)
-	if err != nil {
-		return 0, ds, err
+	var (
+		ds  *linux.SemidDS
+		err error
+	)
+	if statAny {
+		ds, err = set.GetStatAny(creds)
+	} else {
+		ds, err = set.GetStat(creds)
 	}
-	return int32(set.ID()), ds, nil
-}
-
-func semStatAny(t *kernel.Task, index int32) (int32, *linux.SemidDS, error) {
-	set := t.IPCNamespace().SemaphoreRegistry().FindByIndex(index)
-	if set == nil {
-		return 0, nil, linuxerr.EINVAL
-	}
-	creds := auth.CredentialsFromContext(t)
-	ds, err := set.GetStatAny(creds)
 	if err != nil {
 		return 0, ds, err
 	}
@@ -675,7 +675,7 @@ func RecvMMsg(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintpt
 	}
 
 	// Reject flags that we don't handle yet.
-	if flags & ^(baseRecvFlags|linux.MSG_CMSG_CLOEXEC|linux.MSG_ERRQUEUE) != 0 {
+	if flags & ^(baseRecvFlags|linux.MSG_CMSG_CLOEXEC|linux.MSG_ERRQUEUE|linux.MSG_WAITFORONE) != 0 {
 		return 0, nil, linuxerr.EINVAL
 	}
 
@@ -696,6 +696,11 @@ func RecvMMsg(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintpt
 		flags |= linux.MSG_DONTWAIT
 	}
 
+	// MSG_WAITFORONE only applies to recvmmsg(2) itself and is not passed
+	// down to the socket.
+	waitForOne := flags&linux.MSG_WAITFORONE != 0
+	flags &^= linux.MSG_WAITFORONE
+
 	var haveDeadline bool
 	var deadline ktime.Time
 	if toPtr != 0 {
@@ -740,6 +745,12 @@ func RecvMMsg(t *kernel.Task, sysno uintptr, args arch.SyscallArguments) (uintpt
 			break
 		}
 		count++
+
+		// "MSG_WAITFORONE: Turns on MSG_DONTWAIT after the first message has
+		// been received." - recvmmsg(2)
+		if waitForOne {
+			flags |= linux.MSG_DONTWAIT
+		}
 	}
 
 	if count == 0 {
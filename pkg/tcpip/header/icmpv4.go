@@ -74,6 +74,22 @@ const (
 	// icmpv4SequenceOffset is the offset of the sequence field
 	// in an ICMPv4EchoRequest/Reply message.
 	icmpv4SequenceOffset = 6
+
+	// ICMPv4TimestampSize is the size of an ICMPv4 Timestamp or Timestamp
+	// Reply message, as per RFC 792 page 16.
+	ICMPv4TimestampSize = 20
+
+	// icmpv4OriginateTimestampOffset is the offset of the originate timestamp
+	// field in an ICMPv4Timestamp/TimestampReply message.
+	icmpv4OriginateTimestampOffset = 8
+
+	// icmpv4ReceiveTimestampOffset is the offset of the receive timestamp
+	// field in an ICMPv4Timestamp/TimestampReply message.
+	icmpv4ReceiveTimestampOffset = 12
+
+	// icmpv4TransmitTimestampOffset is the offset of the transmit timestamp
+	// field in an ICMPv4Timestamp/TimestampReply message.
+	icmpv4TransmitTimestampOffset = 16
 )
 
 // ICMPv4Type is the ICMP type field described in RFC 792.
@@ -215,6 +231,42 @@ func (b ICMPv4) SetSequence(sequence uint16) {
 	binary.BigEndian.PutUint16(b[icmpv4SequenceOffset:], sequence)
 }
 
+// OriginateTimestamp retrieves the Originate Timestamp field from an ICMPv4
+// Timestamp or Timestamp Reply message.
+func (b ICMPv4) OriginateTimestamp() uint32 {
+	return binary.BigEndian.Uint32(b[icmpv4OriginateTimestampOffset:])
+}
+
+// SetOriginateTimestamp sets the Originate Timestamp field of an ICMPv4
+// Timestamp or Timestamp Reply message.
+func (b ICMPv4) SetOriginateTimestamp(ts uint32) {
+	binary.BigEndian.PutUint32(b[icmpv4OriginateTimestampOffset:], ts)
+}
+
+// ReceiveTimestamp retrieves the Receive Timestamp field from an ICMPv4
+// Timestamp or Timestamp Reply message.
+func (b ICMPv4) ReceiveTimestamp() uint32 {
+	return binary.BigEndian.Uint32(b[icmpv4ReceiveTimestampOffset:])
+}
+
+// SetReceiveTimestamp sets the Receive Timestamp field of an ICMPv4
+// Timestamp or Timestamp Reply message.
+func (b ICMPv4) SetReceiveTimestamp(ts uint32) {
+	binary.BigEndian.PutUint32(b[icmpv4ReceiveTimestampOffset:], ts)
+}
+
+// TransmitTimestamp retrieves the Transmit Timestamp field from an ICMPv4
+// Timestamp or Timestamp Reply message.
+func (b ICMPv4) TransmitTimestamp() uint32 {
+	return binary.BigEndian.Uint32(b[icmpv4TransmitTimestampOffset:])
+}
+
+// SetTransmitTimestamp sets the Transmit Timestamp field of an ICMPv4
+// Timestamp or Timestamp Reply message.
+func (b ICMPv4) SetTransmitTimestamp(ts uint32) {
+	binary.BigEndian.PutUint32(b[icmpv4TransmitTimestampOffset:], ts)
+}
+
 // ICMPv4Checksum calculates the ICMP checksum over the provided ICMP header,
 // and payload.
 func ICMPv4Checksum(h ICMPv4, payloadCsum uint16) uint16 {
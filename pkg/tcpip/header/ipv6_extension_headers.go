@@ -272,6 +272,12 @@ const (
 	// Alert Hop by Hop option as defined in RFC 2711 section 2.1.
 	ipv6RouterAlertHopByHopOptionIdentifier IPv6ExtHdrOptionIdentifier = 5
 
+	// IPv6JumboPayloadHopByHopOptionIdentifier is the identifier for the Jumbo
+	// Payload Hop by Hop option as defined in RFC 2675 section 2. Jumbograms
+	// are not supported, so the option is parsed as an unknown option whose
+	// identifier requires the packet to be discarded.
+	IPv6JumboPayloadHopByHopOptionIdentifier IPv6ExtHdrOptionIdentifier = 0xC2
+
 	// ipv6ExtHdrOptionTypeOffset is the option type offset in an extension header
 	// option as defined in RFC 8200 section 4.2.
 	ipv6ExtHdrOptionTypeOffset = 0
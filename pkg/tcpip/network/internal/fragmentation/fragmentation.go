@@ -57,6 +57,15 @@ var (
 	// ErrFragmentConflict indicates that, during reassembly, some fragments are
 	// in conflict with one another.
 	ErrFragmentConflict = errors.New("conflicting fragments")
+
+	// ErrFragmentTooSmall indicates that a fragment other than the last one of
+	// a packet carries less data than the configured minimum.
+	ErrFragmentTooSmall = errors.New("fragment too small")
+
+	// ErrFragmentDuplicate indicates that, during reassembly, a fragment was
+	// dropped because its range had already been received. Unlike other
+	// errors, it does not abandon the reassembly.
+	ErrFragmentDuplicate = errors.New("duplicate fragment")
 )
 
 // FragmentID is the identifier for a fragment.
@@ -69,13 +78,35 @@ type FragmentID struct {
 
 	// ID is the identification value of the fragment.
 	//
-	// This is a uint32 because IPv6 uses a 32-bit identification value.
+	// This is a uint32 because IPv6 uses a 32-bit identification value. IDs
+	// are reused once they wrap around, so fragments of distinct packets may
+	// share an ID; see Process.
 	ID uint32
 
 	// The protocol for the packet.
 	Protocol uint8
+
+	// NIC is the NIC the fragment was received on. It must only be set when
+	// the addresses are scoped to a link (e.g. IPv6 link-local or multicast
+	// destinations), so that fragments of routed packets are reassembled
+	// regardless of which NIC they arrived on.
+	NIC tcpip.NICID
 }
 
+// EvictionMode determines which reassemblers are evicted when the memory
+// consumed by fragments exceeds the high limit.
+type EvictionMode int
+
+const (
+	// EvictOldest evicts the oldest reassemblers first. This is the default.
+	EvictOldest EvictionMode = iota
+
+	// EvictHeaviestSource evicts the oldest reassembler of the source holding
+	// the most in-flight bytes first. This prevents a single source from
+	// crowding out reassembly of packets from other sources.
+	EvictHeaviestSource
+)
+
 // Fragmentation is the main structure that other modules
 // of the stack should use to implement IP Fragmentation.
 type Fragmentation struct {
@@ -90,6 +121,8 @@ type Fragmentation struct {
 	clock          tcpip.Clock
 	releaseJob     *tcpip.Job
 	timeoutHandler TimeoutHandler
+	evictionMode   EvictionMode
+	minFragSize    uint16
 }
 
 // TimeoutHandler is consulted if a packet reassembly has timed out.
@@ -115,13 +148,7 @@ type TimeoutHandler interface {
 // Fragments are lazily evicted only when a new a packet with an
 // already existing fragmentation-id arrives after the timeout.
 func NewFragmentation(blockSize uint16, highMemoryLimit, lowMemoryLimit int, reassemblingTimeout time.Duration, clock tcpip.Clock, timeoutHandler TimeoutHandler) *Fragmentation {
-	if lowMemoryLimit >= highMemoryLimit {
-		lowMemoryLimit = highMemoryLimit
-	}
-
-	if lowMemoryLimit < 0 {
-		lowMemoryLimit = 0
-	}
+	highMemoryLimit, lowMemoryLimit = clampMemoryLimits(highMemoryLimit, lowMemoryLimit)
 
 	if blockSize < minBlockSize {
 		blockSize = minBlockSize
@@ -141,22 +168,94 @@ func NewFragmentation(blockSize uint16, highMemoryLimit, lowMemoryLimit int, rea
 	return f
 }
 
+// clampMemoryLimits returns the given memory limits adjusted so that
+// 0 <= low <= high.
+func clampMemoryLimits(high, low int) (int, int) {
+	if low >= high {
+		low = high
+	}
+
+	if low < 0 {
+		low = 0
+	}
+	return high, low
+}
+
+// SetMemoryLimits sets the memory limits of f. Once the memory consumed by
+// fragments exceeds highMemoryLimit, reassemblers are evicted until it drops
+// to lowMemoryLimit. See NewFragmentation.
+func (f *Fragmentation) SetMemoryLimits(highMemoryLimit, lowMemoryLimit int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.highLimit, f.lowLimit = clampMemoryLimits(highMemoryLimit, lowMemoryLimit)
+	f.evictLocked()
+}
+
+// MemoryLimits returns the high and low memory limits of f.
+func (f *Fragmentation) MemoryLimits() (highMemoryLimit, lowMemoryLimit int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.highLimit, f.lowLimit
+}
+
+// SetEvictionMode sets the policy used to pick reassemblers to evict when the
+// memory limit is reached.
+func (f *Fragmentation) SetEvictionMode(mode EvictionMode) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.evictionMode = mode
+}
+
+// SetMinFragmentSize sets the minimum number of bytes carried by a fragment
+// that isn't the last one of its packet. Smaller fragments are dropped, as
+// floods of tiny fragments consume disproportionate reassembly resources. Zero
+// disables the check, which is the default.
+func (f *Fragmentation) SetMinFragmentSize(size uint16) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.minFragSize = size
+}
+
+// MinFragmentSize returns the minimum size of non-final fragments.
+func (f *Fragmentation) MinFragmentSize() uint16 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.minFragSize
+}
+
 // Process processes an incoming fragment belonging to an ID and returns a
 // complete packet and its protocol number when all the packets belonging to
-// that ID have been received.
+// that ID have been received. The complete packet records the number of
+// fragments it was reassembled from and how long reassembly took.
 //
 // [first, last] is the range of the fragment bytes.
 //
 // first must be a multiple of the block size f is configured with. The size
-// of the fragment data must be a multiple of the block size, unless there are
-// no fragments following this fragment (more set to false).
+// of the fragment data must be non-zero and a multiple of the block size,
+// unless there are no fragments following this fragment (more set to false),
+// in which case it only needs to be non-zero.
 //
 // proto is the protocol number marked in the fragment being processed. It has
 // to be given here outside of the FragmentID struct because IPv6 should not use
 // the protocol to identify a fragment.
+//
+// A fragment whose range has already been received is dropped and
+// ErrFragmentDuplicate is returned; reassembly of the packet continues. A
+// fragment that overlaps or conflicts with those already received is dropped
+// along with the whole reassembly, as required by RFC 5722 and RFC 8200
+// section 4.5. The only exception is a first fragment received after the
+// final one, which unambiguously starts a new packet reusing the ID: the
+// existing reassembly is discarded and a new one is started with it.
 func (f *Fragmentation) Process(
 	id FragmentID, first, last uint16, more bool, proto uint8, pkt stack.PacketBufferPtr) (
 	stack.PacketBufferPtr, uint8, bool, error) {
+	// A zero-length fragment can't be described by the inclusive range
+	// [first, last]; callers computing last as first+size-1 would wrap around
+	// and claim the whole 64KiB payload range, leaving a phantom filled hole.
+	if pkt.Data().Size() == 0 {
+		return nil, 0, false, fmt.Errorf("zero-length fragment at first=%d: %w", first, ErrInvalidArgs)
+	}
+
 	if first > last {
 		return nil, 0, false, fmt.Errorf("first=%d is greater than last=%d: %w", first, last, ErrInvalidArgs)
 	}
@@ -176,25 +275,37 @@ func (f *Fragmentation) Process(
 
 	f.mu.Lock()
 	if f.reassemblers == nil {
+		f.mu.Unlock()
 		return nil, 0, false, fmt.Errorf("Release() called before fragmentation processing could finish")
 	}
-
-	r, ok := f.reassemblers[id]
-	if !ok {
-		r = newReassembler(id, f.clock)
-		f.reassemblers[id] = r
-		wasEmpty := f.rList.Empty()
-		f.rList.PushFront(r)
-		if wasEmpty {
-			// If we have just pushed a first reassembler into an empty list, we
-			// should kickstart the release job. The release job will keep
-			// rescheduling itself until the list becomes empty.
-			f.releaseReassemblersLocked()
-		}
+	if more && fragmentSize < f.minFragSize {
+		minFragSize := f.minFragSize
+		f.mu.Unlock()
+		return nil, 0, false, fmt.Errorf("fragment size=%d bytes is smaller than the minimum=%d on non-final fragment: %w", fragmentSize, minFragSize, ErrFragmentTooSmall)
 	}
+	r := f.reassemblerLocked(id)
 	f.mu.Unlock()
 
 	resPkt, firstFragmentProto, done, memConsumed, err := r.process(first, last, more, proto, pkt)
+	if err != nil && err != ErrFragmentDuplicate && first == 0 && r.finalReceived() {
+		// A new first fragment after the final one means that the
+		// identification value wrapped around and a new packet reuses the ID
+		// of one still being reassembled. The old packet can't complete
+		// anymore, so it is abandoned and the fragment starts a new one.
+		f.mu.Lock()
+		f.release(r, false /* timedOut */)
+		if f.reassemblers == nil {
+			f.mu.Unlock()
+			return nil, 0, false, fmt.Errorf("Release() called before fragmentation processing could finish")
+		}
+		r = f.reassemblerLocked(id)
+		f.mu.Unlock()
+		resPkt, firstFragmentProto, done, memConsumed, err = r.process(first, last, more, proto, pkt)
+	}
+	if err == ErrFragmentDuplicate {
+		// Retransmitted fragments are expected; keep the reassembler.
+		return nil, 0, false, err
+	}
 	if err != nil {
 		// We probably got an invalid sequence of fragments. Just
 		// discard the reassembler and move on.
@@ -204,25 +315,91 @@ func (f *Fragmentation) Process(
 		return nil, 0, false, fmt.Errorf("fragmentation processing error: %w", err)
 	}
 	f.mu.Lock()
-	f.memSize += memConsumed
+	// If r was released while it was processing the fragment, the memory it
+	// consumed has already been accounted for.
+	if !r.isDone() {
+		f.memSize += memConsumed
+	}
 	if done {
+		resPkt.ReassembledFragments = r.fragments()
+		resPkt.ReassemblyDuration = f.clock.NowMonotonic().Sub(r.createdAt)
 		f.release(r, false /* timedOut */)
 	}
-	// Evict reassemblers if we are consuming more memory than highLimit until
-	// we reach lowLimit.
-	if f.memSize > f.highLimit {
-		for f.memSize > f.lowLimit {
-			tail := f.rList.Back()
-			if tail == nil {
-				break
-			}
-			f.release(tail, false /* timedOut */)
-		}
-	}
+	f.evictLocked()
 	f.mu.Unlock()
 	return resPkt, firstFragmentProto, done, nil
 }
 
+// reassemblerLocked returns the reassembler for id, creating it if needed.
+// This function must be called with f.mu locked.
+func (f *Fragmentation) reassemblerLocked(id FragmentID) *reassembler {
+	r, ok := f.reassemblers[id]
+	if ok {
+		return r
+	}
+	r = newReassembler(id, f.clock)
+	f.reassemblers[id] = r
+	wasEmpty := f.rList.Empty()
+	f.rList.PushFront(r)
+	if wasEmpty {
+		// If we have just pushed a first reassembler into an empty list, we
+		// should kickstart the release job. The release job will keep
+		// rescheduling itself until the list becomes empty.
+		f.releaseReassemblersLocked()
+	}
+	return r
+}
+
+// ReassemblerInfo describes an in-flight packet reassembly.
+type ReassemblerInfo struct {
+	// ID is the identifier of the packet being reassembled.
+	ID FragmentID
+
+	// MemSize is the memory consumed by the fragments received so far.
+	MemSize int
+
+	// Fragments is the number of fragments received so far.
+	Fragments int
+
+	// Remaining is the time left before the reassembly times out.
+	Remaining time.Duration
+}
+
+// Reassemblers returns a snapshot of the in-flight reassemblies, oldest first.
+// It is intended for debugging and does not affect reassembly timeouts.
+func (f *Fragmentation) Reassemblers() []ReassemblerInfo {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.clock.NowMonotonic()
+	var infos []ReassemblerInfo
+	for r := f.rList.Back(); r != nil; r = r.Prev() {
+		remaining := f.timeout - now.Sub(r.createdAt)
+		if remaining < 0 {
+			remaining = 0
+		}
+		infos = append(infos, ReassemblerInfo{
+			ID:        r.id,
+			MemSize:   r.memSize,
+			Fragments: r.fragments(),
+			Remaining: remaining,
+		})
+	}
+	return infos
+}
+
+// Flush drops all in-flight reassemblies and releases their fragments. It is
+// safe to call concurrently with Process, although fragments processed
+// concurrently may start new reassemblies.
+func (f *Fragmentation) Flush() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for r := f.rList.Front(); r != nil; r = f.rList.Front() {
+		f.release(r, false /* timedOut */)
+	}
+	f.releaseJob.Cancel()
+}
+
 // Release releases all underlying resources.
 func (f *Fragmentation) Release() {
 	f.mu.Lock()
@@ -264,6 +441,51 @@ func (f *Fragmentation) release(r *reassembler, timedOut bool) {
 	r.holes = nil
 }
 
+// evictLocked evicts reassemblers if we are consuming more memory than
+// f.highLimit until we reach f.lowLimit. Evicting down to the lower limit
+// batches evictions rather than evicting one reassembler per fragment once the
+// high limit is reached. This function must be called with f.mu locked.
+func (f *Fragmentation) evictLocked() {
+	if f.memSize <= f.highLimit {
+		return
+	}
+	for f.memSize > f.lowLimit {
+		victim := f.evictionVictimLocked()
+		if victim == nil {
+			break
+		}
+		f.release(victim, false /* timedOut */)
+	}
+}
+
+// evictionVictimLocked returns the reassembler that should be evicted next
+// according to f.evictionMode, or nil if there are no reassemblers. This
+// function must be called with f.mu locked.
+func (f *Fragmentation) evictionVictimLocked() *reassembler {
+	if f.evictionMode != EvictHeaviestSource {
+		return f.rList.Back()
+	}
+
+	var (
+		heaviest    tcpip.Address
+		heaviestMem int
+	)
+	bySource := make(map[tcpip.Address]int)
+	for r := f.rList.Front(); r != nil; r = r.Next() {
+		bySource[r.id.Source] += r.memSize
+		if mem := bySource[r.id.Source]; mem > heaviestMem {
+			heaviest, heaviestMem = r.id.Source, mem
+		}
+	}
+	// The reassembler at the end of the list is the oldest.
+	for r := f.rList.Back(); r != nil; r = r.Prev() {
+		if r.id.Source == heaviest {
+			return r
+		}
+	}
+	return f.rList.Back()
+}
+
 // releaseReassemblersLocked releases already-expired reassemblers, then
 // schedules the job to call back itself for the remaining reassemblers if
 // any. This function must be called with f.mu locked.
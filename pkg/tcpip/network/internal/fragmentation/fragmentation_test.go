@@ -0,0 +1,455 @@
+// Copyright 2023 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fragmentation
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/buffer"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/faketime"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+)
+
+const reassembleTimeout = time.Second
+
+type fragment struct {
+	first uint16
+	last  uint16
+	more  bool
+	fill  byte
+}
+
+func (frag fragment) data() []byte {
+	return bytes.Repeat([]byte{frag.fill}, int(frag.last-frag.first+1))
+}
+
+func newTestFragmentation() *Fragmentation {
+	return NewFragmentation(minBlockSize, HighFragThreshold, LowFragThreshold, reassembleTimeout, faketime.NewManualClock(), nil)
+}
+
+// process passes frag to f and returns the reassembled payload, if any.
+func process(t *testing.T, f *Fragmentation, id FragmentID, frag fragment) ([]byte, bool, error) {
+	t.Helper()
+	pkt := stack.NewPacketBuffer(stack.PacketBufferOptions{
+		Payload: buffer.MakeWithData(frag.data()),
+	})
+	defer pkt.DecRef()
+	resPkt, _, done, err := f.Process(id, frag.first, frag.last, frag.more, 0, pkt)
+	if !done {
+		return nil, false, err
+	}
+	defer resPkt.DecRef()
+	buf := resPkt.Data().ToBuffer()
+	defer buf.Release()
+	return buf.Flatten(), true, err
+}
+
+func TestOverlappingFragmentDiscardsReassembly(t *testing.T) {
+	f := newTestFragmentation()
+	id := FragmentID{ID: 1}
+
+	if _, done, err := process(t, f, id, fragment{first: 0, last: 7, more: true, fill: 1}); done || err != nil {
+		t.Fatalf("got done=%t err=%v, want done=false err=nil", done, err)
+	}
+	// An overlapping fragment abandons the reassembly and is dropped.
+	if _, _, err := process(t, f, id, fragment{first: 4, last: 11, more: true, fill: 2}); !errors.Is(err, ErrFragmentOverlap) {
+		t.Fatalf("got err=%v, want %v", err, ErrFragmentOverlap)
+	}
+	if rs := f.Reassemblers(); len(rs) != 0 {
+		t.Fatalf("got %d reassemblies after overlap, want 0", len(rs))
+	}
+
+	// The remaining fragments of the packet must not be completed by the
+	// overlapping one.
+	if _, done, err := process(t, f, id, fragment{first: 8, last: 15, more: false, fill: 3}); done || err != nil {
+		t.Fatalf("got done=%t err=%v, want done=false err=nil", done, err)
+	}
+	if got := f.Reassemblers(); len(got) != 1 || got[0].Fragments != 1 {
+		t.Fatalf("got reassemblies %+v, want one with a single fragment", got)
+	}
+}
+
+func TestFirstFragmentAfterFinalRestartsReassembly(t *testing.T) {
+	f := newTestFragmentation()
+	id := FragmentID{ID: 1}
+
+	// The first packet loses its middle fragment.
+	for _, frag := range []fragment{
+		{first: 0, last: 7, more: true, fill: 1},
+		{first: 16, last: 23, more: false, fill: 1},
+	} {
+		if _, done, err := process(t, f, id, frag); done || err != nil {
+			t.Fatalf("got done=%t err=%v, want done=false err=nil", done, err)
+		}
+	}
+
+	// A new packet reuses the ID. Its first fragment conflicts with the first
+	// packet's, which has already received its final fragment.
+	second := []fragment{
+		{first: 0, last: 15, more: true, fill: 2},
+		{first: 16, last: 23, more: false, fill: 2},
+	}
+	if _, done, err := process(t, f, id, second[0]); done || err != nil {
+		t.Fatalf("got done=%t err=%v, want done=false err=nil", done, err)
+	}
+	data, done, err := process(t, f, id, second[1])
+	if !done || err != nil {
+		t.Fatalf("got done=%t err=%v, want done=true err=nil", done, err)
+	}
+	if want := append(second[0].data(), second[1].data()...); !bytes.Equal(data, want) {
+		t.Errorf("got reassembled data %v, want %v", data, want)
+	}
+}
+
+func TestNonFirstConflictDoesNotRestartReassembly(t *testing.T) {
+	f := newTestFragmentation()
+	id := FragmentID{ID: 1}
+
+	for _, frag := range []fragment{
+		{first: 0, last: 7, more: true, fill: 1},
+		{first: 16, last: 23, more: false, fill: 1},
+	} {
+		if _, done, err := process(t, f, id, frag); done || err != nil {
+			t.Fatalf("got done=%t err=%v, want done=false err=nil", done, err)
+		}
+	}
+	// A conflicting fragment that isn't a first fragment is ambiguous, so it
+	// is dropped along with the reassembly.
+	if _, _, err := process(t, f, id, fragment{first: 8, last: 19, more: true, fill: 2}); !errors.Is(err, ErrFragmentOverlap) {
+		t.Fatalf("got err=%v, want %v", err, ErrFragmentOverlap)
+	}
+	if rs := f.Reassemblers(); len(rs) != 0 {
+		t.Fatalf("got %d reassemblies, want 0", len(rs))
+	}
+}
+
+func TestReassembledPacketInfo(t *testing.T) {
+	clock := faketime.NewManualClock()
+	f := NewFragmentation(minBlockSize, HighFragThreshold, LowFragThreshold, reassembleTimeout, clock, nil)
+	id := FragmentID{ID: 1}
+
+	const step = 10 * time.Millisecond
+	frags := []fragment{
+		{first: 16, last: 23, more: false, fill: 3},
+		{first: 0, last: 7, more: true, fill: 1},
+		{first: 8, last: 15, more: true, fill: 2},
+	}
+	for i, frag := range frags {
+		if i != 0 {
+			clock.Advance(step)
+		}
+		pkt := stack.NewPacketBuffer(stack.PacketBufferOptions{
+			Payload: buffer.MakeWithData(frag.data()),
+		})
+		resPkt, _, done, err := f.Process(id, frag.first, frag.last, frag.more, 0, pkt)
+		pkt.DecRef()
+		if err != nil {
+			t.Fatalf("f.Process(%+v): %v", frag, err)
+		}
+		if last := i == len(frags)-1; done != last {
+			t.Fatalf("f.Process(%+v) got done=%t, want %t", frag, done, last)
+		}
+		if !done {
+			if got := f.Reassemblers(); len(got) != 1 || got[0].Fragments != i+1 {
+				t.Fatalf("got reassemblies %+v, want one with %d fragments", got, i+1)
+			}
+			continue
+		}
+		if got, want := resPkt.ReassembledFragments, len(frags); got != want {
+			t.Errorf("got ReassembledFragments = %d, want %d", got, want)
+		}
+		if got, want := resPkt.ReassemblyDuration, step*time.Duration(len(frags)-1); got != want {
+			t.Errorf("got ReassemblyDuration = %s, want %s", got, want)
+		}
+		resPkt.DecRef()
+	}
+}
+
+func TestEvictionMode(t *testing.T) {
+	var (
+		sourceA = tcpip.AddrFrom4([4]byte{10, 0, 0, 1})
+		sourceB = tcpip.AddrFrom4([4]byte{10, 0, 0, 2})
+	)
+	// B's reassembly is the oldest, but A has the most in-flight bytes.
+	ids := []FragmentID{
+		{Source: sourceB, ID: 1},
+		{Source: sourceA, ID: 2},
+		{Source: sourceA, ID: 3},
+	}
+	for _, tc := range []struct {
+		name    string
+		mode    EvictionMode
+		evicted FragmentID
+	}{
+		{name: "oldest", mode: EvictOldest, evicted: ids[0]},
+		{name: "heaviest source", mode: EvictHeaviestSource, evicted: ids[1]},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newTestFragmentation()
+			f.SetEvictionMode(tc.mode)
+			for _, id := range ids {
+				if _, done, err := process(t, f, id, fragment{first: 0, last: 7, more: true}); done || err != nil {
+					t.Fatalf("got done=%t err=%v, want done=false err=nil", done, err)
+				}
+			}
+
+			// Make room for all but one reassembly.
+			limit := f.memSize * (len(ids) - 1) / len(ids)
+			f.SetMemoryLimits(limit, limit)
+			rs := f.Reassemblers()
+			if len(rs) != len(ids)-1 {
+				t.Fatalf("got %d reassemblies after eviction, want %d", len(rs), len(ids)-1)
+			}
+			for _, r := range rs {
+				if r.ID == tc.evicted {
+					t.Errorf("reassembly %+v wasn't evicted", tc.evicted)
+				}
+			}
+		})
+	}
+}
+
+func TestFragmentSizeEdgeCases(t *testing.T) {
+	const blockSize = 8
+	f := NewFragmentation(blockSize, HighFragThreshold, LowFragThreshold, reassembleTimeout, faketime.NewManualClock(), nil)
+
+	// A zero-length fragment is rejected without starting a reassembly.
+	pkt := stack.NewPacketBuffer(stack.PacketBufferOptions{})
+	_, _, done, err := f.Process(FragmentID{ID: 1}, 0, 0, false /* more */, 0, pkt)
+	pkt.DecRef()
+	if done || !errors.Is(err, ErrInvalidArgs) {
+		t.Errorf("got done=%t err=%v for a zero-length fragment, want done=false err=%v", done, err, ErrInvalidArgs)
+	}
+	if rs := f.Reassemblers(); len(rs) != 0 {
+		t.Errorf("got %d reassemblies after a zero-length fragment, want 0", len(rs))
+	}
+
+	// Non-final fragments must be a multiple of the block size.
+	if _, done, err := process(t, f, FragmentID{ID: 2}, fragment{first: 0, last: blockSize - 2, more: true}); done || !errors.Is(err, ErrInvalidArgs) {
+		t.Errorf("got done=%t err=%v for a partial block, want done=false err=%v", done, err, ErrInvalidArgs)
+	}
+
+	// A final fragment may be as small as a single byte, whether or not it is
+	// the only fragment.
+	data, done, err := process(t, f, FragmentID{ID: 3}, fragment{first: 0, last: 0, more: false, fill: 1})
+	if !done || err != nil {
+		t.Fatalf("got done=%t err=%v for a single byte packet, want done=true err=nil", done, err)
+	}
+	if want := []byte{1}; !bytes.Equal(data, want) {
+		t.Errorf("got reassembled data %v, want %v", data, want)
+	}
+	first := fragment{first: 0, last: blockSize - 1, more: true, fill: 1}
+	last := fragment{first: blockSize, last: blockSize, more: false, fill: 2}
+	if _, done, err := process(t, f, FragmentID{ID: 4}, last); done || err != nil {
+		t.Fatalf("got done=%t err=%v, want done=false err=nil", done, err)
+	}
+	data, done, err = process(t, f, FragmentID{ID: 4}, first)
+	if !done || err != nil {
+		t.Fatalf("got done=%t err=%v, want done=true err=nil", done, err)
+	}
+	if want := append(first.data(), last.data()...); !bytes.Equal(data, want) {
+		t.Errorf("got reassembled data %v, want %v", data, want)
+	}
+}
+
+func TestReassemblers(t *testing.T) {
+	clock := faketime.NewManualClock()
+	f := NewFragmentation(minBlockSize, HighFragThreshold, LowFragThreshold, reassembleTimeout, clock, nil)
+
+	const step = 100 * time.Millisecond
+	ids := []FragmentID{{ID: 1}, {ID: 2}}
+	for i, id := range ids {
+		if i != 0 {
+			clock.Advance(step)
+		}
+		if _, done, err := process(t, f, id, fragment{first: 0, last: 7, more: true}); done || err != nil {
+			t.Fatalf("got done=%t err=%v, want done=false err=nil", done, err)
+		}
+	}
+	clock.Advance(step)
+
+	rs := f.Reassemblers()
+	if len(rs) != len(ids) {
+		t.Fatalf("got %d reassemblies, want %d", len(rs), len(ids))
+	}
+	// The oldest reassembly is listed first.
+	for i, r := range rs {
+		want := ReassemblerInfo{
+			ID:        ids[i],
+			MemSize:   r.MemSize,
+			Fragments: 1,
+			Remaining: reassembleTimeout - step*time.Duration(len(ids)-i),
+		}
+		if r != want {
+			t.Errorf("got reassembly %d = %+v, want %+v", i, r, want)
+		}
+		if r.MemSize <= 0 {
+			t.Errorf("got reassembly %d MemSize = %d, want > 0", i, r.MemSize)
+		}
+	}
+
+	// Looking at reassemblies doesn't extend them.
+	clock.Advance(reassembleTimeout - step)
+	if rs := f.Reassemblers(); len(rs) != 0 {
+		t.Errorf("got reassemblies %+v after the timeout, want none", rs)
+	}
+}
+
+func TestMemoryLimits(t *testing.T) {
+	for _, tc := range []struct {
+		high, low         int
+		wantHigh, wantLow int
+	}{
+		{high: 100, low: 50, wantHigh: 100, wantLow: 50},
+		{high: 50, low: 100, wantHigh: 50, wantLow: 50},
+		{high: 100, low: -1, wantHigh: 100, wantLow: 0},
+	} {
+		f := newTestFragmentation()
+		f.SetMemoryLimits(tc.high, tc.low)
+		if high, low := f.MemoryLimits(); high != tc.wantHigh || low != tc.wantLow {
+			t.Errorf("SetMemoryLimits(%d, %d): got MemoryLimits() = (%d, %d), want (%d, %d)", tc.high, tc.low, high, low, tc.wantHigh, tc.wantLow)
+		}
+	}
+
+	// Lowering the limits evicts reassemblies down to the low limit.
+	f := newTestFragmentation()
+	const reassemblies = 4
+	for i := 0; i < reassemblies; i++ {
+		if _, done, err := process(t, f, FragmentID{ID: uint32(i)}, fragment{first: 0, last: 7, more: true}); done || err != nil {
+			t.Fatalf("got done=%t err=%v, want done=false err=nil", done, err)
+		}
+	}
+	perReassembly := f.memSize / reassemblies
+	f.SetMemoryLimits(f.memSize-1, perReassembly)
+	if rs := f.Reassemblers(); len(rs) != 1 {
+		t.Fatalf("got %d reassemblies, want 1", len(rs))
+	} else if want := (FragmentID{ID: reassemblies - 1}); rs[0].ID != want {
+		t.Errorf("got remaining reassembly %+v, want the newest %+v", rs[0].ID, want)
+	}
+
+	// Memory below the high limit doesn't trigger eviction.
+	f.SetMemoryLimits(HighFragThreshold, 0)
+	if _, done, err := process(t, f, FragmentID{ID: reassemblies}, fragment{first: 0, last: 7, more: true}); done || err != nil {
+		t.Fatalf("got done=%t err=%v, want done=false err=nil", done, err)
+	}
+	if rs := f.Reassemblers(); len(rs) != 2 {
+		t.Errorf("got %d reassemblies, want 2", len(rs))
+	}
+}
+
+func TestDuplicateFragment(t *testing.T) {
+	f := newTestFragmentation()
+	id := FragmentID{ID: 1}
+
+	first := fragment{first: 0, last: 7, more: true, fill: 1}
+	if _, done, err := process(t, f, id, first); done || err != nil {
+		t.Fatalf("got done=%t err=%v, want done=false err=nil", done, err)
+	}
+	memSize := f.memSize
+	// A retransmitted fragment is dropped without abandoning the reassembly.
+	if _, done, err := process(t, f, id, first); done || !errors.Is(err, ErrFragmentDuplicate) {
+		t.Fatalf("got done=%t err=%v, want done=false err=%v", done, err, ErrFragmentDuplicate)
+	}
+	if f.memSize != memSize {
+		t.Errorf("got memSize = %d after a duplicate, want %d", f.memSize, memSize)
+	}
+	last := fragment{first: 8, last: 15, more: false, fill: 2}
+	data, done, err := process(t, f, id, last)
+	if !done || err != nil {
+		t.Fatalf("got done=%t err=%v, want done=true err=nil", done, err)
+	}
+	if want := append(first.data(), last.data()...); !bytes.Equal(data, want) {
+		t.Errorf("got reassembled data %v, want %v", data, want)
+	}
+}
+
+func TestMinFragmentSize(t *testing.T) {
+	f := newTestFragmentation()
+	if got := f.MinFragmentSize(); got != 0 {
+		t.Errorf("got MinFragmentSize() = %d by default, want 0", got)
+	}
+	const minSize = 16
+	f.SetMinFragmentSize(minSize)
+	if got := f.MinFragmentSize(); got != minSize {
+		t.Errorf("got MinFragmentSize() = %d, want %d", got, minSize)
+	}
+
+	id := FragmentID{ID: 1}
+	if _, done, err := process(t, f, id, fragment{first: 0, last: minSize - 2, more: true}); done || !errors.Is(err, ErrFragmentTooSmall) {
+		t.Errorf("got done=%t err=%v for a small non-final fragment, want done=false err=%v", done, err, ErrFragmentTooSmall)
+	}
+	if rs := f.Reassemblers(); len(rs) != 0 {
+		t.Errorf("got %d reassemblies after a small fragment, want 0", len(rs))
+	}
+
+	// Fragments of the minimum size and small final fragments are accepted.
+	first := fragment{first: 0, last: minSize - 1, more: true, fill: 1}
+	last := fragment{first: minSize, last: minSize, more: false, fill: 2}
+	if _, done, err := process(t, f, id, first); done || err != nil {
+		t.Fatalf("got done=%t err=%v, want done=false err=nil", done, err)
+	}
+	data, done, err := process(t, f, id, last)
+	if !done || err != nil {
+		t.Fatalf("got done=%t err=%v, want done=true err=nil", done, err)
+	}
+	if want := append(first.data(), last.data()...); !bytes.Equal(data, want) {
+		t.Errorf("got reassembled data %v, want %v", data, want)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	clock := faketime.NewManualClock()
+	f := NewFragmentation(minBlockSize, HighFragThreshold, LowFragThreshold, reassembleTimeout, clock, nil)
+	id := FragmentID{ID: 1}
+
+	first := fragment{first: 0, last: 7, more: true, fill: 1}
+	last := fragment{first: 8, last: 15, more: false, fill: 2}
+	for i := 0; i < 2; i++ {
+		if _, done, err := process(t, f, FragmentID{ID: uint32(i)}, first); done || err != nil {
+			t.Fatalf("got done=%t err=%v, want done=false err=nil", done, err)
+		}
+	}
+	f.Flush()
+	if rs := f.Reassemblers(); len(rs) != 0 {
+		t.Errorf("got %d reassemblies after Flush, want 0", len(rs))
+	}
+	if f.memSize != 0 {
+		t.Errorf("got memSize = %d after Flush, want 0", f.memSize)
+	}
+
+	// The flushed fragments don't complete packets, but new reassemblies work
+	// and time out as usual.
+	if _, done, err := process(t, f, id, last); done || err != nil {
+		t.Fatalf("got done=%t err=%v after Flush, want done=false err=nil", done, err)
+	}
+	clock.Advance(reassembleTimeout)
+	if rs := f.Reassemblers(); len(rs) != 0 {
+		t.Errorf("got %d reassemblies after the timeout, want 0", len(rs))
+	}
+	if _, done, err := process(t, f, id, first); done || err != nil {
+		t.Fatalf("got done=%t err=%v, want done=false err=nil", done, err)
+	}
+	data, done, err := process(t, f, id, last)
+	if !done || err != nil {
+		t.Fatalf("got done=%t err=%v, want done=true err=nil", done, err)
+	}
+	if want := append(first.data(), last.data()...); !bytes.Equal(data, want) {
+		t.Errorf("got reassembled data %v, want %v", data, want)
+	}
+}
@@ -100,11 +100,12 @@ func (r *reassembler) process(first, last uint16, more bool, proto uint8, pkt st
 			}
 		}
 
-		holeFound = true
 		if currentHole.filled {
-			// Incoming fragment is a duplicate.
-			continue
+			// Incoming fragment is a duplicate. Holes don't overlap, so
+			// no other hole can hold any part of it.
+			return nil, 0, false, 0, ErrFragmentDuplicate
 		}
+		holeFound = true
 
 		// We are populating the current hole with the payload and creating a new
 		// hole for any unfilled ranges on either end.
@@ -181,3 +182,28 @@ func (r *reassembler) checkDoneOrMark() bool {
 	r.mu.Unlock()
 	return prev
 }
+
+func (r *reassembler) isDone() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.done
+}
+
+// finalReceived returns whether the final fragment has been received.
+func (r *reassembler) finalReceived() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, h := range r.holes {
+		if h.filled && h.final {
+			return true
+		}
+	}
+	return false
+}
+
+// fragments returns the number of fragments received.
+func (r *reassembler) fragments() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.filled
+}
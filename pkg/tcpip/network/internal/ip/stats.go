@@ -142,6 +142,18 @@ type MultiCounterIPStats struct {
 	// dropped due to the fragment failing validation checks.
 	MalformedFragmentsReceived tcpip.MultiCounterStat
 
+	// DuplicateFragmentsReceived is the number of IP Fragments that were
+	// dropped because their payload had already been received.
+	DuplicateFragmentsReceived tcpip.MultiCounterStat
+
+	// PacketsReassembled is the number of IP packets that were reassembled
+	// from fragments.
+	PacketsReassembled tcpip.MultiCounterStat
+
+	// FragmentsReassembled is the number of IP Fragments that were combined
+	// into reassembled packets.
+	FragmentsReassembled tcpip.MultiCounterStat
+
 	// IPTablesPreroutingDropped is the number of IP packets dropped in the
 	// Prerouting chain.
 	IPTablesPreroutingDropped tcpip.MultiCounterStat
@@ -176,6 +188,10 @@ type MultiCounterIPStats struct {
 	// seen.
 	OptionRouterAlertReceived tcpip.MultiCounterStat
 
+	// OptionJumboPayloadReceived is the number of IPv6 Jumbo Payload options
+	// seen.
+	OptionJumboPayloadReceived tcpip.MultiCounterStat
+
 	// OptionUnknownReceived is the number of unknown IP options seen.
 	OptionUnknownReceived tcpip.MultiCounterStat
 
@@ -195,6 +211,9 @@ func (m *MultiCounterIPStats) Init(a, b *tcpip.IPStats) {
 	m.OutgoingPacketErrors.Init(a.OutgoingPacketErrors, b.OutgoingPacketErrors)
 	m.MalformedPacketsReceived.Init(a.MalformedPacketsReceived, b.MalformedPacketsReceived)
 	m.MalformedFragmentsReceived.Init(a.MalformedFragmentsReceived, b.MalformedFragmentsReceived)
+	m.DuplicateFragmentsReceived.Init(a.DuplicateFragmentsReceived, b.DuplicateFragmentsReceived)
+	m.PacketsReassembled.Init(a.PacketsReassembled, b.PacketsReassembled)
+	m.FragmentsReassembled.Init(a.FragmentsReassembled, b.FragmentsReassembled)
 	m.IPTablesPreroutingDropped.Init(a.IPTablesPreroutingDropped, b.IPTablesPreroutingDropped)
 	m.IPTablesInputDropped.Init(a.IPTablesInputDropped, b.IPTablesInputDropped)
 	m.IPTablesForwardDropped.Init(a.IPTablesForwardDropped, b.IPTablesForwardDropped)
@@ -203,6 +222,7 @@ func (m *MultiCounterIPStats) Init(a, b *tcpip.IPStats) {
 	m.OptionTimestampReceived.Init(a.OptionTimestampReceived, b.OptionTimestampReceived)
 	m.OptionRecordRouteReceived.Init(a.OptionRecordRouteReceived, b.OptionRecordRouteReceived)
 	m.OptionRouterAlertReceived.Init(a.OptionRouterAlertReceived, b.OptionRouterAlertReceived)
+	m.OptionJumboPayloadReceived.Init(a.OptionJumboPayloadReceived, b.OptionJumboPayloadReceived)
 	m.OptionUnknownReceived.Init(a.OptionUnknownReceived, b.OptionUnknownReceived)
 	m.Forwarding.Init(&a.Forwarding, &b.Forwarding)
 }
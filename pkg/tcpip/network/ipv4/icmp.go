@@ -16,6 +16,7 @@ package ipv4
 
 import (
 	"fmt"
+	"time"
 
 	"gvisor.dev/gvisor/pkg/buffer"
 	"gvisor.dev/gvisor/pkg/tcpip"
@@ -510,6 +511,35 @@ func (e *endpoint) handleICMP(pkt stack.PacketBufferPtr) {
 	case header.ICMPv4Timestamp:
 		received.timestamp.Increment()
 
+		// The timestamps follow the ICMP header in the packet's payload.
+		// Save what is needed for the reply before pkt is delivered, as
+		// DeliverTransportPacket may modify it.
+		var request [header.ICMPv4TimestampSize]byte
+		requestData := stack.PayloadSince(pkt.TransportHeader())
+		n := copy(request[:], requestData.AsSlice())
+		requestData.Release()
+		if n < header.ICMPv4TimestampSize {
+			received.invalid.Increment()
+			return
+		}
+		ipHdr := header.IPv4(pkt.NetworkHeader().Slice())
+		localAddr := ipHdr.DestinationAddress()
+		remoteAddr := ipHdr.SourceAddress()
+		localAddressBroadcast := pkt.NetworkPacketInfo.LocalAddressBroadcast
+
+		// It's possible that a raw socket expects to receive this.
+		e.dispatcher.DeliverTransportPacket(header.ICMPv4ProtocolNumber, pkt)
+		pkt = nil
+
+		if !e.protocol.options.AllowICMPTimestampReplies {
+			return
+		}
+
+		if localAddressBroadcast || header.IsV4MulticastAddress(localAddr) {
+			localAddr = tcpip.Address{}
+		}
+		e.sendTimestampReply(header.ICMPv4(request[:]), localAddr, remoteAddr)
+
 	case header.ICMPv4TimestampReply:
 		received.timestampReply.Increment()
 
@@ -524,6 +554,64 @@ func (e *endpoint) handleICMP(pkt stack.PacketBufferPtr) {
 	}
 }
 
+// icmpTimestamp returns t as an ICMP timestamp: the number of milliseconds
+// since midnight UT, as per RFC 792 page 16.
+func icmpTimestamp(t time.Time) uint32 {
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return uint32(t.Sub(midnight).Milliseconds())
+}
+
+// sendTimestampReply sends an ICMP Timestamp Reply in response to the
+// Timestamp request held in request.
+func (e *endpoint) sendTimestampReply(request header.ICMPv4, localAddr, remoteAddr tcpip.Address) {
+	sent := e.stats.icmp.packetsSent
+	if !e.protocol.allowICMPReply(header.ICMPv4TimestampReply, header.ICMPv4UnusedCode) {
+		sent.rateLimited.Increment()
+		return
+	}
+
+	r, err := e.protocol.stack.FindRoute(e.nic.ID(), localAddr, remoteAddr, ProtocolNumber, false /* multicastLoop */)
+	if err != nil {
+		// If we cannot find a route to the destination, silently drop the packet.
+		return
+	}
+	defer r.Release()
+
+	// We don't distinguish between the time the request was received and the
+	// time the reply is sent.
+	now := icmpTimestamp(e.protocol.stack.Clock().Now())
+
+	replyPkt := stack.NewPacketBuffer(stack.PacketBufferOptions{
+		ReserveHeaderBytes: int(r.MaxHeaderLength()) + header.ICMPv4TimestampSize,
+	})
+	defer replyPkt.DecRef()
+	replyPkt.TransportProtocolNumber = header.ICMPv4ProtocolNumber
+
+	// As per RFC 792 page 16, the identifier, sequence number and originate
+	// timestamp are copied from the request.
+	reply := header.ICMPv4(replyPkt.TransportHeader().Push(header.ICMPv4TimestampSize))
+	copy(reply, request)
+	reply.SetType(header.ICMPv4TimestampReply)
+	reply.SetCode(header.ICMPv4UnusedCode)
+	reply.SetReceiveTimestamp(now)
+	reply.SetTransmitTimestamp(now)
+	reply.SetChecksum(header.ICMPv4Checksum(reply, 0 /* payloadCsum */))
+
+	if err := r.WritePacket(
+		stack.NetworkHeaderParams{
+			Protocol: header.ICMPv4ProtocolNumber,
+			TTL:      r.DefaultTTL(),
+			TOS:      stack.DefaultTOS,
+		},
+		replyPkt,
+	); err != nil {
+		sent.dropped.Increment()
+		return
+	}
+	sent.timestampReply.Increment()
+}
+
 // ======= ICMP Error packet generation =========
 
 // icmpReason is a marker interface for IPv4 specific ICMP errors.
@@ -566,6 +566,26 @@ func (e *endpoint) writePacketPostRouting(r *stack.Route, pkt stack.PacketBuffer
 		return err
 	}
 
+	if packetMustBeFragmented(pkt, networkMTU) && canResegment(pkt) {
+		// The packet was coalesced by GRO and is being forwarded. Split it back
+		// into segments that fit the MTU rather than fragmenting it.
+		id := header.IPv4(pkt.NetworkHeader().Slice()).ID()
+		segs, ok := stack.SegmentGROPacket(pkt, networkMTU, int(r.MaxHeaderLength()), func(hdr []byte, payloadLen int) {
+			h := header.IPv4(hdr)
+			h.SetTotalLength(uint16(len(hdr) + payloadLen))
+			h.SetID(id)
+			id++
+			h.SetChecksum(0)
+			h.SetChecksum(^h.CalculateChecksum())
+		})
+		if ok {
+			sent, err := stack.WriteGROSegments(e.nic, r, segs)
+			stats.PacketsSent.IncrementBy(uint64(sent))
+			stats.OutgoingPacketErrors.IncrementBy(uint64(len(segs) - sent))
+			return err
+		}
+	}
+
 	if packetMustBeFragmented(pkt, networkMTU) {
 		h := header.IPv4(pkt.NetworkHeader().Slice())
 		if h.Flags()&header.IPv4FlagDontFragment != 0 && pkt.NetworkPacketInfo.IsForwardedPacket {
@@ -1257,6 +1277,10 @@ func (e *endpoint) deliverPacketLocally(h header.IPv4, pkt stack.PacketBufferPtr
 			proto,
 			pkt,
 		)
+		if err == fragmentation.ErrFragmentDuplicate {
+			stats.ip.DuplicateFragmentsReceived.Increment()
+			return
+		}
 		if err != nil {
 			stats.ip.MalformedPacketsReceived.Increment()
 			stats.ip.MalformedFragmentsReceived.Increment()
@@ -1265,6 +1289,8 @@ func (e *endpoint) deliverPacketLocally(h header.IPv4, pkt stack.PacketBufferPtr
 		if !ready {
 			return
 		}
+		stats.ip.PacketsReassembled.Increment()
+		stats.ip.FragmentsReassembled.IncrementBy(uint64(resPkt.ReassembledFragments))
 		defer resPkt.DecRef()
 		pkt = resPkt
 		h = header.IPv4(pkt.NetworkHeader().Slice())
@@ -1549,6 +1575,17 @@ func (p *protocol) SetOption(option tcpip.SettableNetworkProtocolOption) tcpip.E
 	case *tcpip.DefaultTTLOption:
 		p.SetDefaultTTL(uint8(*v))
 		return nil
+	case *tcpip.ICMPRateMaskOption:
+		types := make(map[header.ICMPv4Type]struct{})
+		for i := 0; i <= math.MaxUint8; i++ {
+			if v.Has(uint8(i)) {
+				types[header.ICMPv4Type(i)] = struct{}{}
+			}
+		}
+		p.mu.Lock()
+		p.icmpRateLimitedTypes = types
+		p.mu.Unlock()
+		return nil
 	default:
 		return &tcpip.ErrUnknownProtocolOption{}
 	}
@@ -1560,6 +1597,14 @@ func (p *protocol) Option(option tcpip.GettableNetworkProtocolOption) tcpip.Erro
 	case *tcpip.DefaultTTLOption:
 		*v = tcpip.DefaultTTLOption(p.DefaultTTL())
 		return nil
+	case *tcpip.ICMPRateMaskOption:
+		*v = tcpip.ICMPRateMaskOption{}
+		p.mu.RLock()
+		for t := range p.icmpRateLimitedTypes {
+			v.Set(uint8(t))
+		}
+		p.mu.RUnlock()
+		return nil
 	default:
 		return &tcpip.ErrUnknownProtocolOption{}
 	}
@@ -1877,6 +1922,11 @@ func packetMustBeFragmented(pkt stack.PacketBufferPtr, networkMTU uint32) bool {
 	return pkt.GSOOptions.Type == stack.GSONone && uint32(payload) > networkMTU
 }
 
+// canResegment returns whether pkt is a forwarded TCP packet coalesced by GRO.
+func canResegment(pkt stack.PacketBufferPtr) bool {
+	return pkt.GROSegmentSize != 0 && pkt.NetworkPacketInfo.IsForwardedPacket
+}
+
 // addressToUint32 translates an IPv4 address into its little endian uint32
 // representation.
 //
@@ -1906,6 +1956,11 @@ type Options struct {
 	// AllowExternalLoopbackTraffic indicates that inbound loopback packets (i.e.
 	// martian loopback packets) should be accepted.
 	AllowExternalLoopbackTraffic bool
+
+	// AllowICMPTimestampReplies indicates that ICMP Timestamp requests should
+	// be answered with Timestamp Replies. Timestamp replies leak the host's
+	// clock, so they are disabled by default.
+	AllowICMPTimestampReplies bool
 }
 
 // NewProtocolWithOptions returns an IPv4 network protocol.
@@ -0,0 +1,332 @@
+// Copyright 2023 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipv4_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/buffer"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/checksum"
+	"gvisor.dev/gvisor/pkg/tcpip/faketime"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"gvisor.dev/gvisor/pkg/tcpip/link/channel"
+	"gvisor.dev/gvisor/pkg/tcpip/network/ipv4"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+	"gvisor.dev/gvisor/pkg/tcpip/transport/tcp"
+)
+
+const (
+	incomingNICID = 1
+	outgoingNICID = 2
+)
+
+var (
+	incomingAddr = tcpip.AddrFrom4([4]byte{10, 0, 0, 1})
+	outgoingAddr = tcpip.AddrFrom4([4]byte{10, 0, 1, 1})
+	remoteSrc    = tcpip.AddrFrom4([4]byte{10, 0, 0, 2})
+	remoteDst    = tcpip.AddrFrom4([4]byte{10, 0, 1, 2})
+)
+
+// TestForwardGROPacketResegmented checks that a packet coalesced by GRO that
+// doesn't fit the egress MTU is forwarded as TCP segments of its original size
+// rather than as IP fragments.
+func TestForwardGROPacketResegmented(t *testing.T) {
+	const (
+		egressMTU   = 1500
+		segmentSize = 1000
+		segments    = 3
+		seqNum      = 12345
+	)
+
+	s := stack.New(stack.Options{
+		NetworkProtocols:   []stack.NetworkProtocolFactory{ipv4.NewProtocol},
+		TransportProtocols: []stack.TransportProtocolFactory{tcp.NewProtocol},
+	})
+	defer s.Close()
+
+	incoming := channel.New(1, egressMTU*segments, "")
+	outgoing := channel.New(segments+1, egressMTU, "")
+	if err := s.CreateNIC(incomingNICID, incoming); err != nil {
+		t.Fatalf("s.CreateNIC(%d, _): %s", incomingNICID, err)
+	}
+	if err := s.CreateNIC(outgoingNICID, outgoing); err != nil {
+		t.Fatalf("s.CreateNIC(%d, _): %s", outgoingNICID, err)
+	}
+	for nicID, addr := range map[tcpip.NICID]tcpip.Address{incomingNICID: incomingAddr, outgoingNICID: outgoingAddr} {
+		protoAddr := tcpip.ProtocolAddress{
+			Protocol:          header.IPv4ProtocolNumber,
+			AddressWithPrefix: tcpip.AddressWithPrefix{Address: addr, PrefixLen: 24},
+		}
+		if err := s.AddProtocolAddress(nicID, protoAddr, stack.AddressProperties{}); err != nil {
+			t.Fatalf("s.AddProtocolAddress(%d, %+v, {}): %s", nicID, protoAddr, err)
+		}
+	}
+	s.SetRouteTable([]tcpip.Route{
+		{Destination: tcpip.AddressWithPrefix{Address: incomingAddr, PrefixLen: 24}.Subnet(), NIC: incomingNICID},
+		{Destination: tcpip.AddressWithPrefix{Address: outgoingAddr, PrefixLen: 24}.Subnet(), NIC: outgoingNICID},
+	})
+	if err := s.SetForwardingDefaultAndAllNICs(header.IPv4ProtocolNumber, true); err != nil {
+		t.Fatalf("s.SetForwardingDefaultAndAllNICs(%d, true): %s", header.IPv4ProtocolNumber, err)
+	}
+
+	payload := make([]byte, segmentSize*segments)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	hdr := make([]byte, header.IPv4MinimumSize+header.TCPMinimumSize)
+	tcpHdr := header.TCP(hdr[header.IPv4MinimumSize:])
+	tcpHdr.Encode(&header.TCPFields{
+		SrcPort:    1000,
+		DstPort:    2000,
+		SeqNum:     seqNum,
+		DataOffset: header.TCPMinimumSize,
+		Flags:      header.TCPFlagAck | header.TCPFlagPsh,
+		WindowSize: 65535,
+	})
+	xsum := header.PseudoHeaderChecksum(header.TCPProtocolNumber, remoteSrc, remoteDst, uint16(header.TCPMinimumSize+len(payload)))
+	xsum = checksum.Checksum(payload, xsum)
+	tcpHdr.SetChecksum(^tcpHdr.CalculateChecksum(xsum))
+	ipHdr := header.IPv4(hdr)
+	ipHdr.Encode(&header.IPv4Fields{
+		TotalLength: uint16(len(hdr) + len(payload)),
+		TTL:         64,
+		Protocol:    uint8(header.TCPProtocolNumber),
+		SrcAddr:     remoteSrc,
+		DstAddr:     remoteDst,
+	})
+	ipHdr.SetChecksum(^ipHdr.CalculateChecksum())
+
+	buf := buffer.MakeWithData(hdr)
+	buf.Append(buffer.NewViewWithData(payload))
+	pkt := stack.NewPacketBuffer(stack.PacketBufferOptions{Payload: buf})
+	// Mark the packet as if GRO had coalesced it from segments of
+	// segmentSize bytes.
+	pkt.GROSegmentSize = segmentSize
+	incoming.InjectInbound(header.IPv4ProtocolNumber, pkt)
+	pkt.DecRef()
+
+	var got []byte
+	nextSeq := uint32(seqNum)
+	for i := 0; i < segments; i++ {
+		seg := outgoing.Read()
+		if seg.IsNil() {
+			t.Fatalf("got %d forwarded packets, want %d", i, segments)
+		}
+		b := seg.ToView().AsSlice()
+		seg.DecRef()
+
+		if len(b) > egressMTU {
+			t.Errorf("segment %d is %d bytes, larger than the egress MTU of %d", i, len(b), egressMTU)
+		}
+		ip := header.IPv4(b)
+		if !ip.IsValid(len(b)) || !ip.IsChecksumValid() {
+			t.Fatalf("segment %d has an invalid IPv4 header", i)
+		}
+		if ip.More() || ip.FragmentOffset() != 0 {
+			t.Errorf("segment %d is an IP fragment", i)
+		}
+		if got, want := ip.TTL(), uint8(63); got != want {
+			t.Errorf("segment %d has TTL %d, want %d", i, got, want)
+		}
+		tcpSeg := header.TCP(ip.Payload())
+		segPayload := tcpSeg.Payload()
+		if got, want := len(segPayload), segmentSize; got != want {
+			t.Errorf("segment %d carries %d bytes, want %d", i, got, want)
+		}
+		if got := tcpSeg.SequenceNumber(); got != nextSeq {
+			t.Errorf("segment %d has sequence number %d, want %d", i, got, nextSeq)
+		}
+		if !tcpSeg.IsChecksumValid(ip.SourceAddress(), ip.DestinationAddress(), checksum.Checksum(segPayload, 0), uint16(len(segPayload))) {
+			t.Errorf("segment %d has an invalid TCP checksum", i)
+		}
+		wantPsh := i == segments-1
+		if got := tcpSeg.Flags().Contains(header.TCPFlagPsh); got != wantPsh {
+			t.Errorf("segment %d has PSH=%t, want %t", i, got, wantPsh)
+		}
+		nextSeq += uint32(len(segPayload))
+		got = append(got, segPayload...)
+	}
+	if n := outgoing.Drain(); n != 0 {
+		t.Errorf("got %d extra forwarded packets", n)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Errorf("forwarded payload doesn't match the original")
+	}
+	if got := s.Stats().IP.PacketsSent.Value(); got != segments {
+		t.Errorf("got s.Stats().IP.PacketsSent.Value() = %d, want %d", got, segments)
+	}
+}
+
+// newICMPTestStack returns a stack with a single NIC that replies to ICMP
+// requests from remoteSrc. If clock is nil, the stack uses a real clock.
+func newICMPTestStack(t *testing.T, opts ipv4.Options, clock tcpip.Clock) (*stack.Stack, *channel.Endpoint) {
+	t.Helper()
+	s := stack.New(stack.Options{
+		NetworkProtocols: []stack.NetworkProtocolFactory{ipv4.NewProtocolWithOptions(opts)},
+		Clock:            clock,
+	})
+	t.Cleanup(s.Close)
+	e := channel.New(4, header.IPv4MinimumMTU, "")
+	if err := s.CreateNIC(incomingNICID, e); err != nil {
+		t.Fatalf("s.CreateNIC(%d, _): %s", incomingNICID, err)
+	}
+	protoAddr := tcpip.ProtocolAddress{
+		Protocol:          header.IPv4ProtocolNumber,
+		AddressWithPrefix: tcpip.AddressWithPrefix{Address: incomingAddr, PrefixLen: 24},
+	}
+	if err := s.AddProtocolAddress(incomingNICID, protoAddr, stack.AddressProperties{}); err != nil {
+		t.Fatalf("s.AddProtocolAddress(%d, %+v, {}): %s", incomingNICID, protoAddr, err)
+	}
+	s.SetRouteTable([]tcpip.Route{{Destination: header.IPv4EmptySubnet, NIC: incomingNICID}})
+	return s, e
+}
+
+// injectICMP delivers an IPv4 packet carrying icmp from remoteSrc to
+// incomingAddr. The ICMP checksum is filled in.
+func injectICMP(e *channel.Endpoint, icmp header.ICMPv4) {
+	icmp.SetChecksum(0)
+	icmp.SetChecksum(header.ICMPv4Checksum(icmp, 0 /* payloadCsum */))
+	hdr := make([]byte, header.IPv4MinimumSize)
+	ip := header.IPv4(hdr)
+	ip.Encode(&header.IPv4Fields{
+		TotalLength: uint16(len(hdr) + len(icmp)),
+		TTL:         64,
+		Protocol:    uint8(header.ICMPv4ProtocolNumber),
+		SrcAddr:     remoteSrc,
+		DstAddr:     incomingAddr,
+	})
+	ip.SetChecksum(^ip.CalculateChecksum())
+	buf := buffer.MakeWithData(hdr)
+	buf.Append(buffer.NewViewWithData(icmp))
+	pkt := stack.NewPacketBuffer(stack.PacketBufferOptions{Payload: buf})
+	e.InjectInbound(header.IPv4ProtocolNumber, pkt)
+	pkt.DecRef()
+}
+
+// readICMP returns the ICMP message of the next packet written by the stack,
+// or nil if there is none.
+func readICMP(e *channel.Endpoint) header.ICMPv4 {
+	pkt := e.Read()
+	if pkt.IsNil() {
+		return nil
+	}
+	defer pkt.DecRef()
+	return header.ICMPv4(header.IPv4(pkt.ToView().AsSlice()).Payload())
+}
+
+func TestICMPRateMask(t *testing.T) {
+	s, e := newICMPTestStack(t, ipv4.Options{}, nil /* clock */)
+	// Every rate limited message is dropped.
+	s.SetICMPLimit(0)
+	s.SetICMPBurst(0)
+
+	echo := func() header.ICMPv4 {
+		req := header.ICMPv4(make([]byte, header.ICMPv4MinimumSize))
+		req.SetType(header.ICMPv4Echo)
+		injectICMP(e, req)
+		return readICMP(e)
+	}
+
+	// Echo replies aren't rate limited by default.
+	if reply := echo(); reply == nil || reply.Type() != header.ICMPv4EchoReply {
+		t.Fatalf("got reply %x to an echo request, want an echo reply", []byte(reply))
+	}
+
+	var mask tcpip.ICMPRateMaskOption
+	mask.Set(uint8(header.ICMPv4EchoReply))
+	if err := s.SetNetworkProtocolOption(header.IPv4ProtocolNumber, &mask); err != nil {
+		t.Fatalf("s.SetNetworkProtocolOption(%d, &%v): %s", header.IPv4ProtocolNumber, mask, err)
+	}
+	var got tcpip.ICMPRateMaskOption
+	if err := s.NetworkProtocolOption(header.IPv4ProtocolNumber, &got); err != nil {
+		t.Fatalf("s.NetworkProtocolOption(%d, _): %s", header.IPv4ProtocolNumber, err)
+	}
+	if got != mask {
+		t.Errorf("got rate mask %v, want %v", got, mask)
+	}
+	rateLimited := s.Stats().ICMP.V4.PacketsSent.RateLimited.Value()
+	if reply := echo(); reply != nil {
+		t.Errorf("got reply %x to an echo request with echo replies rate limited, want none", []byte(reply))
+	}
+	if got, want := s.Stats().ICMP.V4.PacketsSent.RateLimited.Value(), rateLimited+1; got != want {
+		t.Errorf("got RateLimited = %d, want %d", got, want)
+	}
+}
+
+func TestICMPTimestampReply(t *testing.T) {
+	const (
+		ident      = 1
+		seq        = 2
+		originate  = 3
+		sinceEpoch = 1500 * time.Millisecond
+	)
+	request := func(size int) header.ICMPv4 {
+		req := header.ICMPv4(make([]byte, size))
+		req.SetType(header.ICMPv4Timestamp)
+		req.SetIdent(ident)
+		req.SetSequence(seq)
+		if size >= header.ICMPv4TimestampSize {
+			req.SetOriginateTimestamp(originate)
+		}
+		return req
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		_, e := newICMPTestStack(t, ipv4.Options{}, nil /* clock */)
+		injectICMP(e, request(header.ICMPv4TimestampSize))
+		if reply := readICMP(e); reply != nil {
+			t.Errorf("got reply %x with timestamp replies disabled, want none", []byte(reply))
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		clock := faketime.NewManualClock()
+		clock.Advance(sinceEpoch)
+		s, e := newICMPTestStack(t, ipv4.Options{AllowICMPTimestampReplies: true}, clock)
+
+		injectICMP(e, request(header.ICMPv4TimestampSize))
+		reply := readICMP(e)
+		if reply == nil {
+			t.Fatalf("got no reply to a timestamp request")
+		}
+		if len(reply) != header.ICMPv4TimestampSize || reply.Type() != header.ICMPv4TimestampReply {
+			t.Fatalf("got reply %x, want a %d byte timestamp reply", []byte(reply), header.ICMPv4TimestampSize)
+		}
+		if reply.Ident() != ident || reply.Sequence() != seq || reply.OriginateTimestamp() != originate {
+			t.Errorf("got (ident, seq, originate) = (%d, %d, %d), want (%d, %d, %d)", reply.Ident(), reply.Sequence(), reply.OriginateTimestamp(), ident, seq, originate)
+		}
+		// The clock starts at midnight UT.
+		want := uint32(sinceEpoch.Milliseconds())
+		if reply.ReceiveTimestamp() != want || reply.TransmitTimestamp() != want {
+			t.Errorf("got (receive, transmit) = (%d, %d), want (%d, %d)", reply.ReceiveTimestamp(), reply.TransmitTimestamp(), want, want)
+		}
+		if got := checksum.Checksum(reply, 0); got != 0xffff {
+			t.Errorf("got reply checksum sum %#x, want %#x", got, 0xffff)
+		}
+
+		// Truncated requests are invalid.
+		invalid := s.Stats().ICMP.V4.PacketsReceived.Invalid.Value()
+		injectICMP(e, request(header.ICMPv4MinimumSize))
+		if reply := readICMP(e); reply != nil {
+			t.Errorf("got reply %x to a truncated timestamp request, want none", []byte(reply))
+		}
+		if got, want := s.Stats().ICMP.V4.PacketsReceived.Invalid.Value(), invalid+1; got != want {
+			t.Errorf("got Invalid = %d, want %d", got, want)
+		}
+	})
+}
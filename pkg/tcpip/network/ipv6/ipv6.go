@@ -748,6 +748,11 @@ func packetMustBeFragmented(pkt stack.PacketBufferPtr, networkMTU uint32) bool {
 	return pkt.GSOOptions.Type == stack.GSONone && uint32(payload) > networkMTU
 }
 
+// canResegment returns whether pkt is a forwarded TCP packet coalesced by GRO.
+func canResegment(pkt stack.PacketBufferPtr) bool {
+	return pkt.GROSegmentSize != 0 && pkt.NetworkPacketInfo.IsForwardedPacket
+}
+
 // handleFragments fragments pkt and calls the handler function on each
 // fragment. It returns the number of fragments handled and the number of
 // fragments left to be processed. The IP header must already be present in the
@@ -856,6 +861,20 @@ func (e *endpoint) writePacket(r *stack.Route, pkt stack.PacketBufferPtr, protoc
 		return err
 	}
 
+	if packetMustBeFragmented(pkt, networkMTU) && canResegment(pkt) {
+		// The packet was coalesced by GRO and is being forwarded. Split it back
+		// into segments that fit the MTU.
+		segs, ok := stack.SegmentGROPacket(pkt, networkMTU, int(r.MaxHeaderLength()), func(hdr []byte, payloadLen int) {
+			header.IPv6(hdr).SetPayloadLength(uint16(len(hdr) - header.IPv6MinimumSize + payloadLen))
+		})
+		if ok {
+			sent, err := stack.WriteGROSegments(e.nic, r, segs)
+			stats.PacketsSent.IncrementBy(uint64(sent))
+			stats.OutgoingPacketErrors.IncrementBy(uint64(len(segs) - sent))
+			return err
+		}
+	}
+
 	if packetMustBeFragmented(pkt, networkMTU) {
 		if pkt.NetworkPacketInfo.IsForwardedPacket {
 			// As per RFC 2460, section 4.5:
@@ -1706,6 +1725,17 @@ func (e *endpoint) processIPv6HopByHopOptionsExtHdr(extHdr *header.IPv6HopByHopO
 			*routerAlert = opt
 			stats.OptionRouterAlertReceived.Increment()
 		default:
+			// Jumbograms are not supported, so their payload length is never
+			// used and reassembly never has to account for payloads larger
+			// than 65,535 bytes. As per RFC 2675 section 3, the Jumbo Payload
+			// option's identifier makes us discard the packet and send an
+			// ICMP Parameter Problem, Code 2, message unless the destination
+			// is multicast.
+			if uo, ok := opt.(*header.IPv6UnknownExtHdrOption); ok && uo.Identifier == header.IPv6JumboPayloadHopByHopOptionIdentifier {
+				stats.OptionJumboPayloadReceived.Increment()
+			} else {
+				stats.OptionUnknownReceived.Increment()
+			}
 			switch opt.UnknownAction() {
 			case header.IPv6OptionUnknownActionSkip:
 			case header.IPv6OptionUnknownActionDiscard:
@@ -1833,6 +1863,9 @@ func (e *endpoint) processFragmentExtHdr(extHdr *header.IPv6FragmentExtHdr, it *
 	//    Parameter Problem, Code 0, message should be sent to the source of
 	//    the fragment, pointing to the Fragment Offset field of the fragment
 	//    packet.
+	//
+	// Jumbograms are rejected while processing the Hop-by-Hop header, so this
+	// bound applies to every reassembled packet.
 	lengthAfterReassembly := int(start) + int(fragmentPayloadLen)
 	if lengthAfterReassembly > header.IPv6MaximumPayloadSize {
 		stats.MalformedPacketsReceived.Increment()
@@ -1844,6 +1877,16 @@ func (e *endpoint) processFragmentExtHdr(extHdr *header.IPv6FragmentExtHdr, it *
 		return fmt.Errorf("determined that reassembled packet length = %d would exceed allowed length = %d", lengthAfterReassembly, header.IPv6MaximumPayloadSize)
 	}
 
+	// Fragments of packets destined to link-scoped addresses are only
+	// reassembled with fragments received on the same NIC. Like Linux, other
+	// packets are reassembled regardless of the NIC their fragments arrive on
+	// so that traffic spread across multiple NICs (e.g. ECMP or bonding) can
+	// still be reassembled.
+	var fragNIC tcpip.NICID
+	if dst := h.DestinationAddress(); header.IsV6LinkLocalUnicastAddress(dst) || header.IsV6MulticastAddress(dst) {
+		fragNIC = e.nic.ID()
+	}
+
 	// Note that pkt doesn't have its transport header set after reassembly,
 	// and won't until DeliverNetworkPacket sets it.
 	resPkt, proto, ready, err := e.protocol.fragmentation.Process(
@@ -1853,6 +1896,7 @@ func (e *endpoint) processFragmentExtHdr(extHdr *header.IPv6FragmentExtHdr, it *
 			Source:      h.SourceAddress(),
 			Destination: h.DestinationAddress(),
 			ID:          extHdr.ID(),
+			NIC:         fragNIC,
 		},
 		start,
 		start+uint16(fragmentPayloadLen)-1,
@@ -1860,6 +1904,10 @@ func (e *endpoint) processFragmentExtHdr(extHdr *header.IPv6FragmentExtHdr, it *
 		uint8(rawPayload.Identifier),
 		*pkt,
 	)
+	if err == fragmentation.ErrFragmentDuplicate {
+		stats.DuplicateFragmentsReceived.Increment()
+		return err
+	}
 	if err != nil {
 		stats.MalformedPacketsReceived.Increment()
 		stats.MalformedFragmentsReceived.Increment()
@@ -1867,6 +1915,9 @@ func (e *endpoint) processFragmentExtHdr(extHdr *header.IPv6FragmentExtHdr, it *
 	}
 
 	if ready {
+		stats.PacketsReassembled.Increment()
+		stats.FragmentsReassembled.IncrementBy(uint64(resPkt.ReassembledFragments))
+
 		// We create a new iterator with the reassembled packet because we could
 		// have more extension headers in the reassembled payload, as per RFC
 		// 8200 section 4.5. We also use the NextHeader value from the first
@@ -2401,6 +2452,17 @@ func (p *protocol) SetOption(option tcpip.SettableNetworkProtocolOption) tcpip.E
 	case *tcpip.DefaultTTLOption:
 		p.SetDefaultTTL(uint8(*v))
 		return nil
+	case *tcpip.ICMPRateMaskOption:
+		types := make(map[header.ICMPv6Type]struct{})
+		for i := 0; i <= math.MaxUint8; i++ {
+			if v.Has(uint8(i)) {
+				types[header.ICMPv6Type(i)] = struct{}{}
+			}
+		}
+		p.mu.Lock()
+		p.mu.icmpRateLimitedTypes = types
+		p.mu.Unlock()
+		return nil
 	default:
 		return &tcpip.ErrUnknownProtocolOption{}
 	}
@@ -2412,6 +2474,14 @@ func (p *protocol) Option(option tcpip.GettableNetworkProtocolOption) tcpip.Erro
 	case *tcpip.DefaultTTLOption:
 		*v = tcpip.DefaultTTLOption(p.DefaultTTL())
 		return nil
+	case *tcpip.ICMPRateMaskOption:
+		*v = tcpip.ICMPRateMaskOption{}
+		p.mu.RLock()
+		for t := range p.mu.icmpRateLimitedTypes {
+			v.Set(uint8(t))
+		}
+		p.mu.RUnlock()
+		return nil
 	default:
 		return &tcpip.ErrUnknownProtocolOption{}
 	}
@@ -0,0 +1,265 @@
+// Copyright 2023 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipv6_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"gvisor.dev/gvisor/pkg/buffer"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"gvisor.dev/gvisor/pkg/tcpip/link/channel"
+	"gvisor.dev/gvisor/pkg/tcpip/network/ipv6"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+)
+
+const nicID = 1
+
+var (
+	localAddr  = tcpip.AddrFrom16([16]byte{0x20, 0x01, 0x0d, 0xb8, 15: 1})
+	remoteAddr = tcpip.AddrFrom16([16]byte{0x20, 0x01, 0x0d, 0xb8, 15: 2})
+)
+
+// jumboFragment returns a fragment whose Hop-by-Hop header carries a Jumbo
+// Payload option.
+func jumboFragment(offset uint16, more bool, payload []byte) []byte {
+	const (
+		hopByHopLen = 8
+		fragmentLen = header.IPv6FragmentHeaderSize
+	)
+	b := make([]byte, header.IPv6MinimumSize+hopByHopLen+fragmentLen+len(payload))
+	header.IPv6(b).Encode(&header.IPv6Fields{
+		PayloadLength:     uint16(len(b) - header.IPv6MinimumSize),
+		TransportProtocol: tcpip.TransportProtocolNumber(header.IPv6HopByHopOptionsExtHdrIdentifier),
+		HopLimit:          64,
+		SrcAddr:           remoteAddr,
+		DstAddr:           localAddr,
+	})
+	hbh := b[header.IPv6MinimumSize:]
+	hbh[0] = uint8(header.IPv6FragmentExtHdrIdentifier)
+	hbh[1] = 0 // Length in 8-byte units, not including the first 8 bytes.
+	hbh[2] = uint8(header.IPv6JumboPayloadHopByHopOptionIdentifier)
+	hbh[3] = 4
+	binary.BigEndian.PutUint32(hbh[4:], 1<<16+uint32(len(payload)))
+	frag := hbh[hopByHopLen:]
+	frag[0] = uint8(header.UDPProtocolNumber)
+	offsetAndFlags := offset
+	if more {
+		offsetAndFlags |= 1
+	}
+	binary.BigEndian.PutUint16(frag[2:], offsetAndFlags)
+	binary.BigEndian.PutUint32(frag[4:], 1 /* id */)
+	copy(frag[fragmentLen:], payload)
+	return b
+}
+
+func TestJumboPayloadFragmentsRejected(t *testing.T) {
+	s := stack.New(stack.Options{
+		NetworkProtocols: []stack.NetworkProtocolFactory{ipv6.NewProtocol},
+	})
+	defer s.Close()
+	e := channel.New(4, header.IPv6MinimumMTU, "")
+	if err := s.CreateNIC(nicID, e); err != nil {
+		t.Fatalf("s.CreateNIC(%d, _): %s", nicID, err)
+	}
+	protoAddr := tcpip.ProtocolAddress{
+		Protocol:          header.IPv6ProtocolNumber,
+		AddressWithPrefix: tcpip.AddressWithPrefix{Address: localAddr, PrefixLen: 64},
+	}
+	if err := s.AddProtocolAddress(nicID, protoAddr, stack.AddressProperties{}); err != nil {
+		t.Fatalf("s.AddProtocolAddress(%d, %+v, {}): %s", nicID, protoAddr, err)
+	}
+	s.SetRouteTable([]tcpip.Route{{Destination: header.IPv6EmptySubnet, NIC: nicID}})
+
+	// The fragment offset field holds the offset in 8-byte units shifted
+	// past the flags, which is the offset in bytes.
+	payload := make([]byte, 8)
+	fragments := [][]byte{
+		jumboFragment(0, true /* more */, payload),
+		jumboFragment(uint16(len(payload)), false /* more */, payload),
+	}
+	stats := s.Stats()
+	unknown := stats.IP.OptionUnknownReceived.Value()
+	for _, frag := range fragments {
+		pkt := stack.NewPacketBuffer(stack.PacketBufferOptions{
+			Payload: buffer.MakeWithData(frag),
+		})
+		e.InjectInbound(header.IPv6ProtocolNumber, pkt)
+		pkt.DecRef()
+	}
+
+	if got, want := stats.IP.OptionJumboPayloadReceived.Value(), uint64(len(fragments)); got != want {
+		t.Errorf("got OptionJumboPayloadReceived = %d, want %d", got, want)
+	}
+	if got := stats.IP.OptionUnknownReceived.Value(); got != unknown {
+		t.Errorf("got OptionUnknownReceived = %d, want %d", got, unknown)
+	}
+	if got := stats.IP.PacketsDelivered.Value(); got != 0 {
+		t.Errorf("got PacketsDelivered = %d, want 0", got)
+	}
+	if got, want := stats.ICMP.V6.PacketsSent.ParamProblem.Value(), uint64(len(fragments)); got != want {
+		t.Errorf("got %d parameter problems sent, want %d", got, want)
+	}
+}
+
+func TestICMPRateMaskOption(t *testing.T) {
+	s := stack.New(stack.Options{
+		NetworkProtocols: []stack.NetworkProtocolFactory{ipv6.NewProtocol},
+	})
+	defer s.Close()
+
+	var mask tcpip.ICMPRateMaskOption
+	for _, typ := range []header.ICMPv6Type{header.ICMPv6EchoReply, header.ICMPv6PacketTooBig} {
+		mask.Set(uint8(typ))
+	}
+	if err := s.SetNetworkProtocolOption(header.IPv6ProtocolNumber, &mask); err != nil {
+		t.Fatalf("s.SetNetworkProtocolOption(%d, &%v): %s", header.IPv6ProtocolNumber, mask, err)
+	}
+	var got tcpip.ICMPRateMaskOption
+	if err := s.NetworkProtocolOption(header.IPv6ProtocolNumber, &got); err != nil {
+		t.Fatalf("s.NetworkProtocolOption(%d, _): %s", header.IPv6ProtocolNumber, err)
+	}
+	if got != mask {
+		t.Errorf("got rate mask %v, want %v", got, mask)
+	}
+}
+
+// fragmentPacket returns a fragment of a UDP packet from src to dst.
+func fragmentPacket(src, dst tcpip.Address, offset uint16, more bool, payload []byte) []byte {
+	const fragmentLen = header.IPv6FragmentHeaderSize
+	b := make([]byte, header.IPv6MinimumSize+fragmentLen+len(payload))
+	header.IPv6(b).Encode(&header.IPv6Fields{
+		PayloadLength:     uint16(len(b) - header.IPv6MinimumSize),
+		TransportProtocol: tcpip.TransportProtocolNumber(header.IPv6FragmentExtHdrIdentifier),
+		HopLimit:          64,
+		SrcAddr:           src,
+		DstAddr:           dst,
+	})
+	frag := b[header.IPv6MinimumSize:]
+	frag[0] = uint8(header.UDPProtocolNumber)
+	offsetAndFlags := offset
+	if more {
+		offsetAndFlags |= 1
+	}
+	binary.BigEndian.PutUint16(frag[2:], offsetAndFlags)
+	binary.BigEndian.PutUint32(frag[4:], 1 /* id */)
+	copy(frag[fragmentLen:], payload)
+	return b
+}
+
+func TestReassemblyAcrossNICs(t *testing.T) {
+	const otherNICID = nicID + 1
+	var (
+		linkLocalAddr       = tcpip.AddrFrom16([16]byte{0xfe, 0x80, 15: 1})
+		remoteLinkLocalAddr = tcpip.AddrFrom16([16]byte{0xfe, 0x80, 15: 2})
+	)
+	for _, tc := range []struct {
+		name        string
+		src, dst    tcpip.Address
+		reassembled bool
+	}{
+		{name: "global", src: remoteAddr, dst: localAddr, reassembled: true},
+		{name: "link-local", src: remoteLinkLocalAddr, dst: linkLocalAddr, reassembled: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := stack.New(stack.Options{
+				NetworkProtocols: []stack.NetworkProtocolFactory{ipv6.NewProtocolWithOptions(ipv6.Options{
+					DADConfigs: stack.DADConfigurations{DupAddrDetectTransmits: 0},
+				})},
+			})
+			defer s.Close()
+			eps := map[tcpip.NICID]*channel.Endpoint{
+				nicID:      channel.New(0, header.IPv6MinimumMTU, ""),
+				otherNICID: channel.New(0, header.IPv6MinimumMTU, ""),
+			}
+			for id, e := range eps {
+				if err := s.CreateNIC(id, e); err != nil {
+					t.Fatalf("s.CreateNIC(%d, _): %s", id, err)
+				}
+				protoAddr := tcpip.ProtocolAddress{
+					Protocol:          header.IPv6ProtocolNumber,
+					AddressWithPrefix: tcpip.AddressWithPrefix{Address: tc.dst, PrefixLen: 64},
+				}
+				if err := s.AddProtocolAddress(id, protoAddr, stack.AddressProperties{}); err != nil {
+					t.Fatalf("s.AddProtocolAddress(%d, %+v, {}): %s", id, protoAddr, err)
+				}
+			}
+
+			// The fragments of the packet arrive on different NICs.
+			payload := make([]byte, 8)
+			for id, frag := range map[tcpip.NICID][]byte{
+				nicID:      fragmentPacket(tc.src, tc.dst, 0, true /* more */, payload),
+				otherNICID: fragmentPacket(tc.src, tc.dst, uint16(len(payload)), false /* more */, payload),
+			} {
+				pkt := stack.NewPacketBuffer(stack.PacketBufferOptions{
+					Payload: buffer.MakeWithData(frag),
+				})
+				eps[id].InjectInbound(header.IPv6ProtocolNumber, pkt)
+				pkt.DecRef()
+			}
+
+			var want uint64
+			if tc.reassembled {
+				want = 1
+			}
+			if got := s.Stats().IP.PacketsReassembled.Value(); got != want {
+				t.Errorf("got PacketsReassembled = %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestDuplicateFragmentsReceived(t *testing.T) {
+	s := stack.New(stack.Options{
+		NetworkProtocols: []stack.NetworkProtocolFactory{ipv6.NewProtocolWithOptions(ipv6.Options{
+			DADConfigs: stack.DADConfigurations{DupAddrDetectTransmits: 0},
+		})},
+	})
+	defer s.Close()
+	e := channel.New(0, header.IPv6MinimumMTU, "")
+	if err := s.CreateNIC(nicID, e); err != nil {
+		t.Fatalf("s.CreateNIC(%d, _): %s", nicID, err)
+	}
+	protoAddr := tcpip.ProtocolAddress{
+		Protocol:          header.IPv6ProtocolNumber,
+		AddressWithPrefix: tcpip.AddressWithPrefix{Address: localAddr, PrefixLen: 64},
+	}
+	if err := s.AddProtocolAddress(nicID, protoAddr, stack.AddressProperties{}); err != nil {
+		t.Fatalf("s.AddProtocolAddress(%d, %+v, {}): %s", nicID, protoAddr, err)
+	}
+
+	payload := make([]byte, 8)
+	first := fragmentPacket(remoteAddr, localAddr, 0, true /* more */, payload)
+	last := fragmentPacket(remoteAddr, localAddr, uint16(len(payload)), false /* more */, payload)
+	for _, frag := range [][]byte{first, first, last} {
+		pkt := stack.NewPacketBuffer(stack.PacketBufferOptions{
+			Payload: buffer.MakeWithData(frag),
+		})
+		e.InjectInbound(header.IPv6ProtocolNumber, pkt)
+		pkt.DecRef()
+	}
+
+	stats := s.Stats().IP
+	if got := stats.DuplicateFragmentsReceived.Value(); got != 1 {
+		t.Errorf("got DuplicateFragmentsReceived = %d, want 1", got)
+	}
+	if got := stats.MalformedFragmentsReceived.Value(); got != 0 {
+		t.Errorf("got MalformedFragmentsReceived = %d, want 0", got)
+	}
+	if got := stats.PacketsReassembled.Value(); got != 1 {
+		t.Errorf("got PacketsReassembled = %d, want 1", got)
+	}
+}
@@ -124,7 +124,7 @@ type StackHandler interface {
 }
 
 // SocketOptions contains all the variables which store values for SOL_SOCKET,
-// SOL_IP, SOL_IPV6 and SOL_TCP level options.
+// SOL_IP, SOL_IPV6, SOL_TCP and SOL_PACKET level options.
 //
 // +stateify savable
 type SocketOptions struct {
@@ -221,6 +221,19 @@ type SocketOptions struct {
 	// passing is enabled for IPv6.
 	ipv6RecvErrEnabled atomicbitops.Uint32
 
+	// ignoreOutgoingEnabled determines whether a packet socket should skip
+	// packets that originate from the local host.
+	ignoreOutgoingEnabled atomicbitops.Uint32
+
+	// qdiscBypassEnabled determines whether a packet socket should write
+	// packets directly to the link endpoint, skipping the NIC's queueing
+	// discipline.
+	qdiscBypassEnabled atomicbitops.Uint32
+
+	// rxqOvflEnabled determines whether the number of packets dropped by the
+	// socket is passed as a control message on receive.
+	rxqOvflEnabled atomicbitops.Uint32
+
 	// errQueue is the per-socket error queue. It is protected by errQueueMu.
 	errQueueMu sync.Mutex `state:"nosave"`
 	errQueue   sockErrorList
@@ -501,6 +514,36 @@ func (so *SocketOptions) SetIPv6RecvError(v bool) {
 	}
 }
 
+// GetIgnoreOutgoing gets value for PACKET_IGNORE_OUTGOING option.
+func (so *SocketOptions) GetIgnoreOutgoing() bool {
+	return so.ignoreOutgoingEnabled.Load() != 0
+}
+
+// SetIgnoreOutgoing sets value for PACKET_IGNORE_OUTGOING option.
+func (so *SocketOptions) SetIgnoreOutgoing(v bool) {
+	storeAtomicBool(&so.ignoreOutgoingEnabled, v)
+}
+
+// GetQdiscBypass gets value for PACKET_QDISC_BYPASS option.
+func (so *SocketOptions) GetQdiscBypass() bool {
+	return so.qdiscBypassEnabled.Load() != 0
+}
+
+// SetQdiscBypass sets value for PACKET_QDISC_BYPASS option.
+func (so *SocketOptions) SetQdiscBypass(v bool) {
+	storeAtomicBool(&so.qdiscBypassEnabled, v)
+}
+
+// GetRxqOvfl gets value for SO_RXQ_OVFL option.
+func (so *SocketOptions) GetRxqOvfl() bool {
+	return so.rxqOvflEnabled.Load() != 0
+}
+
+// SetRxqOvfl sets value for SO_RXQ_OVFL option.
+func (so *SocketOptions) SetRxqOvfl(v bool) {
+	storeAtomicBool(&so.rxqOvflEnabled, v)
+}
+
 // GetLastError gets value for SO_ERROR option.
 func (so *SocketOptions) GetLastError() Error {
 	return so.handler.LastError()
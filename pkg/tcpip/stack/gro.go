@@ -16,12 +16,16 @@ package stack
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
+	"sort"
 	"time"
 
 	"gvisor.dev/gvisor/pkg/atomicbitops"
+	"gvisor.dev/gvisor/pkg/buffer"
 	"gvisor.dev/gvisor/pkg/sync"
 	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/checksum"
 	"gvisor.dev/gvisor/pkg/tcpip/header"
 )
 
@@ -39,16 +43,26 @@ import (
 // groDispatchers with link endpoint dispatchers.
 
 const (
-	// groNBuckets is the number of GRO buckets.
-	groNBuckets = 8
+	// groDefaultNBuckets is the default number of GRO buckets.
+	groDefaultNBuckets = 8
 
-	groNBucketsMask = groNBuckets - 1
+	// groMaxNBuckets is the maximum number of GRO buckets.
+	groMaxNBuckets = 1 << 12
 
 	// groBucketSize is the size of each GRO bucket.
 	groBucketSize = 8
 
 	// groMaxPacketSize is the maximum size of a GRO'd packet.
 	groMaxPacketSize = 1 << 16 // 65KB.
+
+	// groDefaultMaxSegments is the default maximum number of segments
+	// coalesced into a GRO'd packet. Linux uses the same value.
+	groDefaultMaxSegments = 64
+
+	// groDefaultMaxHeldBytes is the default limit on the bytes held by a
+	// groDispatcher across all its buckets. It is large enough to never be
+	// reached with the default number of buckets.
+	groDefaultMaxHeldBytes = groDefaultNBuckets * groBucketSize * groMaxPacketSize
 )
 
 // A groBucket holds packets that are undergoing GRO.
@@ -71,6 +85,15 @@ type groBucket struct {
 
 	// +checklocks:mu
 	allocIdxs [groBucketSize]int
+
+	// retired is set once the bucket has been drained and replaced. No
+	// packets may be inserted into a retired bucket.
+	// +checklocks:mu
+	retired bool
+
+	// heldBytes points to the owning groDispatcher's count of held bytes.
+	// It is immutable after initialization.
+	heldBytes *atomicbitops.Int64
 }
 
 // +checklocks:gb.mu
@@ -89,8 +112,10 @@ func (gb *groBucket) insert(pkt PacketBufferPtr, ipHdr []byte, tcpHdr header.TCP
 		ipHdr:         ipHdr,
 		tcpHdr:        tcpHdr,
 		initialLength: pkt.Data().Size(), // pkt.Data() contains network header.
+		segments:      1,
 		idx:           groPkt.idx,
 	}
+	gb.heldBytes.Add(int64(pkt.Data().Size()))
 	gb.count++
 	gb.packets.PushBack(groPkt)
 }
@@ -101,6 +126,7 @@ func (gb *groBucket) insert(pkt PacketBufferPtr, ipHdr []byte, tcpHdr header.TCP
 func (gb *groBucket) removeOldest() PacketBufferPtr {
 	pkt := gb.packets.Front()
 	gb.packets.Remove(pkt)
+	gb.heldBytes.Add(-int64(pkt.pkt.Data().Size()))
 	gb.count--
 	gb.allocIdxs[gb.count] = pkt.idx
 	ret := pkt.pkt
@@ -112,6 +138,7 @@ func (gb *groBucket) removeOldest() PacketBufferPtr {
 // +checklocks:gb.mu
 func (gb *groBucket) removeOne(pkt *groPacket) {
 	gb.packets.Remove(pkt)
+	gb.heldBytes.Add(-int64(pkt.pkt.Data().Size()))
 	gb.count--
 	gb.allocIdxs[gb.count] = pkt.idx
 	pkt.reset()
@@ -224,22 +251,39 @@ func (gb *groBucket) found(gd *groDispatcher, groPkt *groPacket, flushGROPkt boo
 	tcpPayloadSize := pkt.Data().Size() - len(ipHdr) - int(dataOff)
 	if flushGROPkt {
 		// Flush the existing GRO packet. Don't hold bucket.mu while
-		// processing the packet.
-		pkt := groPkt.pkt
+		// processing the packet. This happens when pkt can't be appended
+		// to groPkt, e.g. because it arrived out of sequence; pkt may then
+		// start a new GRO packet below, so coalesced packets are always
+		// contiguous.
+		flushPkt := groPkt.pkt
 		gb.removeOne(groPkt)
 		gb.mu.Unlock()
-		ep.HandlePacket(pkt)
-		pkt.DecRef()
+		ep.HandlePacket(flushPkt)
+		flushPkt.DecRef()
 		gb.mu.Lock()
+		if gb.retired {
+			// The buckets were replaced while gb.mu was unlocked, so
+			// the incoming packet can't be held here.
+			gb.mu.Unlock()
+			ep.HandlePacket(pkt)
+			return
+		}
 		groPkt = nil
 	} else if groPkt != nil {
+		// Record the size of the coalesced segments so that the packet can be
+		// re-segmented if it is forwarded.
+		if groPkt.pkt.GROSegmentSize == 0 {
+			groPkt.pkt.GROSegmentSize = groPkt.payloadSize()
+		}
 		// Merge pkt in to GRO packet.
 		pkt.Data().TrimFront(len(ipHdr) + int(dataOff))
 		groPkt.pkt.Data().Merge(pkt.Data())
+		gb.heldBytes.Add(int64(tcpPayloadSize))
 		// Update the IP total length.
 		updateIPHdr(groPkt.ipHdr, tcpPayloadSize)
 		// Add flags from the packet to the GRO packet.
 		groPkt.tcpHdr.SetFlags(uint8(groPkt.tcpHdr.Flags() | (flags & (header.TCPFlagFin | header.TCPFlagPsh))))
+		groPkt.segments++
 
 		pkt = nil
 	}
@@ -256,6 +300,8 @@ func (gb *groBucket) found(gd *groDispatcher, groPkt *groPacket, flushGROPkt boo
 	flush = flush || tcpPayloadSize == 0
 	if groPkt != nil {
 		flush = flush || pktSize != groPkt.initialLength
+		// Flush once the packet holds the maximum number of segments.
+		flush = flush || groPkt.segments >= gd.getMaxSegments()
 	}
 
 	switch {
@@ -288,6 +334,9 @@ func (gb *groBucket) found(gd *groDispatcher, groPkt *groPacket, flushGROPkt boo
 		gb.mu.Unlock()
 	}
 
+	// Flush the oldest packets if too much memory is held.
+	gd.shrink()
+
 	// Schedule a timer if we never had one set before.
 	if gd.flushTimerState.CompareAndSwap(flushTimerUnset, flushTimerSet) {
 		gd.flushTimer.Reset(gd.getInterval())
@@ -321,6 +370,9 @@ type groPacket struct {
 	// packets are the same size.
 	initialLength int
 
+	// segments is the number of packets coalesced into pkt.
+	segments int
+
 	// idx is the groPacket's index in its bucket packetsPrealloc. It is
 	// immutable.
 	idx int
@@ -351,7 +403,29 @@ type groDispatcher struct {
 	// intervalNS is the interval in nanoseconds.
 	intervalNS atomicbitops.Int64
 
-	buckets [groNBuckets]groBucket
+	// maxSegments is the maximum number of segments coalesced into a
+	// single packet.
+	maxSegments atomicbitops.Int32
+
+	// ipv6ExtHdrs is whether IPv6 packets carrying extension headers are
+	// coalesced. Packets whose next header is TCP are always coalesced.
+	ipv6ExtHdrs atomicbitops.Bool
+
+	// heldBytes is the number of bytes held across all buckets.
+	heldBytes atomicbitops.Int64
+
+	// maxHeldBytes is the limit on heldBytes. Once it is exceeded, the
+	// oldest packets are flushed regardless of which bucket holds them.
+	maxHeldBytes atomicbitops.Int64
+
+	// bucketsMu protects buckets. It is only held for reading while a
+	// bucket is being found and locked, never while packets are handled.
+	bucketsMu sync.RWMutex
+
+	// buckets shard flows by a hash of their addresses and ports. The
+	// number of buckets is always a power of two.
+	// +checklocks:bucketsMu
+	buckets []groBucket
 
 	flushTimerState atomicbitops.Int32
 	flushTimer      *time.Timer
@@ -359,16 +433,12 @@ type groDispatcher struct {
 
 func (gd *groDispatcher) init(interval time.Duration) {
 	gd.intervalNS.Store(interval.Nanoseconds())
+	gd.maxSegments.Store(groDefaultMaxSegments)
+	gd.maxHeldBytes.Store(groDefaultMaxHeldBytes)
 
-	for i := range gd.buckets {
-		bucket := &gd.buckets[i]
-		bucket.mu.Lock()
-		for j := range bucket.packetsPrealloc {
-			bucket.allocIdxs[j] = j
-			bucket.packetsPrealloc[j].idx = j
-		}
-		bucket.mu.Unlock()
-	}
+	gd.bucketsMu.Lock()
+	gd.buckets = gd.newBuckets(groDefaultNBuckets)
+	gd.bucketsMu.Unlock()
 
 	// Create a timer to fire far from now and cancel it immediately.
 	//
@@ -395,6 +465,22 @@ func (gd *groDispatcher) init(interval time.Duration) {
 	gd.flushTimer.Stop()
 }
 
+// newBuckets returns n empty buckets.
+func (gd *groDispatcher) newBuckets(n int) []groBucket {
+	buckets := make([]groBucket, n)
+	for i := range buckets {
+		bucket := &buckets[i]
+		bucket.heldBytes = &gd.heldBytes
+		bucket.mu.Lock()
+		for j := range bucket.packetsPrealloc {
+			bucket.allocIdxs[j] = j
+			bucket.packetsPrealloc[j].idx = j
+		}
+		bucket.mu.Unlock()
+	}
+	return buckets
+}
+
 func (gd *groDispatcher) getInterval() time.Duration {
 	return time.Duration(gd.intervalNS.Load()) * time.Nanosecond
 }
@@ -409,6 +495,97 @@ func (gd *groDispatcher) setInterval(interval time.Duration) {
 	}
 }
 
+func (gd *groDispatcher) getMaxSegments() int {
+	return int(gd.maxSegments.Load())
+}
+
+func (gd *groDispatcher) setMaxSegments(maxSegments int) {
+	gd.maxSegments.Store(int32(maxSegments))
+}
+
+func (gd *groDispatcher) getMaxHeldBytes() int64 {
+	return gd.maxHeldBytes.Load()
+}
+
+func (gd *groDispatcher) setMaxHeldBytes(maxHeldBytes int64) {
+	gd.maxHeldBytes.Store(maxHeldBytes)
+}
+
+func (gd *groDispatcher) getIPv6ExtHdrs() bool {
+	return gd.ipv6ExtHdrs.Load()
+}
+
+func (gd *groDispatcher) setIPv6ExtHdrs(enabled bool) {
+	gd.ipv6ExtHdrs.Store(enabled)
+}
+
+func (gd *groDispatcher) getNBuckets() int {
+	gd.bucketsMu.RLock()
+	defer gd.bucketsMu.RUnlock()
+	return len(gd.buckets)
+}
+
+// setNBuckets replaces the buckets with n empty ones. n must be a power of
+// two. Flows may hash to different buckets afterwards, so any packets held in
+// the old buckets are flushed.
+func (gd *groDispatcher) setNBuckets(n int) {
+	gd.bucketsMu.Lock()
+	old := gd.buckets
+	gd.buckets = gd.newBuckets(n)
+	gd.bucketsMu.Unlock()
+
+	// Dispatchers may still hold a lock on an old bucket, so each one is
+	// marked as retired while locked to prevent further insertions.
+	var flushed []groFlushedPacket
+	for i := range old {
+		bucket := &old[i]
+		bucket.mu.Lock()
+		bucket.retired = true
+		flushed = bucket.removeAll(flushed)
+		bucket.mu.Unlock()
+	}
+	deliverFlushed(flushed)
+}
+
+// shrink flushes the oldest packets across all buckets until the number of
+// bytes held is within maxHeldBytes.
+func (gd *groDispatcher) shrink() {
+	for gd.heldBytes.Load() > gd.maxHeldBytes.Load() {
+		// Find the bucket holding the oldest packet. Each bucket's
+		// packets are ordered by age, so only the heads are compared.
+		var oldest *groBucket
+		var oldestCreated time.Time
+		gd.bucketsMu.RLock()
+		for i := range gd.buckets {
+			bucket := &gd.buckets[i]
+			bucket.mu.Lock()
+			if groPkt := bucket.packets.Front(); groPkt != nil && (oldest == nil || groPkt.created.Before(oldestCreated)) {
+				oldest = bucket
+				oldestCreated = groPkt.created
+			}
+			bucket.mu.Unlock()
+		}
+		gd.bucketsMu.RUnlock()
+		if oldest == nil {
+			return
+		}
+
+		// The bucket may have been flushed or retired while unlocked,
+		// in which case we look again.
+		oldest.mu.Lock()
+		groPkt := oldest.packets.Front()
+		if groPkt == nil {
+			oldest.mu.Unlock()
+			continue
+		}
+		pkt, ep := groPkt.pkt, groPkt.ep
+		oldest.removeOne(groPkt)
+		oldest.mu.Unlock()
+		ep.HandlePacket(pkt)
+		pkt.DecRef()
+	}
+}
+
 // dispatch sends pkt up the stack after it undergoes GRO coalescing.
 func (gd *groDispatcher) dispatch(pkt PacketBufferPtr, netProto tcpip.NetworkProtocolNumber, ep NetworkEndpoint) {
 	// If GRO is disabled simply pass the packet along.
@@ -491,8 +668,10 @@ func (gd *groDispatcher) dispatch4(pkt PacketBufferPtr, ep NetworkEndpoint) {
 	}
 
 	// Now we can get the bucket for the packet.
-	bucket := &gd.buckets[gd.bucketForPacket(ipHdr, tcpHdr)&groNBucketsMask]
+	gd.bucketsMu.RLock()
+	bucket := &gd.buckets[gd.bucketForPacket(ipHdr, tcpHdr)&(len(gd.buckets)-1)]
 	bucket.mu.Lock()
+	gd.bucketsMu.RUnlock()
 	groPkt, flushGROPkt := bucket.findGROPacket4(pkt, ipHdr, tcpHdr, ep)
 	bucket.found(gd, groPkt, flushGROPkt, pkt, ipHdr, tcpHdr, ep, updateIPv4Hdr)
 }
@@ -510,35 +689,43 @@ func (gd *groDispatcher) dispatch6(pkt PacketBufferPtr, ep NetworkEndpoint) {
 	ipHdr := header.IPv6(hdrBytes)
 
 	// Getting the IP header (+ extension headers) size is a bit of a pain
-	// on IPv6.
+	// on IPv6, so packets without extension headers take a fast path.
+	// Packets with extension headers are passed through as-is unless
+	// coalescing them is enabled.
 	transProto := tcpip.TransportProtocolNumber(ipHdr.NextHeader())
-	buf := pkt.Data().ToBuffer()
-	buf.TrimFront(header.IPv6MinimumSize)
-	it := header.MakeIPv6PayloadIterator(header.IPv6ExtensionHeaderIdentifier(transProto), buf)
 	ipHdrSize := int(header.IPv6MinimumSize)
-	for {
-		transProto = tcpip.TransportProtocolNumber(it.NextHeaderIdentifier())
-		extHdr, done, err := it.Next()
-		if err != nil {
+	if transProto != header.TCPProtocolNumber {
+		if !gd.getIPv6ExtHdrs() {
 			ep.HandlePacket(pkt)
 			return
 		}
-		if done {
-			break
-		}
-		switch extHdr.(type) {
-		// We can GRO these, so just skip over them.
-		case header.IPv6HopByHopOptionsExtHdr:
-		case header.IPv6RoutingExtHdr:
-		case header.IPv6DestinationOptionsExtHdr:
-		default:
-			// This is either a TCP header or something we can't handle.
-			ipHdrSize = int(it.HeaderOffset())
-			done = true
-		}
-		extHdr.Release()
-		if done {
-			break
+		buf := pkt.Data().ToBuffer()
+		buf.TrimFront(header.IPv6MinimumSize)
+		it := header.MakeIPv6PayloadIterator(header.IPv6ExtensionHeaderIdentifier(transProto), buf)
+		for {
+			transProto = tcpip.TransportProtocolNumber(it.NextHeaderIdentifier())
+			extHdr, done, err := it.Next()
+			if err != nil {
+				ep.HandlePacket(pkt)
+				return
+			}
+			if done {
+				break
+			}
+			switch extHdr.(type) {
+			// We can GRO these, so just skip over them.
+			case header.IPv6HopByHopOptionsExtHdr:
+			case header.IPv6RoutingExtHdr:
+			case header.IPv6DestinationOptionsExtHdr:
+			default:
+				// This is either a TCP header or something we can't handle.
+				ipHdrSize = int(it.HeaderOffset())
+				done = true
+			}
+			extHdr.Release()
+			if done {
+				break
+			}
 		}
 	}
 
@@ -589,8 +776,10 @@ func (gd *groDispatcher) dispatch6(pkt PacketBufferPtr, ep NetworkEndpoint) {
 	}
 
 	// Now we can get the bucket for the packet.
-	bucket := &gd.buckets[gd.bucketForPacket(ipHdr, tcpHdr)&groNBucketsMask]
+	gd.bucketsMu.RLock()
+	bucket := &gd.buckets[gd.bucketForPacket(ipHdr, tcpHdr)&(len(gd.buckets)-1)]
 	bucket.mu.Lock()
+	gd.bucketsMu.RUnlock()
 	groPkt, flushGROPkt := bucket.findGROPacket6(pkt, ipHdr, tcpHdr, ep)
 	bucket.found(gd, groPkt, flushGROPkt, pkt, ipHdr, tcpHdr, ep, updateIPv6Hdr)
 }
@@ -621,52 +810,103 @@ func (gd *groDispatcher) flush() bool {
 	return gd.flushSinceOrEqualTo(old)
 }
 
+// A groFlushedPacket is a packet removed from GRO that is yet to be delivered.
+type groFlushedPacket struct {
+	pkt     PacketBufferPtr
+	ep      NetworkEndpoint
+	created time.Time
+}
+
+// removeSinceOrEqualTo removes the packets older than or equal to the
+// specified time from gb and appends them to flushed.
+//
+// Returns the extended flushed and true iff packets remain in gb.
+// +checklocks:gb.mu
+func (gb *groBucket) removeSinceOrEqualTo(old time.Time, flushed []groFlushedPacket) ([]groFlushedPacket, bool) {
+	// Walk the bucket front-to-back, i.e. oldest first.
+	for groPkt := gb.packets.Front(); groPkt != nil; groPkt = gb.packets.Front() {
+		if groPkt.created.After(old) {
+			// Packets are ordered by age, so we can move on once we
+			// find one that's too new.
+			return flushed, true
+		}
+		flushed = append(flushed, groFlushedPacket{groPkt.pkt, groPkt.ep, groPkt.created})
+		gb.removeOne(groPkt)
+	}
+	return flushed, false
+}
+
+// removeAll removes every packet from gb and appends them to flushed.
+// +checklocks:gb.mu
+func (gb *groBucket) removeAll(flushed []groFlushedPacket) []groFlushedPacket {
+	for groPkt := gb.packets.Front(); groPkt != nil; groPkt = gb.packets.Front() {
+		flushed = append(flushed, groFlushedPacket{groPkt.pkt, groPkt.ep, groPkt.created})
+		gb.removeOne(groPkt)
+	}
+	return flushed
+}
+
+// deliverFlushed sends flushed packets up the stack in the order they started
+// coalescing and releases them.
+func deliverFlushed(flushed []groFlushedPacket) {
+	// Each bucket is already ordered, so a stable sort keeps packets of the
+	// same flow in order while interleaving buckets by age.
+	sort.SliceStable(flushed, func(i, j int) bool {
+		return flushed[i].created.Before(flushed[j].created)
+	})
+	for _, fp := range flushed {
+		fp.ep.HandlePacket(fp.pkt)
+		fp.pkt.DecRef()
+	}
+}
+
 // flushSinceOrEqualTo sends any packets older than or equal to the specified
-// time.
+// time. Packets are sent in the order they started coalescing, regardless of
+// which bucket holds them, to minimize reordering.
 //
 // Returns true iff packets remain.
 func (gd *groDispatcher) flushSinceOrEqualTo(old time.Time) bool {
-	type pair struct {
-		pkt PacketBufferPtr
-		ep  NetworkEndpoint
-	}
-
 	hasMore := false
 
-	for i := range gd.buckets {
-		// Put packets in a slice so we don't have to hold bucket.mu
-		// when we call HandlePacket.
-		var pairsBacking [groNBuckets]pair
-		pairs := pairsBacking[:0]
+	// Put packets in a slice so we don't have to hold bucket.mu when we
+	// call HandlePacket.
+	var flushedBacking [groDefaultNBuckets * groBucketSize]groFlushedPacket
+	flushed := flushedBacking[:0]
 
+	gd.bucketsMu.RLock()
+	for i := range gd.buckets {
 		bucket := &gd.buckets[i]
 		bucket.mu.Lock()
-		for groPkt := bucket.packets.Front(); groPkt != nil; groPkt = groPkt.Next() {
-			if groPkt.created.After(old) {
-				// Packets are ordered by age, so we can move
-				// on once we find one that's too new.
-				hasMore = true
-				break
-			} else {
-				pairs = append(pairs, pair{groPkt.pkt, groPkt.ep})
-				bucket.removeOne(groPkt)
-			}
-		}
+		var bucketHasMore bool
+		flushed, bucketHasMore = bucket.removeSinceOrEqualTo(old, flushed)
+		hasMore = hasMore || bucketHasMore
 		bucket.mu.Unlock()
-
-		for _, pair := range pairs {
-			pair.ep.HandlePacket(pair.pkt)
-			pair.pkt.DecRef()
-		}
 	}
+	gd.bucketsMu.RUnlock()
 
+	deliverFlushed(flushed)
 	return hasMore
 }
 
-func (gd *groDispatcher) flushAll() {
-	if gd.flushSinceOrEqualTo(time.Now()) {
-		panic("packets unexpectedly remain after flushing all")
+// removeAll removes every packet held in any bucket, leaving all buckets
+// empty.
+func (gd *groDispatcher) removeAll() []groFlushedPacket {
+	var flushed []groFlushedPacket
+	gd.bucketsMu.RLock()
+	for i := range gd.buckets {
+		bucket := &gd.buckets[i]
+		bucket.mu.Lock()
+		flushed = bucket.removeAll(flushed)
+		bucket.mu.Unlock()
 	}
+	gd.bucketsMu.RUnlock()
+	return flushed
+}
+
+// flushAll sends every held packet up the stack, regardless of age. It is
+// safe to call repeatedly, e.g. before saving the stack.
+func (gd *groDispatcher) flushAll() {
+	deliverFlushed(gd.removeAll())
 }
 
 // close stops the GRO goroutine and releases any held packets.
@@ -675,20 +915,16 @@ func (gd *groDispatcher) close() {
 	// Prevent the timer from being scheduled again.
 	gd.flushTimerState.Store(flushTimerClosed)
 
-	for i := range gd.buckets {
-		bucket := &gd.buckets[i]
-		bucket.mu.Lock()
-		for groPkt := bucket.packets.Front(); groPkt != nil; groPkt = bucket.packets.Front() {
-			groPkt.pkt.DecRef()
-			bucket.removeOne(groPkt)
-		}
-		bucket.mu.Unlock()
+	for _, fp := range gd.removeAll() {
+		fp.pkt.DecRef()
 	}
 }
 
 // String implements fmt.Stringer.
 func (gd *groDispatcher) String() string {
 	ret := "GRO state: \n"
+	gd.bucketsMu.RLock()
+	defer gd.bucketsMu.RUnlock()
 	for i := range gd.buckets {
 		bucket := &gd.buckets[i]
 		bucket.mu.Lock()
@@ -715,8 +951,167 @@ func shouldFlushTCP(groPkt *groPacket, tcpHdr header.TCP) bool {
 		groPkt.tcpHdr.SequenceNumber()+uint32(groPkt.payloadSize()) != tcpHdr.SequenceNumber() { // Does the incoming packet match the expected sequence number?
 		return true
 	}
-	// The options, including timestamps, must be identical.
-	return !bytes.Equal(tcpHdr[header.TCPMinimumSize:], groPkt.tcpHdr[header.TCPMinimumSize:])
+	return !tcpOptionsCoalescable(groPkt.tcpHdr[header.TCPMinimumSize:], tcpHdr[header.TCPMinimumSize:])
+}
+
+// tcpOptionsCoalescable returns whether a segment with TCP options opts can be
+// coalesced into a GRO packet with TCP options groOpts. The options must be
+// identical, except that the TSval of the timestamp option may advance. The GRO
+// packet keeps its TSval and TSecr.
+func tcpOptionsCoalescable(groOpts, opts []byte) bool {
+	if bytes.Equal(groOpts, opts) {
+		return true
+	}
+	if len(groOpts) != len(opts) {
+		return false
+	}
+	off := tcpTimestampOptionOffset(groOpts)
+	if off < 0 || off != tcpTimestampOptionOffset(opts) {
+		return false
+	}
+	// Everything but the timestamp values must be identical.
+	if !bytes.Equal(groOpts[:off+2], opts[:off+2]) || !bytes.Equal(groOpts[off+header.TCPOptionTSLength:], opts[off+header.TCPOptionTSLength:]) {
+		return false
+	}
+	groTSVal := binary.BigEndian.Uint32(groOpts[off+2:])
+	groTSEcr := binary.BigEndian.Uint32(groOpts[off+6:])
+	tsVal := binary.BigEndian.Uint32(opts[off+2:])
+	tsEcr := binary.BigEndian.Uint32(opts[off+6:])
+	// TSecr must match and TSval must not go backwards.
+	return tsEcr == groTSEcr && int32(tsVal-groTSVal) >= 0
+}
+
+// tcpTimestampOptionOffset returns the offset of the timestamp option in the
+// TCP options opts, or -1 if there is none.
+func tcpTimestampOptionOffset(opts []byte) int {
+	for i := 0; i < len(opts); {
+		switch opts[i] {
+		case header.TCPOptionEOL:
+			return -1
+		case header.TCPOptionNOP:
+			i++
+			continue
+		}
+		if i+1 >= len(opts) {
+			return -1
+		}
+		l := int(opts[i+1])
+		if l < 2 || i+l > len(opts) {
+			return -1
+		}
+		if opts[i] == header.TCPOptionTS && l == header.TCPOptionTSLength {
+			return i
+		}
+		i += l
+	}
+	return -1
+}
+
+// SegmentGROPacket splits pkt, a TCP packet coalesced by GRO, back into
+// segments carrying at most pkt.GROSegmentSize bytes of TCP payload each. pkt's
+// network header must be set. The TCP header is read from pkt's transport
+// header, as set on forwarded packets by DeepCopyForForwarding, or from the
+// start of its data if the transport header hasn't been parsed.
+//
+// Each segment starts with a copy of pkt's network header, which
+// updateNetworkHdr must update (e.g. lengths and checksum) given the number of
+// bytes following it. reserve is the number of bytes to reserve for link
+// headers in each segment.
+//
+// The caller takes ownership of the returned packets. SegmentGROPacket returns
+// false if pkt's TCP header is malformed or if the segments would not fit in
+// networkMTU.
+func SegmentGROPacket(pkt PacketBufferPtr, networkMTU uint32, reserve int, updateNetworkHdr func(hdr []byte, payloadLen int)) ([]PacketBufferPtr, bool) {
+	if pkt.GROSegmentSize <= 0 {
+		return nil, false
+	}
+	tcpHdr, inData := header.TCP(pkt.TransportHeader().Slice()), false
+	if len(tcpHdr) == 0 {
+		hdrBytes, ok := pkt.Data().PullUp(header.TCPMinimumSize)
+		if !ok {
+			return nil, false
+		}
+		hdrBytes, ok = pkt.Data().PullUp(int(header.TCP(hdrBytes).DataOffset()))
+		if !ok {
+			return nil, false
+		}
+		tcpHdr, inData = header.TCP(hdrBytes), true
+	}
+	if len(tcpHdr) < header.TCPMinimumSize {
+		return nil, false
+	}
+	dataOff := int(tcpHdr.DataOffset())
+	if dataOff < header.TCPMinimumSize || dataOff != len(tcpHdr) {
+		return nil, false
+	}
+	if uint32(dataOff+pkt.GROSegmentSize) > networkMTU {
+		return nil, false
+	}
+	netHdr := pkt.NetworkHeader().Slice()
+	srcAddr, dstAddr := pkt.Network().SourceAddress(), pkt.Network().DestinationAddress()
+
+	payload := pkt.Data().ToBuffer()
+	defer payload.Release()
+	if inData {
+		payload.TrimFront(int64(dataOff))
+	}
+
+	var segs []PacketBufferPtr
+	seq := tcpHdr.SequenceNumber()
+	for payload.Size() > 0 {
+		n := int64(pkt.GROSegmentSize)
+		if n > payload.Size() {
+			n = payload.Size()
+		}
+		segPayload := payload.Clone()
+		segPayload.Truncate(n)
+		payload.TrimFront(n)
+
+		segTCPHdr := header.TCP(make([]byte, dataOff))
+		copy(segTCPHdr, tcpHdr)
+		segTCPHdr.SetSequenceNumber(seq)
+		if payload.Size() > 0 {
+			// Only the last segment carries FIN and PSH.
+			segTCPHdr.SetFlags(uint8(tcpHdr.Flags() &^ (header.TCPFlagFin | header.TCPFlagPsh)))
+		}
+		segTCPHdr.SetChecksum(0)
+		xsum := header.PseudoHeaderChecksum(header.TCPProtocolNumber, srcAddr, dstAddr, uint16(dataOff+int(n)))
+		xsum = checksum.Combine(xsum, segPayload.Checksum(0))
+		segTCPHdr.SetChecksum(^segTCPHdr.CalculateChecksum(xsum))
+		seq += uint32(n)
+
+		segBuf := buffer.MakeWithData(segTCPHdr)
+		segBuf.Merge(&segPayload)
+		seg := NewPacketBuffer(PacketBufferOptions{
+			ReserveHeaderBytes: reserve + len(netHdr),
+			Payload:            segBuf,
+			IsForwardedPacket:  pkt.NetworkPacketInfo.IsForwardedPacket,
+		})
+		segNetHdr := seg.NetworkHeader().Push(len(netHdr))
+		copy(segNetHdr, netHdr)
+		updateNetworkHdr(segNetHdr, dataOff+int(n))
+		seg.NetworkProtocolNumber = pkt.NetworkProtocolNumber
+		seg.TransportProtocolNumber = header.TCPProtocolNumber
+		segs = append(segs, seg)
+	}
+	return segs, true
+}
+
+// WriteGROSegments writes segs, which were produced by SegmentGROPacket, to nic
+// and releases them. It stops writing at the first error and returns the
+// number of segments written along with that error.
+func WriteGROSegments(nic NetworkInterface, r *Route, segs []PacketBufferPtr) (int, tcpip.Error) {
+	sent := 0
+	var err tcpip.Error
+	for _, seg := range segs {
+		if err == nil {
+			if err = nic.WritePacket(r, seg); err == nil {
+				sent++
+			}
+		}
+		seg.DecRef()
+	}
+	return sent, err
 }
 
 func updateIPv4Hdr(ipHdrBytes []byte, newBytes int) {
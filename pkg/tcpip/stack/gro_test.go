@@ -0,0 +1,534 @@
+// Copyright 2023 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/buffer"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+)
+
+const (
+	groTestDstPort    = 80
+	groTestPayloadLen = 100
+)
+
+var (
+	groTestSrcAddr4 = tcpip.AddrFrom4([4]byte{10, 0, 0, 1})
+	groTestDstAddr4 = tcpip.AddrFrom4([4]byte{10, 0, 0, 2})
+	groTestSrcAddr6 = tcpip.AddrFrom16([16]byte{0x20, 0x01, 0x0d, 0xb8, 15: 1})
+	groTestDstAddr6 = tcpip.AddrFrom16([16]byte{0x20, 0x01, 0x0d, 0xb8, 15: 2})
+)
+
+// groSegment describes a TCP segment passed to GRO.
+type groSegment struct {
+	srcPort uint16
+	seq     uint32
+	flags   header.TCPFlags
+	opts    []byte
+}
+
+// groDelivered describes a packet delivered up the stack by GRO.
+type groDelivered struct {
+	srcPort    uint16
+	seq        uint32
+	payloadLen int
+}
+
+// groRecorder is a NetworkEndpoint that records the packets delivered to it.
+type groRecorder struct {
+	NetworkEndpoint
+
+	mu        sync.Mutex
+	delivered []groDelivered
+}
+
+// HandlePacket implements NetworkEndpoint.HandlePacket.
+func (r *groRecorder) HandlePacket(pkt PacketBufferPtr) {
+	b := pkt.Data().AsRange().ToSlice()
+	var ipHdrLen int
+	switch header.IPVersion(b) {
+	case header.IPv4Version:
+		ipHdrLen = int(header.IPv4(b).HeaderLength())
+	case header.IPv6Version:
+		ipHdrLen = header.IPv6MinimumSize
+		for next := header.IPv6(b).NextHeader(); tcpip.TransportProtocolNumber(next) != header.TCPProtocolNumber; {
+			next = b[ipHdrLen]
+			ipHdrLen += (int(b[ipHdrLen+1]) + 1) * 8
+		}
+	}
+	tcpHdr := header.TCP(b[ipHdrLen:])
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.delivered = append(r.delivered, groDelivered{
+		srcPort:    tcpHdr.SourcePort(),
+		seq:        tcpHdr.SequenceNumber(),
+		payloadLen: len(tcpHdr) - int(tcpHdr.DataOffset()),
+	})
+}
+
+// check verifies that the packets delivered since the last call are want.
+func (r *groRecorder) check(t *testing.T, want ...groDelivered) {
+	t.Helper()
+	r.mu.Lock()
+	got := r.delivered
+	r.delivered = nil
+	r.mu.Unlock()
+	if len(got) != len(want) {
+		t.Fatalf("got delivered packets %+v, want %+v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("got delivered packets %+v, want %+v", got, want)
+		}
+	}
+}
+
+// newTestGRODispatcher returns a groDispatcher whose flush timer won't fire
+// during the test.
+func newTestGRODispatcher(t *testing.T) *groDispatcher {
+	t.Helper()
+	var gd groDispatcher
+	gd.init(time.Hour)
+	t.Cleanup(gd.close)
+	return &gd
+}
+
+func encodeGROTestTCP(b []byte, seg groSegment) {
+	header.TCP(b).Encode(&header.TCPFields{
+		SrcPort:    seg.srcPort,
+		DstPort:    groTestDstPort,
+		SeqNum:     seg.seq,
+		AckNum:     1,
+		DataOffset: uint8(header.TCPMinimumSize + len(seg.opts)),
+		Flags:      seg.flags | header.TCPFlagAck,
+		WindowSize: 0xffff,
+	})
+	copy(b[header.TCPMinimumSize:], seg.opts)
+}
+
+func newGROTestPacket(b []byte) PacketBufferPtr {
+	pkt := NewPacketBuffer(PacketBufferOptions{
+		Payload: buffer.MakeWithData(b),
+	})
+	// Checksums aren't computed by the tests.
+	pkt.RXChecksumValidated = true
+	return pkt
+}
+
+// dispatchIPv4 passes an IPv4 packet carrying seg and groTestPayloadLen bytes
+// of payload to gd.
+func dispatchIPv4(gd *groDispatcher, r *groRecorder, seg groSegment) {
+	b := make([]byte, header.IPv4MinimumSize+header.TCPMinimumSize+len(seg.opts)+groTestPayloadLen)
+	header.IPv4(b).Encode(&header.IPv4Fields{
+		TotalLength: uint16(len(b)),
+		TTL:         64,
+		Protocol:    uint8(header.TCPProtocolNumber),
+		SrcAddr:     groTestSrcAddr4,
+		DstAddr:     groTestDstAddr4,
+	})
+	encodeGROTestTCP(b[header.IPv4MinimumSize:], seg)
+	pkt := newGROTestPacket(b)
+	gd.dispatch(pkt, header.IPv4ProtocolNumber, r)
+	pkt.DecRef()
+}
+
+func TestGROFlushOutOfOrder(t *testing.T) {
+	gd := newTestGRODispatcher(t)
+	var r groRecorder
+	const port = 1000
+
+	dispatchIPv4(gd, &r, groSegment{srcPort: port, seq: 1000})
+	r.check(t)
+
+	// A gap in the sequence flushes the held packet, and the new segment
+	// starts a new GRO packet.
+	dispatchIPv4(gd, &r, groSegment{srcPort: port, seq: 1200})
+	r.check(t, groDelivered{port, 1000, groTestPayloadLen})
+	dispatchIPv4(gd, &r, groSegment{srcPort: port, seq: 1300})
+	r.check(t)
+
+	// A segment filling the gap isn't coalesced out of order.
+	dispatchIPv4(gd, &r, groSegment{srcPort: port, seq: 1100})
+	r.check(t, groDelivered{port, 1200, 2 * groTestPayloadLen})
+
+	gd.flushAll()
+	r.check(t, groDelivered{port, 1100, groTestPayloadLen})
+}
+
+// groTSOptions returns TCP options holding a timestamp option, padded as Linux
+// does.
+func groTSOptions(tsVal, tsEcr uint32) []byte {
+	opts := make([]byte, 2+header.TCPOptionTSLength)
+	n := header.EncodeNOP(opts)
+	n += header.EncodeNOP(opts[n:])
+	header.EncodeTSOption(tsVal, tsEcr, opts[n:])
+	return opts
+}
+
+func TestTCPOptionsCoalescable(t *testing.T) {
+	// A window scale option, which isn't expected on data segments but has
+	// the same layout as any other option.
+	wsOpt := []byte{header.TCPOptionWS, 3, 7, header.TCPOptionNOP}
+	for _, tc := range []struct {
+		name    string
+		groOpts []byte
+		opts    []byte
+		want    bool
+	}{
+		{
+			name: "no options",
+			want: true,
+		},
+		{
+			name:    "identical",
+			groOpts: groTSOptions(10, 20),
+			opts:    groTSOptions(10, 20),
+			want:    true,
+		},
+		{
+			name:    "TSval advances",
+			groOpts: groTSOptions(10, 20),
+			opts:    groTSOptions(11, 20),
+			want:    true,
+		},
+		{
+			name:    "TSval advances across wraparound",
+			groOpts: groTSOptions(0xffffffff, 20),
+			opts:    groTSOptions(1, 20),
+			want:    true,
+		},
+		{
+			name:    "TSval goes backwards",
+			groOpts: groTSOptions(10, 20),
+			opts:    groTSOptions(9, 20),
+			want:    false,
+		},
+		{
+			name:    "TSecr differs",
+			groOpts: groTSOptions(10, 20),
+			opts:    groTSOptions(11, 21),
+			want:    false,
+		},
+		{
+			name:    "other option differs",
+			groOpts: append(groTSOptions(10, 20), wsOpt...),
+			opts:    append(groTSOptions(11, 20), header.TCPOptionWS, 3, 8, header.TCPOptionNOP),
+			want:    false,
+		},
+		{
+			name:    "different lengths",
+			groOpts: groTSOptions(10, 20),
+			opts:    append(groTSOptions(11, 20), wsOpt...),
+			want:    false,
+		},
+		{
+			name:    "timestamp at different offsets",
+			groOpts: append(groTSOptions(10, 20), wsOpt...),
+			opts:    append(wsOpt, groTSOptions(11, 20)...),
+			want:    false,
+		},
+		{
+			name:    "no timestamp",
+			groOpts: wsOpt,
+			opts:    []byte{header.TCPOptionWS, 3, 8, header.TCPOptionNOP},
+			want:    false,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tcpOptionsCoalescable(tc.groOpts, tc.opts); got != tc.want {
+				t.Errorf("tcpOptionsCoalescable(%x, %x) = %t, want %t", tc.groOpts, tc.opts, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTCPTimestampOptionOffset(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []byte
+		want int
+	}{
+		{
+			name: "padded",
+			opts: groTSOptions(1, 2),
+			want: 2,
+		},
+		{
+			name: "after another option",
+			opts: append([]byte{header.TCPOptionWS, 3, 7, header.TCPOptionNOP}, groTSOptions(1, 2)...),
+			want: 6,
+		},
+		{
+			name: "after end of options",
+			opts: append([]byte{header.TCPOptionEOL, header.TCPOptionNOP}, groTSOptions(1, 2)[2:]...),
+			want: -1,
+		},
+		{
+			name: "truncated",
+			opts: groTSOptions(1, 2)[:6],
+			want: -1,
+		},
+		{
+			name: "invalid length",
+			opts: []byte{header.TCPOptionWS, 0, header.TCPOptionNOP, header.TCPOptionNOP},
+			want: -1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tcpTimestampOptionOffset(tc.opts); got != tc.want {
+				t.Errorf("tcpTimestampOptionOffset(%x) = %d, want %d", tc.opts, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGROCoalesceTimestamps(t *testing.T) {
+	gd := newTestGRODispatcher(t)
+	var r groRecorder
+	const port = 1000
+
+	// Segments whose TSval advances are coalesced.
+	dispatchIPv4(gd, &r, groSegment{srcPort: port, seq: 1000, opts: groTSOptions(10, 20)})
+	dispatchIPv4(gd, &r, groSegment{srcPort: port, seq: 1100, opts: groTSOptions(11, 20)})
+	dispatchIPv4(gd, &r, groSegment{srcPort: port, seq: 1200, opts: groTSOptions(11, 20)})
+	r.check(t)
+
+	// A segment echoing a different TSecr flushes the GRO packet.
+	dispatchIPv4(gd, &r, groSegment{srcPort: port, seq: 1300, opts: groTSOptions(12, 21)})
+	r.check(t, groDelivered{port, 1000, 3 * groTestPayloadLen})
+
+	gd.flushAll()
+	r.check(t, groDelivered{port, 1300, groTestPayloadLen})
+}
+
+func TestGROMaxSegments(t *testing.T) {
+	gd := newTestGRODispatcher(t)
+	if got := gd.getMaxSegments(); got != groDefaultMaxSegments {
+		t.Errorf("got default max segments = %d, want %d", got, groDefaultMaxSegments)
+	}
+	const maxSegments = 3
+	gd.setMaxSegments(maxSegments)
+	var r groRecorder
+	const port = 1000
+
+	// The GRO packet is flushed as soon as it holds maxSegments segments.
+	seq := uint32(1000)
+	for i := 0; i < maxSegments; i++ {
+		r.check(t)
+		dispatchIPv4(gd, &r, groSegment{srcPort: port, seq: seq})
+		seq += groTestPayloadLen
+	}
+	r.check(t, groDelivered{port, 1000, maxSegments * groTestPayloadLen})
+
+	// The following segment starts a new GRO packet.
+	dispatchIPv4(gd, &r, groSegment{srcPort: port, seq: seq})
+	r.check(t)
+	gd.flushAll()
+	r.check(t, groDelivered{port, seq, groTestPayloadLen})
+}
+
+func TestGROMaxHeldBytes(t *testing.T) {
+	gd := newTestGRODispatcher(t)
+	const pktSize = header.IPv4MinimumSize + header.TCPMinimumSize + groTestPayloadLen
+	gd.setMaxHeldBytes(2*pktSize + groTestPayloadLen/2)
+	var r groRecorder
+
+	dispatchIPv4(gd, &r, groSegment{srcPort: 1000, seq: 1000})
+	dispatchIPv4(gd, &r, groSegment{srcPort: 1001, seq: 2000})
+	r.check(t)
+	if got, want := gd.heldBytes.Load(), int64(2*pktSize); got != want {
+		t.Errorf("got held bytes = %d, want %d", got, want)
+	}
+
+	// Exceeding the budget flushes the oldest packet, whichever flow it
+	// belongs to.
+	dispatchIPv4(gd, &r, groSegment{srcPort: 1002, seq: 3000})
+	r.check(t, groDelivered{1000, 1000, groTestPayloadLen})
+
+	// Coalescing counts against the budget too.
+	dispatchIPv4(gd, &r, groSegment{srcPort: 1002, seq: 3100})
+	r.check(t, groDelivered{1001, 2000, groTestPayloadLen})
+	if got, want := gd.heldBytes.Load(), int64(pktSize+groTestPayloadLen); got != want {
+		t.Errorf("got held bytes = %d, want %d", got, want)
+	}
+
+	gd.flushAll()
+	r.check(t, groDelivered{1002, 3000, 2 * groTestPayloadLen})
+	if got := gd.heldBytes.Load(); got != 0 {
+		t.Errorf("got held bytes = %d after flushing, want 0", got)
+	}
+}
+
+func TestGROFlushOldestFirst(t *testing.T) {
+	gd := newTestGRODispatcher(t)
+	var r groRecorder
+
+	// Flows from ports 1000 and 1008 share a bucket which comes after the
+	// bucket of port 1001.
+	dispatchIPv4(gd, &r, groSegment{srcPort: 1000, seq: 1000})
+	time.Sleep(time.Millisecond)
+	dispatchIPv4(gd, &r, groSegment{srcPort: 1001, seq: 2000})
+	time.Sleep(time.Millisecond)
+	cutoff := time.Now()
+	time.Sleep(time.Millisecond)
+	dispatchIPv4(gd, &r, groSegment{srcPort: 1008, seq: 3000})
+	r.check(t)
+
+	// Packets are delivered in the order they started coalescing rather
+	// than bucket by bucket.
+	if !gd.flushSinceOrEqualTo(cutoff) {
+		t.Errorf("gd.flushSinceOrEqualTo(_) = false with a newer packet held, want true")
+	}
+	r.check(t, groDelivered{1000, 1000, groTestPayloadLen}, groDelivered{1001, 2000, groTestPayloadLen})
+
+	if gd.flushSinceOrEqualTo(time.Now()) {
+		t.Errorf("gd.flushSinceOrEqualTo(_) = true after flushing every packet, want false")
+	}
+	r.check(t, groDelivered{1008, 3000, groTestPayloadLen})
+}
+
+func TestGROSetNBuckets(t *testing.T) {
+	gd := newTestGRODispatcher(t)
+	if got := gd.getNBuckets(); got != groDefaultNBuckets {
+		t.Errorf("got %d buckets by default, want %d", got, groDefaultNBuckets)
+	}
+	var r groRecorder
+
+	dispatchIPv4(gd, &r, groSegment{srcPort: 1000, seq: 1000})
+	dispatchIPv4(gd, &r, groSegment{srcPort: 1001, seq: 2000})
+	r.check(t)
+
+	// Packets held when the buckets are replaced are flushed.
+	const nBuckets = 16
+	gd.setNBuckets(nBuckets)
+	if got := gd.getNBuckets(); got != nBuckets {
+		t.Errorf("got %d buckets, want %d", got, nBuckets)
+	}
+	r.check(t, groDelivered{1000, 1000, groTestPayloadLen}, groDelivered{1001, 2000, groTestPayloadLen})
+	if got := gd.heldBytes.Load(); got != 0 {
+		t.Errorf("got held bytes = %d after replacing the buckets, want 0", got)
+	}
+
+	// The new buckets coalesce packets as before.
+	dispatchIPv4(gd, &r, groSegment{srcPort: 1000, seq: 1100})
+	dispatchIPv4(gd, &r, groSegment{srcPort: 1000, seq: 1200})
+	r.check(t)
+	gd.flushAll()
+	r.check(t, groDelivered{1000, 1100, 2 * groTestPayloadLen})
+}
+
+func TestGROFlushAll(t *testing.T) {
+	gd := newTestGRODispatcher(t)
+	var r groRecorder
+
+	// Hold packets in several buckets.
+	dispatchIPv4(gd, &r, groSegment{srcPort: 1000, seq: 1000})
+	dispatchIPv4(gd, &r, groSegment{srcPort: 1000, seq: 1100})
+	dispatchIPv4(gd, &r, groSegment{srcPort: 1001, seq: 2000})
+	dispatchIPv4(gd, &r, groSegment{srcPort: 1002, seq: 3000})
+	r.check(t)
+
+	// Every packet is delivered regardless of age.
+	gd.flushAll()
+	r.check(t,
+		groDelivered{1000, 1000, 2 * groTestPayloadLen},
+		groDelivered{1001, 2000, groTestPayloadLen},
+		groDelivered{1002, 3000, groTestPayloadLen},
+	)
+	if got := gd.heldBytes.Load(); got != 0 {
+		t.Errorf("got held bytes = %d after flushing, want 0", got)
+	}
+
+	// Flushing again is harmless.
+	gd.flushAll()
+	r.check(t)
+}
+
+func TestGROClose(t *testing.T) {
+	gd := newTestGRODispatcher(t)
+	var r groRecorder
+
+	dispatchIPv4(gd, &r, groSegment{srcPort: 1000, seq: 1000})
+	dispatchIPv4(gd, &r, groSegment{srcPort: 1001, seq: 2000})
+
+	// Closing releases held packets without delivering them.
+	gd.close()
+	r.check(t)
+	if got := gd.heldBytes.Load(); got != 0 {
+		t.Errorf("got held bytes = %d after closing, want 0", got)
+	}
+	if got := gd.flushTimerState.Load(); got != flushTimerClosed {
+		t.Errorf("got flush timer state %d after closing, want %d", got, flushTimerClosed)
+	}
+}
+
+// dispatchIPv6 passes an IPv6 packet with the given extension headers carrying
+// seg and groTestPayloadLen bytes of payload to gd.
+func dispatchIPv6(gd *groDispatcher, r *groRecorder, seg groSegment, extHdrs header.IPv6ExtHdrSerializer) {
+	ipHdrLen := header.IPv6MinimumSize + extHdrs.Length()
+	b := make([]byte, ipHdrLen+header.TCPMinimumSize+len(seg.opts)+groTestPayloadLen)
+	header.IPv6(b).Encode(&header.IPv6Fields{
+		PayloadLength:     uint16(len(b) - header.IPv6MinimumSize),
+		TransportProtocol: header.TCPProtocolNumber,
+		HopLimit:          64,
+		SrcAddr:           groTestSrcAddr6,
+		DstAddr:           groTestDstAddr6,
+		ExtensionHeaders:  extHdrs,
+	})
+	encodeGROTestTCP(b[ipHdrLen:], seg)
+	pkt := newGROTestPacket(b)
+	gd.dispatch(pkt, header.IPv6ProtocolNumber, r)
+	pkt.DecRef()
+}
+
+func TestGROIPv6ExtensionHeaders(t *testing.T) {
+	gd := newTestGRODispatcher(t)
+	var r groRecorder
+	const port = 1000
+	hopByHop := header.IPv6ExtHdrSerializer{
+		&header.IPv6SerializableHopByHopExtHdr{
+			&header.IPv6RouterAlertOption{Value: header.IPv6RouterAlertMLD},
+		},
+	}
+
+	// Packets without extension headers are always coalesced.
+	dispatchIPv6(gd, &r, groSegment{srcPort: port, seq: 1000}, nil)
+	dispatchIPv6(gd, &r, groSegment{srcPort: port, seq: 1100}, nil)
+	r.check(t)
+	gd.flushAll()
+	r.check(t, groDelivered{port, 1000, 2 * groTestPayloadLen})
+
+	// Packets with extension headers are passed through by default.
+	dispatchIPv6(gd, &r, groSegment{srcPort: port, seq: 1200}, hopByHop)
+	r.check(t, groDelivered{port, 1200, groTestPayloadLen})
+	dispatchIPv6(gd, &r, groSegment{srcPort: port, seq: 1300}, hopByHop)
+	r.check(t, groDelivered{port, 1300, groTestPayloadLen})
+
+	gd.setIPv6ExtHdrs(true)
+	dispatchIPv6(gd, &r, groSegment{srcPort: port, seq: 1400}, hopByHop)
+	dispatchIPv6(gd, &r, groSegment{srcPort: port, seq: 1500}, hopByHop)
+	r.check(t)
+	// Packets with different extension headers belong to different flows.
+	dispatchIPv6(gd, &r, groSegment{srcPort: port, seq: 1600}, nil)
+	r.check(t)
+	gd.flushAll()
+	r.check(t, groDelivered{port, 1400, 2 * groTestPayloadLen}, groDelivered{port, 1600, groTestPayloadLen})
+}
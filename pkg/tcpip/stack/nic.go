@@ -76,8 +76,18 @@ type nic struct {
 	// +checklocks:packetEPsMu
 	packetEPs map[tcpip.NetworkProtocolNumber]*packetEndpointList
 
+	// packetEPsPromiscuity is the number of packet endpoint memberships that
+	// put the NIC in promiscuous mode.
+	//
+	// +checklocks:packetEPsMu
+	packetEPsPromiscuity int
+
 	qDisc QueueingDiscipline
 
+	// directQDisc writes packets directly to the link endpoint. It is used
+	// instead of qDisc by writers that bypass queueing disciplines.
+	directQDisc QueueingDiscipline
+
 	gro groDispatcher
 }
 
@@ -155,9 +165,10 @@ func newNIC(stack *Stack, id tcpip.NICID, ep LinkEndpoint, opts NICOptions) *nic
 
 	// If no queueing discipline was specified provide a stub implementation that
 	// just delegates to the lower link endpoint.
+	directQDisc := &delegatingQueueingDiscipline{LinkWriter: ep}
 	qDisc := opts.QDisc
 	if qDisc == nil {
-		qDisc = &delegatingQueueingDiscipline{LinkWriter: ep}
+		qDisc = directQDisc
 	}
 
 	// TODO(b/143357959): RFC 8200 section 5 requires that IPv6 endpoints
@@ -174,6 +185,7 @@ func newNIC(stack *Stack, id tcpip.NICID, ep LinkEndpoint, opts NICOptions) *nic
 		linkAddrResolvers:         make(map[tcpip.NetworkProtocolNumber]*linkResolver),
 		duplicateAddressDetectors: make(map[tcpip.NetworkProtocolNumber]DuplicateAddressDetector),
 		qDisc:                     qDisc,
+		directQDisc:               directQDisc,
 	}
 	nic.linkResQueue.init(nic)
 
@@ -371,6 +383,10 @@ func (n *nic) WritePacket(r *Route, pkt PacketBufferPtr) tcpip.Error {
 
 // WritePacketToRemote implements NetworkInterface.
 func (n *nic) WritePacketToRemote(remoteLinkAddr tcpip.LinkAddress, pkt PacketBufferPtr) tcpip.Error {
+	return n.writePacketToRemote(remoteLinkAddr, pkt, n.qDisc)
+}
+
+func (n *nic) writePacketToRemote(remoteLinkAddr tcpip.LinkAddress, pkt PacketBufferPtr, qDisc QueueingDiscipline) tcpip.Error {
 	pkt.EgressRoute = RouteInfo{
 		routeInfo: routeInfo{
 			NetProto:         pkt.NetworkProtocolNumber,
@@ -378,7 +394,8 @@ func (n *nic) WritePacketToRemote(remoteLinkAddr tcpip.LinkAddress, pkt PacketBu
 		},
 		RemoteLinkAddress: remoteLinkAddr,
 	}
-	return n.writePacket(pkt)
+	n.NetworkLinkEndpoint.AddHeader(pkt)
+	return n.writeRawPacketToQDisc(pkt, qDisc)
 }
 
 func (n *nic) writePacket(pkt PacketBufferPtr) tcpip.Error {
@@ -386,17 +403,31 @@ func (n *nic) writePacket(pkt PacketBufferPtr) tcpip.Error {
 	return n.writeRawPacket(pkt)
 }
 
-func (n *nic) writeRawPacketWithLinkHeaderInPayload(pkt PacketBufferPtr) tcpip.Error {
+func (n *nic) writeRawPacketWithLinkHeaderInPayload(pkt PacketBufferPtr, qDisc QueueingDiscipline) tcpip.Error {
 	if !n.NetworkLinkEndpoint.ParseHeader(pkt) {
 		return &tcpip.ErrMalformedHeader{}
 	}
-	return n.writeRawPacket(pkt)
+	return n.writeRawPacketToQDisc(pkt, qDisc)
 }
 
 func (n *nic) writeRawPacket(pkt PacketBufferPtr) tcpip.Error {
+	return n.writeRawPacketToQDisc(pkt, n.qDisc)
+}
+
+// qDiscFor returns the queueing discipline to write packets through.
+func (n *nic) qDiscFor(bypassQDisc bool) QueueingDiscipline {
+	if bypassQDisc {
+		return n.directQDisc
+	}
+	return n.qDisc
+}
+
+// writeRawPacketToQDisc writes pkt through qDisc, which is either the NIC's
+// queueing discipline or n.directQDisc.
+func (n *nic) writeRawPacketToQDisc(pkt PacketBufferPtr, qDisc QueueingDiscipline) tcpip.Error {
 	// Always an outgoing packet.
 	pkt.PktType = tcpip.PacketOutgoing
-	if err := n.qDisc.WritePacket(pkt); err != nil {
+	if err := qDisc.WritePacket(pkt); err != nil {
 		if _, ok := err.(*tcpip.ErrNoBufferSpace); ok {
 			n.stats.txPacketsDroppedNoBufferSpace.Increment()
 		}
@@ -956,6 +987,26 @@ func (n *nic) unregisterPacketEndpoint(netProto tcpip.NetworkProtocolNumber, ep
 	}
 }
 
+// adjustPacketEndpointPromiscuity adds delta to the number of packet endpoint
+// memberships that require the NIC to be promiscuous.
+func (n *nic) adjustPacketEndpointPromiscuity(delta int) {
+	n.packetEPsMu.Lock()
+	defer n.packetEPsMu.Unlock()
+
+	n.packetEPsPromiscuity += delta
+	if n.packetEPsPromiscuity < 0 {
+		panic(fmt.Sprintf("negative packet endpoint promiscuity on NIC %d: %d", n.id, n.packetEPsPromiscuity))
+	}
+}
+
+// packetEPsPromiscuous returns true if a packet endpoint membership put the NIC
+// in promiscuous mode.
+func (n *nic) packetEPsPromiscuous() bool {
+	n.packetEPsMu.RLock()
+	defer n.packetEPsMu.RUnlock()
+	return n.packetEPsPromiscuity > 0
+}
+
 // isValidForOutgoing returns true if the endpoint can be used to send out a
 // packet. It requires the endpoint to not be marked expired (i.e., its address
 // has been removed) unless the NIC is in spoofing mode, or temporary.
@@ -16,6 +16,7 @@ package stack
 import (
 	"fmt"
 	"io"
+	"time"
 
 	"gvisor.dev/gvisor/pkg/buffer"
 	"gvisor.dev/gvisor/pkg/sync"
@@ -142,6 +143,19 @@ type PacketBuffer struct {
 	EgressRoute RouteInfo
 	GSOOptions  GSO
 
+	// GROSegmentSize is the TCP payload size of the segments GRO coalesced
+	// into this packet, or zero if the packet isn't the result of coalescing.
+	GROSegmentSize int
+
+	// ReassembledFragments is the number of IP fragments this packet was
+	// reassembled from, or zero if the packet isn't the result of reassembly.
+	ReassembledFragments int
+
+	// ReassemblyDuration is the time between the start of this packet's
+	// reassembly and its completion. It is only valid if ReassembledFragments
+	// is non-zero.
+	ReassemblyDuration time.Duration
+
 	// snatDone indicates if the packet's source has been manipulated as per
 	// iptables NAT table.
 	snatDone bool
@@ -374,6 +388,9 @@ func (pk PacketBufferPtr) Clone() PacketBufferPtr {
 	newPk.Hash = pk.Hash
 	newPk.Owner = pk.Owner
 	newPk.GSOOptions = pk.GSOOptions
+	newPk.GROSegmentSize = pk.GROSegmentSize
+	newPk.ReassembledFragments = pk.ReassembledFragments
+	newPk.ReassemblyDuration = pk.ReassemblyDuration
 	newPk.NetworkProtocolNumber = pk.NetworkProtocolNumber
 	newPk.dnatDone = pk.dnatDone
 	newPk.snatDone = pk.snatDone
@@ -457,6 +474,9 @@ func (pk PacketBufferPtr) DeepCopyForForwarding(reservedHeaderBytes int) PacketB
 		newPk.TransportProtocolNumber = pk.TransportProtocolNumber
 	}
 
+	newPk.GROSegmentSize = pk.GROSegmentSize
+	newPk.ReassembledFragments = pk.ReassembledFragments
+	newPk.ReassemblyDuration = pk.ReassemblyDuration
 	newPk.tuple = pk.tuple
 
 	return newPk
@@ -538,6 +538,19 @@ func (s *Stack) NICForwarding(id tcpip.NICID, protocol tcpip.NetworkProtocolNumb
 	return nic.forwarding(protocol)
 }
 
+// NICARPHardwareType returns the ARP hardware type of the specified NIC.
+func (s *Stack) NICARPHardwareType(id tcpip.NICID) (header.ARPHardwareType, tcpip.Error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	nic, ok := s.nics[id]
+	if !ok {
+		return header.ARPHardwareNone, &tcpip.ErrUnknownNICID{}
+	}
+
+	return nic.NetworkLinkEndpoint.ARPHardwareType(), nil
+}
+
 // SetForwardingDefaultAndAllNICs sets packet forwarding for all NICs for the
 // passed protocol and sets the default setting for newly created NICs.
 func (s *Stack) SetForwardingDefaultAndAllNICs(protocol tcpip.NetworkProtocolNumber, enable bool) tcpip.Error {
@@ -736,6 +749,138 @@ func (s *Stack) SetGROTimeout(nicID tcpip.NICID, timeout time.Duration) tcpip.Er
 	return nil
 }
 
+// GROMaxSegments returns the maximum number of segments GRO coalesces into a
+// single packet.
+func (s *Stack) GROMaxSegments(nicID tcpip.NICID) (int, tcpip.Error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	nic, ok := s.nics[nicID]
+	if !ok {
+		return 0, &tcpip.ErrUnknownNICID{}
+	}
+
+	return nic.gro.getMaxSegments(), nil
+}
+
+// SetGROMaxSegments sets the maximum number of segments GRO coalesces into a
+// single packet. A GRO packet is flushed once it reaches this many segments.
+func (s *Stack) SetGROMaxSegments(nicID tcpip.NICID, maxSegments int) tcpip.Error {
+	if maxSegments < 1 {
+		return &tcpip.ErrInvalidOptionValue{}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	nic, ok := s.nics[nicID]
+	if !ok {
+		return &tcpip.ErrUnknownNICID{}
+	}
+
+	nic.gro.setMaxSegments(maxSegments)
+	return nil
+}
+
+// GROMaxHeldBytes returns the maximum number of bytes GRO holds for a NIC.
+func (s *Stack) GROMaxHeldBytes(nicID tcpip.NICID) (int64, tcpip.Error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	nic, ok := s.nics[nicID]
+	if !ok {
+		return 0, &tcpip.ErrUnknownNICID{}
+	}
+
+	return nic.gro.getMaxHeldBytes(), nil
+}
+
+// SetGROMaxHeldBytes sets the maximum number of bytes GRO holds for a NIC
+// across all flows. When the limit is exceeded, the oldest packets are flushed
+// until GRO holds no more than maxHeldBytes. The new limit is enforced when
+// the next packet is coalesced.
+func (s *Stack) SetGROMaxHeldBytes(nicID tcpip.NICID, maxHeldBytes int64) tcpip.Error {
+	if maxHeldBytes < 0 {
+		return &tcpip.ErrInvalidOptionValue{}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	nic, ok := s.nics[nicID]
+	if !ok {
+		return &tcpip.ErrUnknownNICID{}
+	}
+
+	nic.gro.setMaxHeldBytes(maxHeldBytes)
+	return nil
+}
+
+// GROIPv6ExtensionHeaders returns whether GRO coalesces IPv6 packets carrying
+// extension headers for a NIC.
+func (s *Stack) GROIPv6ExtensionHeaders(nicID tcpip.NICID) (bool, tcpip.Error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	nic, ok := s.nics[nicID]
+	if !ok {
+		return false, &tcpip.ErrUnknownNICID{}
+	}
+
+	return nic.gro.getIPv6ExtHdrs(), nil
+}
+
+// SetGROIPv6ExtensionHeaders sets whether GRO coalesces IPv6 packets carrying
+// hop-by-hop, routing or destination options extension headers for a NIC. It
+// is disabled by default, in which case only IPv6 packets whose next header is
+// TCP are coalesced and all others are passed through as-is.
+func (s *Stack) SetGROIPv6ExtensionHeaders(nicID tcpip.NICID, enabled bool) tcpip.Error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	nic, ok := s.nics[nicID]
+	if !ok {
+		return &tcpip.ErrUnknownNICID{}
+	}
+
+	nic.gro.setIPv6ExtHdrs(enabled)
+	return nil
+}
+
+// GROBuckets returns the number of buckets GRO uses to shard flows for a NIC.
+func (s *Stack) GROBuckets(nicID tcpip.NICID) (int, tcpip.Error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	nic, ok := s.nics[nicID]
+	if !ok {
+		return 0, &tcpip.ErrUnknownNICID{}
+	}
+
+	return nic.gro.getNBuckets(), nil
+}
+
+// SetGROBuckets sets the number of buckets GRO uses to shard flows for a NIC.
+// More buckets reduce collisions between flows at the cost of memory. n must
+// be a power of two. Packets held by GRO when the number of buckets changes
+// are flushed.
+func (s *Stack) SetGROBuckets(nicID tcpip.NICID, n int) tcpip.Error {
+	if n < 1 || n > groMaxNBuckets || n&(n-1) != 0 {
+		return &tcpip.ErrInvalidOptionValue{}
+	}
+
+	s.mu.RLock()
+	nic, ok := s.nics[nicID]
+	s.mu.RUnlock()
+	if !ok {
+		return &tcpip.ErrUnknownNICID{}
+	}
+
+	// Flushed packets are delivered up the stack, so s.mu must not be held.
+	nic.gro.setNBuckets(n)
+	return nil
+}
+
 // SetRouteTable assigns the route table to be used by this stack. It
 // specifies which NIC to use for given destination address ranges.
 //
@@ -1044,7 +1189,7 @@ func (s *Stack) NICInfo() map[tcpip.NICID]NICInfo {
 		flags := NICStateFlags{
 			Up:          true, // Netstack interfaces are always up.
 			Running:     nic.Enabled(),
-			Promiscuous: nic.Promiscuous(),
+			Promiscuous: nic.Promiscuous() || nic.packetEPsPromiscuous(),
 			Loopback:    nic.IsLoopback(),
 		}
 
@@ -1517,6 +1662,26 @@ func (s *Stack) SetPromiscuousMode(nicID tcpip.NICID, enable bool) tcpip.Error {
 	return nil
 }
 
+// AdjustPacketEndpointPromiscuity adds delta to the number of packet endpoint
+// memberships that require the given NIC to be in promiscuous mode. While the
+// count is positive, the NIC is reported as promiscuous.
+//
+// Netstack doesn't filter frames addressed to other hosts before delivering
+// them to packet endpoints, so this doesn't affect which frames are received.
+func (s *Stack) AdjustPacketEndpointPromiscuity(nicID tcpip.NICID, delta int) tcpip.Error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	nic, ok := s.nics[nicID]
+	if !ok {
+		return &tcpip.ErrUnknownNICID{}
+	}
+
+	nic.adjustPacketEndpointPromiscuity(delta)
+
+	return nil
+}
+
 // SetSpoofing enables or disables address spoofing in the given NIC, allowing
 // endpoints to bind to any address in the NIC.
 func (s *Stack) SetSpoofing(nicID tcpip.NICID, enable bool) tcpip.Error {
@@ -1770,8 +1935,19 @@ func (s *Stack) Destroy() {
 	s.Wait()
 }
 
-// Pause pauses any protocol level background workers.
+// Pause pauses any protocol level background workers. Packets held for GRO
+// are delivered first so that they are saved with their endpoints.
 func (s *Stack) Pause() {
+	s.mu.RLock()
+	nics := make([]*nic, 0, len(s.nics))
+	for _, n := range s.nics {
+		nics = append(nics, n)
+	}
+	s.mu.RUnlock()
+	for _, n := range nics {
+		n.gro.flushAll()
+	}
+
 	for _, p := range s.transportProtocols {
 		p.proto.Pause()
 	}
@@ -1856,7 +2032,10 @@ func (s *Stack) unregisterPacketEndpointLocked(nicID tcpip.NICID, netProto tcpip
 
 // WritePacketToRemote writes a payload on the specified NIC using the provided
 // network protocol and remote link address.
-func (s *Stack) WritePacketToRemote(nicID tcpip.NICID, remote tcpip.LinkAddress, netProto tcpip.NetworkProtocolNumber, payload buffer.Buffer) tcpip.Error {
+//
+// If bypassQDisc is true, the packet is written directly to the NIC's link
+// endpoint without passing through its queueing discipline.
+func (s *Stack) WritePacketToRemote(nicID tcpip.NICID, remote tcpip.LinkAddress, netProto tcpip.NetworkProtocolNumber, payload buffer.Buffer, bypassQDisc bool) tcpip.Error {
 	s.mu.Lock()
 	nic, ok := s.nics[nicID]
 	s.mu.Unlock()
@@ -1869,12 +2048,15 @@ func (s *Stack) WritePacketToRemote(nicID tcpip.NICID, remote tcpip.LinkAddress,
 	})
 	defer pkt.DecRef()
 	pkt.NetworkProtocolNumber = netProto
-	return nic.WritePacketToRemote(remote, pkt)
+	return nic.writePacketToRemote(remote, pkt, nic.qDiscFor(bypassQDisc))
 }
 
 // WriteRawPacket writes data directly to the specified NIC without adding any
 // headers.
-func (s *Stack) WriteRawPacket(nicID tcpip.NICID, proto tcpip.NetworkProtocolNumber, payload buffer.Buffer) tcpip.Error {
+//
+// If bypassQDisc is true, the packet is written directly to the NIC's link
+// endpoint without passing through its queueing discipline.
+func (s *Stack) WriteRawPacket(nicID tcpip.NICID, proto tcpip.NetworkProtocolNumber, payload buffer.Buffer, bypassQDisc bool) tcpip.Error {
 	s.mu.RLock()
 	nic, ok := s.nics[nicID]
 	s.mu.RUnlock()
@@ -1887,7 +2069,7 @@ func (s *Stack) WriteRawPacket(nicID tcpip.NICID, proto tcpip.NetworkProtocolNum
 	})
 	defer pkt.DecRef()
 	pkt.NetworkProtocolNumber = proto
-	return nic.writeRawPacketWithLinkHeaderInPayload(pkt)
+	return nic.writeRawPacketWithLinkHeaderInPayload(pkt, nic.qDiscFor(bypassQDisc))
 }
 
 // NetworkProtocolInstance returns the protocol instance in the stack for the
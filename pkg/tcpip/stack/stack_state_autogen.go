@@ -505,6 +505,9 @@ func (p *PacketBuffer) StateFields() []string {
 		"Owner",
 		"EgressRoute",
 		"GSOOptions",
+		"GROSegmentSize",
+		"ReassembledFragments",
+		"ReassemblyDuration",
 		"snatDone",
 		"dnatDone",
 		"PktType",
@@ -532,13 +535,16 @@ func (p *PacketBuffer) StateSave(stateSinkObject state.Sink) {
 	stateSinkObject.Save(9, &p.Owner)
 	stateSinkObject.Save(10, &p.EgressRoute)
 	stateSinkObject.Save(11, &p.GSOOptions)
-	stateSinkObject.Save(12, &p.snatDone)
-	stateSinkObject.Save(13, &p.dnatDone)
-	stateSinkObject.Save(14, &p.PktType)
-	stateSinkObject.Save(15, &p.NICID)
-	stateSinkObject.Save(16, &p.RXChecksumValidated)
-	stateSinkObject.Save(17, &p.NetworkPacketInfo)
-	stateSinkObject.Save(18, &p.tuple)
+	stateSinkObject.Save(12, &p.GROSegmentSize)
+	stateSinkObject.Save(13, &p.ReassembledFragments)
+	stateSinkObject.Save(14, &p.ReassemblyDuration)
+	stateSinkObject.Save(15, &p.snatDone)
+	stateSinkObject.Save(16, &p.dnatDone)
+	stateSinkObject.Save(17, &p.PktType)
+	stateSinkObject.Save(18, &p.NICID)
+	stateSinkObject.Save(19, &p.RXChecksumValidated)
+	stateSinkObject.Save(20, &p.NetworkPacketInfo)
+	stateSinkObject.Save(21, &p.tuple)
 }
 
 func (p *PacketBuffer) afterLoad() {}
@@ -557,13 +563,16 @@ func (p *PacketBuffer) StateLoad(stateSourceObject state.Source) {
 	stateSourceObject.Load(9, &p.Owner)
 	stateSourceObject.Load(10, &p.EgressRoute)
 	stateSourceObject.Load(11, &p.GSOOptions)
-	stateSourceObject.Load(12, &p.snatDone)
-	stateSourceObject.Load(13, &p.dnatDone)
-	stateSourceObject.Load(14, &p.PktType)
-	stateSourceObject.Load(15, &p.NICID)
-	stateSourceObject.Load(16, &p.RXChecksumValidated)
-	stateSourceObject.Load(17, &p.NetworkPacketInfo)
-	stateSourceObject.Load(18, &p.tuple)
+	stateSourceObject.Load(12, &p.GROSegmentSize)
+	stateSourceObject.Load(13, &p.ReassembledFragments)
+	stateSourceObject.Load(14, &p.ReassemblyDuration)
+	stateSourceObject.Load(15, &p.snatDone)
+	stateSourceObject.Load(16, &p.dnatDone)
+	stateSourceObject.Load(17, &p.PktType)
+	stateSourceObject.Load(18, &p.NICID)
+	stateSourceObject.Load(19, &p.RXChecksumValidated)
+	stateSourceObject.Load(20, &p.NetworkPacketInfo)
+	stateSourceObject.Load(21, &p.tuple)
 }
 
 func (h *headerInfo) StateTypeName() string {
@@ -0,0 +1,166 @@
+// Copyright 2023 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack_test
+
+import (
+	"testing"
+
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"gvisor.dev/gvisor/pkg/tcpip/link/channel"
+	"gvisor.dev/gvisor/pkg/tcpip/link/ethernet"
+	"gvisor.dev/gvisor/pkg/tcpip/link/loopback"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+)
+
+func TestNICARPHardwareType(t *testing.T) {
+	s := stack.New(stack.Options{})
+	defer s.Close()
+
+	nics := []struct {
+		id   tcpip.NICID
+		ep   stack.LinkEndpoint
+		want header.ARPHardwareType
+	}{
+		{id: 1, ep: loopback.New(), want: header.ARPHardwareLoopback},
+		{id: 2, ep: channel.New(0, 1500, ""), want: header.ARPHardwareNone},
+		{id: 3, ep: ethernet.New(channel.New(0, 1500, "\x02\x02\x03\x04\x05\x06")), want: header.ARPHardwareEther},
+	}
+	for _, nic := range nics {
+		if err := s.CreateNIC(nic.id, nic.ep); err != nil {
+			t.Fatalf("s.CreateNIC(%d, _): %s", nic.id, err)
+		}
+	}
+	for _, nic := range nics {
+		got, err := s.NICARPHardwareType(nic.id)
+		if err != nil {
+			t.Fatalf("s.NICARPHardwareType(%d): %s", nic.id, err)
+		}
+		if got != nic.want {
+			t.Errorf("s.NICARPHardwareType(%d) = %d, want %d", nic.id, got, nic.want)
+		}
+	}
+
+	const unknownNICID = 4
+	if _, err := s.NICARPHardwareType(unknownNICID); err == nil {
+		t.Errorf("s.NICARPHardwareType(%d) succeeded, want error", unknownNICID)
+	} else if _, ok := err.(*tcpip.ErrUnknownNICID); !ok {
+		t.Errorf("s.NICARPHardwareType(%d) = %s, want %s", unknownNICID, err, &tcpip.ErrUnknownNICID{})
+	}
+}
+
+func TestSetGROMaxSegments(t *testing.T) {
+	s := stack.New(stack.Options{})
+	defer s.Close()
+	const nicID = 1
+	if err := s.CreateNIC(nicID, loopback.New()); err != nil {
+		t.Fatalf("s.CreateNIC(%d, _): %s", nicID, err)
+	}
+
+	if err := s.SetGROMaxSegments(nicID, 0); err == nil {
+		t.Errorf("s.SetGROMaxSegments(%d, 0) succeeded, want error", nicID)
+	} else if _, ok := err.(*tcpip.ErrInvalidOptionValue); !ok {
+		t.Errorf("s.SetGROMaxSegments(%d, 0) = %s, want %s", nicID, err, &tcpip.ErrInvalidOptionValue{})
+	}
+	const maxSegments = 16
+	if err := s.SetGROMaxSegments(nicID, maxSegments); err != nil {
+		t.Fatalf("s.SetGROMaxSegments(%d, %d): %s", nicID, maxSegments, err)
+	}
+	if got, err := s.GROMaxSegments(nicID); err != nil || got != maxSegments {
+		t.Errorf("s.GROMaxSegments(%d) = (%d, %v), want (%d, nil)", nicID, got, err, maxSegments)
+	}
+	if err := s.SetGROMaxSegments(nicID+1, maxSegments); err == nil {
+		t.Errorf("s.SetGROMaxSegments(%d, %d) succeeded, want error", nicID+1, maxSegments)
+	} else if _, ok := err.(*tcpip.ErrUnknownNICID); !ok {
+		t.Errorf("s.SetGROMaxSegments(%d, %d) = %s, want %s", nicID+1, maxSegments, err, &tcpip.ErrUnknownNICID{})
+	}
+}
+
+func TestSetGROMaxHeldBytes(t *testing.T) {
+	s := stack.New(stack.Options{})
+	defer s.Close()
+	const nicID = 1
+	if err := s.CreateNIC(nicID, loopback.New()); err != nil {
+		t.Fatalf("s.CreateNIC(%d, _): %s", nicID, err)
+	}
+
+	if err := s.SetGROMaxHeldBytes(nicID, -1); err == nil {
+		t.Errorf("s.SetGROMaxHeldBytes(%d, -1) succeeded, want error", nicID)
+	} else if _, ok := err.(*tcpip.ErrInvalidOptionValue); !ok {
+		t.Errorf("s.SetGROMaxHeldBytes(%d, -1) = %s, want %s", nicID, err, &tcpip.ErrInvalidOptionValue{})
+	}
+	const maxHeldBytes = 1 << 20
+	if err := s.SetGROMaxHeldBytes(nicID, maxHeldBytes); err != nil {
+		t.Fatalf("s.SetGROMaxHeldBytes(%d, %d): %s", nicID, maxHeldBytes, err)
+	}
+	if got, err := s.GROMaxHeldBytes(nicID); err != nil || got != maxHeldBytes {
+		t.Errorf("s.GROMaxHeldBytes(%d) = (%d, %v), want (%d, nil)", nicID, got, err, maxHeldBytes)
+	}
+}
+
+func TestSetGROBuckets(t *testing.T) {
+	s := stack.New(stack.Options{})
+	defer s.Close()
+	const nicID = 1
+	if err := s.CreateNIC(nicID, loopback.New()); err != nil {
+		t.Fatalf("s.CreateNIC(%d, _): %s", nicID, err)
+	}
+
+	// The number of buckets must be a power of two within the limit.
+	for _, n := range []int{0, 3, 1 << 13} {
+		if err := s.SetGROBuckets(nicID, n); err == nil {
+			t.Errorf("s.SetGROBuckets(%d, %d) succeeded, want error", nicID, n)
+		} else if _, ok := err.(*tcpip.ErrInvalidOptionValue); !ok {
+			t.Errorf("s.SetGROBuckets(%d, %d) = %s, want %s", nicID, n, err, &tcpip.ErrInvalidOptionValue{})
+		}
+	}
+	for _, n := range []int{1, 64} {
+		if err := s.SetGROBuckets(nicID, n); err != nil {
+			t.Fatalf("s.SetGROBuckets(%d, %d): %s", nicID, n, err)
+		}
+		if got, err := s.GROBuckets(nicID); err != nil || got != n {
+			t.Errorf("s.GROBuckets(%d) = (%d, %v), want (%d, nil)", nicID, got, err, n)
+		}
+	}
+	if err := s.SetGROBuckets(nicID+1, 1); err == nil {
+		t.Errorf("s.SetGROBuckets(%d, 1) succeeded, want error", nicID+1)
+	} else if _, ok := err.(*tcpip.ErrUnknownNICID); !ok {
+		t.Errorf("s.SetGROBuckets(%d, 1) = %s, want %s", nicID+1, err, &tcpip.ErrUnknownNICID{})
+	}
+}
+
+func TestSetGROIPv6ExtensionHeaders(t *testing.T) {
+	s := stack.New(stack.Options{})
+	defer s.Close()
+	const nicID = 1
+	if err := s.CreateNIC(nicID, loopback.New()); err != nil {
+		t.Fatalf("s.CreateNIC(%d, _): %s", nicID, err)
+	}
+
+	if got, err := s.GROIPv6ExtensionHeaders(nicID); err != nil || got {
+		t.Errorf("s.GROIPv6ExtensionHeaders(%d) = (%t, %v) by default, want (false, nil)", nicID, got, err)
+	}
+	if err := s.SetGROIPv6ExtensionHeaders(nicID, true); err != nil {
+		t.Fatalf("s.SetGROIPv6ExtensionHeaders(%d, true): %s", nicID, err)
+	}
+	if got, err := s.GROIPv6ExtensionHeaders(nicID); err != nil || !got {
+		t.Errorf("s.GROIPv6ExtensionHeaders(%d) = (%t, %v), want (true, nil)", nicID, got, err)
+	}
+	if err := s.SetGROIPv6ExtensionHeaders(nicID+1, true); err == nil {
+		t.Errorf("s.SetGROIPv6ExtensionHeaders(%d, true) succeeded, want error", nicID+1)
+	} else if _, ok := err.(*tcpip.ErrUnknownNICID); !ok {
+		t.Errorf("s.SetGROIPv6ExtensionHeaders(%d, true) = %s, want %s", nicID+1, err, &tcpip.ErrUnknownNICID{})
+	}
+}
@@ -662,6 +662,13 @@ type ReceivableControlMessages struct {
 
 	// SockErr is the dequeued socket error on recvmsg(MSG_ERRQUEUE).
 	SockErr *SockError
+
+	// HasDropCount indicates whether DropCount is valid/set.
+	HasDropCount bool
+
+	// DropCount is the number of packets dropped by the socket before the
+	// associated packet was received.
+	DropCount uint32
 }
 
 // PacketOwner is used to get UID and GID of the packet.
@@ -1021,6 +1028,27 @@ func (*DefaultTTLOption) isGettableNetworkProtocolOption() {}
 
 func (*DefaultTTLOption) isSettableNetworkProtocolOption() {}
 
+// ICMPRateMaskOption is used by stack.(*Stack).NetworkProtocolOption to specify
+// the ICMP message types that are subject to the stack's global ICMP rate
+// limiter. It is analogous to Linux's icmp_ratemask and icmp/ratemask sysctls.
+//
+// Bit N of the mask is set if ICMP messages of type N are rate limited.
+type ICMPRateMaskOption [4]uint64
+
+// Has returns true if ICMP messages of type typ are rate limited.
+func (m *ICMPRateMaskOption) Has(typ uint8) bool {
+	return m[typ/64]&(1<<(typ%64)) != 0
+}
+
+// Set marks ICMP messages of type typ as rate limited.
+func (m *ICMPRateMaskOption) Set(typ uint8) {
+	m[typ/64] |= 1 << (typ % 64)
+}
+
+func (*ICMPRateMaskOption) isGettableNetworkProtocolOption() {}
+
+func (*ICMPRateMaskOption) isSettableNetworkProtocolOption() {}
+
 // GettableTransportProtocolOption is a marker interface for transport protocol
 // options that may be queried.
 type GettableTransportProtocolOption interface {
@@ -1360,6 +1388,49 @@ type RemoveMembershipOption MembershipOption
 
 func (*RemoveMembershipOption) isSettableSocketOption() {}
 
+// PacketMembershipType is the type of a packet socket membership.
+type PacketMembershipType int
+
+const (
+	// PacketMembershipPromiscuous is a membership that puts the interface in
+	// promiscuous mode.
+	PacketMembershipPromiscuous PacketMembershipType = iota
+
+	// PacketMembershipMulticast is a membership that joins the link-layer
+	// multicast group Addr.
+	PacketMembershipMulticast
+
+	// PacketMembershipAllMulticast is a membership that receives all
+	// link-layer multicast frames.
+	PacketMembershipAllMulticast
+
+	// PacketMembershipUnicast is a membership that adds Addr as a secondary
+	// unicast address of the interface.
+	PacketMembershipUnicast
+)
+
+// PacketMembershipOption is used to identify a packet socket membership on an
+// interface.
+//
+// +stateify savable
+type PacketMembershipOption struct {
+	NIC  NICID
+	Type PacketMembershipType
+	Addr LinkAddress
+}
+
+// AddPacketMembershipOption identifies a packet socket membership to add on
+// some interface.
+type AddPacketMembershipOption PacketMembershipOption
+
+func (*AddPacketMembershipOption) isSettableSocketOption() {}
+
+// RemovePacketMembershipOption identifies a packet socket membership to drop
+// on some interface.
+type RemovePacketMembershipOption PacketMembershipOption
+
+func (*RemovePacketMembershipOption) isSettableSocketOption() {}
+
 // SocketDetachFilterOption is used by SetSockOpt to detach a previously attached
 // classic BPF filter on a given endpoint.
 type SocketDetachFilterOption int
@@ -1947,6 +2018,18 @@ type IPStats struct {
 	// due to the fragment failing validation checks.
 	MalformedFragmentsReceived *StatCounter
 
+	// DuplicateFragmentsReceived is the number of IP Fragments that were
+	// dropped because their payload had already been received.
+	DuplicateFragmentsReceived *StatCounter
+
+	// PacketsReassembled is the number of IP packets that were reassembled
+	// from fragments.
+	PacketsReassembled *StatCounter
+
+	// FragmentsReassembled is the number of IP Fragments that were combined
+	// into reassembled packets.
+	FragmentsReassembled *StatCounter
+
 	// IPTablesPreroutingDropped is the number of IP packets dropped in the
 	// Prerouting chain.
 	IPTablesPreroutingDropped *StatCounter
@@ -1978,6 +2061,11 @@ type IPStats struct {
 	// OptionRouterAlertReceived is the number of Router Alert options seen.
 	OptionRouterAlertReceived *StatCounter
 
+	// OptionJumboPayloadReceived is the number of IPv6 Jumbo Payload options
+	// seen. Jumbograms are not supported, so packets carrying the option are
+	// discarded.
+	OptionJumboPayloadReceived *StatCounter
+
 	// OptionUnknownReceived is the number of unknown IP options seen.
 	OptionUnknownReceived *StatCounter
 
@@ -993,6 +993,9 @@ func (so *SocketOptions) StateFields() []string {
 		"receiveOriginalDstAddress",
 		"ipv4RecvErrEnabled",
 		"ipv6RecvErrEnabled",
+		"ignoreOutgoingEnabled",
+		"qdiscBypassEnabled",
+		"rxqOvflEnabled",
 		"errQueue",
 		"bindToDevice",
 		"sendBufferSize",
@@ -1029,12 +1032,15 @@ func (so *SocketOptions) StateSave(stateSinkObject state.Sink) {
 	stateSinkObject.Save(19, &so.receiveOriginalDstAddress)
 	stateSinkObject.Save(20, &so.ipv4RecvErrEnabled)
 	stateSinkObject.Save(21, &so.ipv6RecvErrEnabled)
-	stateSinkObject.Save(22, &so.errQueue)
-	stateSinkObject.Save(23, &so.bindToDevice)
-	stateSinkObject.Save(24, &so.sendBufferSize)
-	stateSinkObject.Save(25, &so.receiveBufferSize)
-	stateSinkObject.Save(26, &so.linger)
-	stateSinkObject.Save(27, &so.rcvlowat)
+	stateSinkObject.Save(22, &so.ignoreOutgoingEnabled)
+	stateSinkObject.Save(23, &so.qdiscBypassEnabled)
+	stateSinkObject.Save(24, &so.rxqOvflEnabled)
+	stateSinkObject.Save(25, &so.errQueue)
+	stateSinkObject.Save(26, &so.bindToDevice)
+	stateSinkObject.Save(27, &so.sendBufferSize)
+	stateSinkObject.Save(28, &so.receiveBufferSize)
+	stateSinkObject.Save(29, &so.linger)
+	stateSinkObject.Save(30, &so.rcvlowat)
 }
 
 func (so *SocketOptions) afterLoad() {}
@@ -1063,12 +1069,15 @@ func (so *SocketOptions) StateLoad(stateSourceObject state.Source) {
 	stateSourceObject.Load(19, &so.receiveOriginalDstAddress)
 	stateSourceObject.Load(20, &so.ipv4RecvErrEnabled)
 	stateSourceObject.Load(21, &so.ipv6RecvErrEnabled)
-	stateSourceObject.Load(22, &so.errQueue)
-	stateSourceObject.Load(23, &so.bindToDevice)
-	stateSourceObject.Load(24, &so.sendBufferSize)
-	stateSourceObject.Load(25, &so.receiveBufferSize)
-	stateSourceObject.Load(26, &so.linger)
-	stateSourceObject.Load(27, &so.rcvlowat)
+	stateSourceObject.Load(22, &so.ignoreOutgoingEnabled)
+	stateSourceObject.Load(23, &so.qdiscBypassEnabled)
+	stateSourceObject.Load(24, &so.rxqOvflEnabled)
+	stateSourceObject.Load(25, &so.errQueue)
+	stateSourceObject.Load(26, &so.bindToDevice)
+	stateSourceObject.Load(27, &so.sendBufferSize)
+	stateSourceObject.Load(28, &so.receiveBufferSize)
+	stateSourceObject.Load(29, &so.linger)
+	stateSourceObject.Load(30, &so.rcvlowat)
 }
 
 func (l *LocalSockError) StateTypeName() string {
@@ -1273,6 +1282,37 @@ func (f *FullAddress) StateLoad(stateSourceObject state.Source) {
 	stateSourceObject.Load(3, &f.LinkAddr)
 }
 
+func (p *PacketMembershipOption) StateTypeName() string {
+	return "pkg/tcpip.PacketMembershipOption"
+}
+
+func (p *PacketMembershipOption) StateFields() []string {
+	return []string{
+		"NIC",
+		"Type",
+		"Addr",
+	}
+}
+
+func (p *PacketMembershipOption) beforeSave() {}
+
+// +checklocksignore
+func (p *PacketMembershipOption) StateSave(stateSinkObject state.Sink) {
+	p.beforeSave()
+	stateSinkObject.Save(0, &p.NIC)
+	stateSinkObject.Save(1, &p.Type)
+	stateSinkObject.Save(2, &p.Addr)
+}
+
+func (p *PacketMembershipOption) afterLoad() {}
+
+// +checklocksignore
+func (p *PacketMembershipOption) StateLoad(stateSourceObject state.Source) {
+	stateSourceObject.Load(0, &p.NIC)
+	stateSourceObject.Load(1, &p.Type)
+	stateSourceObject.Load(2, &p.Addr)
+}
+
 func (s *SendableControlMessages) StateTypeName() string {
 	return "pkg/tcpip.SendableControlMessages"
 }
@@ -1338,6 +1378,8 @@ func (c *ReceivableControlMessages) StateFields() []string {
 		"HasOriginalDstAddress",
 		"OriginalDstAddress",
 		"SockErr",
+		"HasDropCount",
+		"DropCount",
 	}
 }
 
@@ -1367,6 +1409,8 @@ func (c *ReceivableControlMessages) StateSave(stateSinkObject state.Sink) {
 	stateSinkObject.Save(16, &c.HasOriginalDstAddress)
 	stateSinkObject.Save(17, &c.OriginalDstAddress)
 	stateSinkObject.Save(18, &c.SockErr)
+	stateSinkObject.Save(19, &c.HasDropCount)
+	stateSinkObject.Save(20, &c.DropCount)
 }
 
 func (c *ReceivableControlMessages) afterLoad() {}
@@ -1391,6 +1435,8 @@ func (c *ReceivableControlMessages) StateLoad(stateSourceObject state.Source) {
 	stateSourceObject.Load(16, &c.HasOriginalDstAddress)
 	stateSourceObject.Load(17, &c.OriginalDstAddress)
 	stateSourceObject.Load(18, &c.SockErr)
+	stateSourceObject.Load(19, &c.HasDropCount)
+	stateSourceObject.Load(20, &c.DropCount)
 	stateSourceObject.LoadValue(0, new(int64), func(y any) { c.loadTimestamp(y.(int64)) })
 }
 
@@ -1805,6 +1851,7 @@ func init() {
 	state.Register((*Address)(nil))
 	state.Register((*AddressMask)(nil))
 	state.Register((*FullAddress)(nil))
+	state.Register((*PacketMembershipOption)(nil))
 	state.Register((*SendableControlMessages)(nil))
 	state.Register((*ReceivableControlMessages)(nil))
 	state.Register((*LinkPacketInfo)(nil))
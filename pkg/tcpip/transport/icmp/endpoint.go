@@ -42,8 +42,14 @@ type icmpPacket struct {
 	// tosOrTClass stores either the Type of Service for IPv4 or the Traffic Class
 	// for IPv6.
 	tosOrTClass uint8
-	// ttlOrHopLimit stores either the TTL for IPv4 or the HopLimit for IPv6
+	// ttlOrHopLimit stores either the TTL for IPv4 or the HopLimit for IPv6.
+	// It is delivered as an IP_TTL or IPV6_HOPLIMIT control message when
+	// IP_RECVTTL or IPV6_RECVHOPLIMIT is enabled.
 	ttlOrHopLimit uint8
+
+	// dropCount is the number of packets dropped by the endpoint before this
+	// packet was queued. It is reported via SO_RXQ_OVFL.
+	dropCount uint32
 }
 
 // endpoint represents an ICMP endpoint. This struct serves as the interface
@@ -72,6 +78,9 @@ type endpoint struct {
 	rcvList    icmpPacketList
 	rcvBufSize int
 	rcvClosed  bool
+	// rcvDropped is the number of packets dropped because the receive queue
+	// was full.
+	rcvDropped uint32
 
 	// The following fields are protected by the mu mutex.
 	mu sync.RWMutex `state:"nosave"`
@@ -79,6 +88,9 @@ type endpoint struct {
 	// during restore.
 	frozen bool
 	ident  uint16
+	// boundBindToDevice is the NIC the endpoint was registered with the stack
+	// on, as set by SO_BINDTODEVICE at the time of registration.
+	boundBindToDevice tcpip.NICID
 }
 
 func newEndpoint(s *stack.Stack, netProto tcpip.NetworkProtocolNumber, transProto tcpip.TransportProtocolNumber, waiterQueue *waiter.Queue) (tcpip.Endpoint, tcpip.Error) {
@@ -134,7 +146,8 @@ func (e *endpoint) Close() {
 		case transport.DatagramEndpointStateBound, transport.DatagramEndpointStateConnected:
 			info := e.net.Info()
 			info.ID.LocalPort = e.ident
-			e.stack.UnregisterTransportEndpoint([]tcpip.NetworkProtocolNumber{info.NetProto}, e.transProto, info.ID, e, ports.Flags{}, tcpip.NICID(e.ops.GetBindToDevice()))
+			e.stack.UnregisterTransportEndpoint([]tcpip.NetworkProtocolNumber{info.NetProto}, e.transProto, info.ID, e, ports.Flags{}, e.boundBindToDevice)
+			e.boundBindToDevice = 0
 		default:
 			panic(fmt.Sprintf("unhandled state = %s", state))
 		}
@@ -232,6 +245,11 @@ func (e *endpoint) Read(dst io.Writer, opts tcpip.ReadOptions) (tcpip.ReadResult
 	default:
 		panic(fmt.Sprintf("unrecognized network protocol = %d", netProto))
 	}
+	// Like Linux, only report the drop count once packets have been dropped.
+	if e.ops.GetRxqOvfl() && p.dropCount != 0 {
+		cm.HasDropCount = true
+		cm.DropCount = p.dropCount
+	}
 
 	res := tcpip.ReadResult{
 		Total:           p.data.Data().Size(),
@@ -241,6 +259,9 @@ func (e *endpoint) Read(dst io.Writer, opts tcpip.ReadOptions) (tcpip.ReadResult
 		res.RemoteAddr = p.senderAddress
 	}
 
+	// The packet has already been dequeued unless peeking, so any part of it
+	// that doesn't fit in dst is discarded. res.Total still reports the full
+	// length so that callers can implement MSG_TRUNC.
 	n, err := p.data.Data().ReadTo(dst, opts.Peek)
 	if n == 0 && err != nil {
 		return res, &tcpip.ErrBadBuffer{}
@@ -326,6 +347,12 @@ func (e *endpoint) prepareForWrite(opts tcpip.WriteOptions) (network.WriteContex
 		}
 	}
 
+	// Like Linux, don't allow sending through a NIC other than the one the
+	// endpoint is bound to with SO_BINDTODEVICE.
+	if bindToDevice := tcpip.NICID(e.ops.GetBindToDevice()); bindToDevice != 0 && opts.To != nil && opts.To.NIC != 0 && opts.To.NIC != bindToDevice {
+		return network.WriteContext{}, 0, &tcpip.ErrHostUnreachable{}
+	}
+
 	ctx, err := e.net.AcquireContextForWrite(opts)
 	return ctx, e.ident, err
 }
@@ -507,12 +534,13 @@ func (e *endpoint) Connect(addr tcpip.FullAddress) tcpip.Error {
 	err := e.net.ConnectAndThen(addr, func(netProto tcpip.NetworkProtocolNumber, previousID, nextID stack.TransportEndpointID) tcpip.Error {
 		nextID.LocalPort = e.ident
 
-		nextID, err := e.registerWithStack(netProto, nextID)
+		nextID, btd, err := e.registerWithStack(netProto, nextID)
 		if err != nil {
 			return err
 		}
 
 		e.ident = nextID.LocalPort
+		e.boundBindToDevice = btd
 		return nil
 	})
 	if err != nil {
@@ -575,12 +603,12 @@ func (*endpoint) Accept(*tcpip.FullAddress) (tcpip.Endpoint, *waiter.Queue, tcpi
 	return nil, nil, &tcpip.ErrNotSupported{}
 }
 
-func (e *endpoint) registerWithStack(netProto tcpip.NetworkProtocolNumber, id stack.TransportEndpointID) (stack.TransportEndpointID, tcpip.Error) {
+func (e *endpoint) registerWithStack(netProto tcpip.NetworkProtocolNumber, id stack.TransportEndpointID) (stack.TransportEndpointID, tcpip.NICID, tcpip.Error) {
 	bindToDevice := tcpip.NICID(e.ops.GetBindToDevice())
 	if id.LocalPort != 0 {
 		// The endpoint already has a local port, just attempt to
 		// register it.
-		return id, e.stack.RegisterTransportEndpoint([]tcpip.NetworkProtocolNumber{netProto}, e.transProto, id, e, ports.Flags{}, bindToDevice)
+		return id, bindToDevice, e.stack.RegisterTransportEndpoint([]tcpip.NetworkProtocolNumber{netProto}, e.transProto, id, e, ports.Flags{}, bindToDevice)
 	}
 
 	// We need to find a port for the endpoint.
@@ -597,7 +625,7 @@ func (e *endpoint) registerWithStack(netProto tcpip.NetworkProtocolNumber, id st
 		}
 	})
 
-	return id, err
+	return id, bindToDevice, err
 }
 
 func (e *endpoint) bindLocked(addr tcpip.FullAddress) tcpip.Error {
@@ -612,12 +640,13 @@ func (e *endpoint) bindLocked(addr tcpip.FullAddress) tcpip.Error {
 			LocalPort:    addr.Port,
 			LocalAddress: addr.Addr,
 		}
-		id, err := e.registerWithStack(boundNetProto, id)
+		id, btd, err := e.registerWithStack(boundNetProto, id)
 		if err != nil {
 			return err
 		}
 
 		e.ident = id.LocalPort
+		e.boundBindToDevice = btd
 		return nil
 	})
 	if err != nil {
@@ -715,6 +744,12 @@ func (e *endpoint) HandlePacket(id stack.TransportEndpointID, pkt stack.PacketBu
 		}
 	}
 
+	// SO_BINDTODEVICE may have been set after the endpoint was registered with
+	// the stack, so the demuxer may deliver packets from other NICs.
+	if bindToDevice := tcpip.NICID(e.ops.GetBindToDevice()); bindToDevice != 0 && pkt.NICID != bindToDevice {
+		return
+	}
+
 	e.rcvMu.Lock()
 
 	// Drop the packet if our buffer is currently full.
@@ -727,13 +762,16 @@ func (e *endpoint) HandlePacket(id stack.TransportEndpointID, pkt stack.PacketBu
 
 	rcvBufSize := e.ops.GetReceiveBufferSize()
 	if e.frozen || e.rcvBufSize >= int(rcvBufSize) {
+		e.rcvDropped++
 		e.rcvMu.Unlock()
 		e.stack.Stats().DroppedPackets.Increment()
 		e.stats.ReceiveErrors.ReceiveBufferOverflow.Increment()
 		return
 	}
 
-	wasEmpty := e.rcvBufSize == 0
+	// Readiness is derived from the receive list, so waiters must be woken
+	// whenever the list transitions from empty to non-empty.
+	wasEmpty := e.rcvList.Empty()
 
 	net := pkt.Network()
 	dstAddr := net.DestinationAddress()
@@ -753,6 +791,7 @@ func (e *endpoint) HandlePacket(id stack.TransportEndpointID, pkt stack.PacketBu
 			NIC:             pkt.NICID,
 			DestinationAddr: dstAddr,
 		},
+		dropCount: e.rcvDropped,
 	}
 
 	// Save any useful information from the network header to the packet.
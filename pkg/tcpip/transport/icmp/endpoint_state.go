@@ -58,7 +58,7 @@ func (e *endpoint) Resume(s *stack.Stack) {
 		var err tcpip.Error
 		info := e.net.Info()
 		info.ID.LocalPort = e.ident
-		info.ID, err = e.registerWithStack(info.NetProto, info.ID)
+		info.ID, e.boundBindToDevice, err = e.registerWithStack(info.NetProto, info.ID)
 		if err != nil {
 			panic(fmt.Sprintf("e.registerWithStack(%d, %#v): %s", info.NetProto, info.ID, err))
 		}
@@ -0,0 +1,338 @@
+// Copyright 2023 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icmp_test
+
+import (
+	"bytes"
+	"testing"
+
+	"gvisor.dev/gvisor/pkg/buffer"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"gvisor.dev/gvisor/pkg/tcpip/link/channel"
+	"gvisor.dev/gvisor/pkg/tcpip/link/loopback"
+	"gvisor.dev/gvisor/pkg/tcpip/network/ipv4"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+	"gvisor.dev/gvisor/pkg/tcpip/transport/icmp"
+	"gvisor.dev/gvisor/pkg/waiter"
+)
+
+const nicID = 1
+
+var localAddr = tcpip.AddrFrom4([4]byte{127, 0, 0, 1})
+
+func newTestStack(t *testing.T) *stack.Stack {
+	t.Helper()
+	s := stack.New(stack.Options{
+		NetworkProtocols:   []stack.NetworkProtocolFactory{ipv4.NewProtocol},
+		TransportProtocols: []stack.TransportProtocolFactory{icmp.NewProtocol4},
+	})
+	t.Cleanup(s.Close)
+	if err := s.CreateNIC(nicID, loopback.New()); err != nil {
+		t.Fatalf("s.CreateNIC(%d, _): %s", nicID, err)
+	}
+	protoAddr := tcpip.ProtocolAddress{
+		Protocol:          header.IPv4ProtocolNumber,
+		AddressWithPrefix: localAddr.WithPrefix(),
+	}
+	if err := s.AddProtocolAddress(nicID, protoAddr, stack.AddressProperties{}); err != nil {
+		t.Fatalf("s.AddProtocolAddress(%d, %+v, {}): %s", nicID, protoAddr, err)
+	}
+	s.SetRouteTable([]tcpip.Route{{Destination: header.IPv4EmptySubnet, NIC: nicID}})
+	return s
+}
+
+func newTestEndpoint(t *testing.T, s *stack.Stack, wq *waiter.Queue) tcpip.Endpoint {
+	t.Helper()
+	ep, err := s.NewEndpoint(icmp.ProtocolNumber4, header.IPv4ProtocolNumber, wq)
+	if err != nil {
+		t.Fatalf("s.NewEndpoint(icmp, ipv4, _): %s", err)
+	}
+	t.Cleanup(ep.Close)
+	return ep
+}
+
+// sendEcho sends an echo request with the given sequence number and payload
+// to the local address. The stack answers it, and the reply is queued on ep.
+func sendEcho(t *testing.T, ep tcpip.Endpoint, seq uint16, payload []byte) {
+	t.Helper()
+	req := header.ICMPv4(make([]byte, header.ICMPv4MinimumSize+len(payload)))
+	req.SetType(header.ICMPv4Echo)
+	req.SetSequence(seq)
+	copy(req.Payload(), payload)
+	opts := tcpip.WriteOptions{To: &tcpip.FullAddress{Addr: localAddr}}
+	if _, err := ep.Write(bytes.NewReader(req), opts); err != nil {
+		t.Fatalf("ep.Write(_, %+v): %s", opts, err)
+	}
+}
+
+func TestReceiveQueueOverflowDropCount(t *testing.T) {
+	s := newTestStack(t)
+	var wq waiter.Queue
+	ep := newTestEndpoint(t, s, &wq)
+	// Any queued packet fills the receive queue.
+	ep.SocketOptions().SetReceiveBufferSize(1, false /* notify */)
+	ep.SocketOptions().SetRxqOvfl(true)
+
+	send := func(seq byte) {
+		t.Helper()
+		sendEcho(t, ep, uint16(seq), nil)
+	}
+	read := func() tcpip.ReadResult {
+		t.Helper()
+		var buf bytes.Buffer
+		res, err := ep.Read(&buf, tcpip.ReadOptions{})
+		if err != nil {
+			t.Fatalf("ep.Read(_, _): %s", err)
+		}
+		return res
+	}
+
+	// Nothing has been dropped yet, so no drop count is reported.
+	send(0)
+	if res := read(); res.ControlMessages.HasDropCount {
+		t.Errorf("got drop count %d before any drops, want none", res.ControlMessages.DropCount)
+	}
+
+	const dropped = 3
+	for i := 0; i <= dropped; i++ {
+		send(byte(i))
+	}
+	// The first packet was queued before the others were dropped.
+	res := read()
+	if res.ControlMessages.HasDropCount {
+		t.Errorf("got drop count %d for the packet queued before the drops, want none", res.ControlMessages.DropCount)
+	}
+
+	// Packets queued after the drops report the total.
+	send(0)
+	res = read()
+	if !res.ControlMessages.HasDropCount || res.ControlMessages.DropCount != dropped {
+		t.Errorf("got drop count (%t, %d), want (true, %d)", res.ControlMessages.HasDropCount, res.ControlMessages.DropCount, dropped)
+	}
+
+	// The count isn't reported unless SO_RXQ_OVFL is set.
+	ep.SocketOptions().SetRxqOvfl(false)
+	send(0)
+	if res := read(); res.ControlMessages.HasDropCount {
+		t.Errorf("got drop count %d with SO_RXQ_OVFL unset, want none", res.ControlMessages.DropCount)
+	}
+}
+
+func TestReceiveTTL(t *testing.T) {
+	s := newTestStack(t)
+	var wq waiter.Queue
+	ep := newTestEndpoint(t, s, &wq)
+
+	for _, receiveTTL := range []bool{false, true} {
+		ep.SocketOptions().SetReceiveTTL(receiveTTL)
+		sendEcho(t, ep, 0, nil)
+		var buf bytes.Buffer
+		res, err := ep.Read(&buf, tcpip.ReadOptions{})
+		if err != nil {
+			t.Fatalf("ep.Read(_, _): %s", err)
+		}
+		cm := res.ControlMessages
+		if cm.HasTTL != receiveTTL {
+			t.Errorf("got HasTTL = %t with IP_RECVTTL = %t, want %t", cm.HasTTL, receiveTTL, receiveTTL)
+		}
+		if receiveTTL && cm.TTL != ipv4.DefaultTTL {
+			t.Errorf("got TTL = %d, want %d", cm.TTL, ipv4.DefaultTTL)
+		}
+	}
+}
+
+// echoReply returns an IPv4 echo reply from src to dst with the given ident.
+func echoReply(src, dst tcpip.Address, ident uint16) []byte {
+	b := make([]byte, header.IPv4MinimumSize+header.ICMPv4MinimumSize)
+	ip := header.IPv4(b)
+	ip.Encode(&header.IPv4Fields{
+		TotalLength: uint16(len(b)),
+		TTL:         ipv4.DefaultTTL,
+		Protocol:    uint8(header.ICMPv4ProtocolNumber),
+		SrcAddr:     src,
+		DstAddr:     dst,
+	})
+	ip.SetChecksum(^ip.CalculateChecksum())
+	reply := header.ICMPv4(ip.Payload())
+	reply.SetType(header.ICMPv4EchoReply)
+	reply.SetIdent(ident)
+	reply.SetChecksum(header.ICMPv4Checksum(reply, 0))
+	return b
+}
+
+func TestBindToDevice(t *testing.T) {
+	const otherNICID = nicID + 1
+	var (
+		nicAddr    = tcpip.AddrFrom4([4]byte{10, 0, 0, 1})
+		remoteAddr = tcpip.AddrFrom4([4]byte{10, 0, 0, 2})
+		otherAddr  = tcpip.AddrFrom4([4]byte{10, 0, 1, 1})
+	)
+	s := stack.New(stack.Options{
+		NetworkProtocols:   []stack.NetworkProtocolFactory{ipv4.NewProtocol},
+		TransportProtocols: []stack.TransportProtocolFactory{icmp.NewProtocol4},
+	})
+	defer s.Close()
+	e := channel.New(1, header.IPv4MinimumMTU, "")
+	for id, ep := range map[tcpip.NICID]stack.LinkEndpoint{nicID: e, otherNICID: channel.New(1, header.IPv4MinimumMTU, "")} {
+		if err := s.CreateNIC(id, ep); err != nil {
+			t.Fatalf("s.CreateNIC(%d, _): %s", id, err)
+		}
+	}
+	for id, addr := range map[tcpip.NICID]tcpip.Address{nicID: nicAddr, otherNICID: otherAddr} {
+		protoAddr := tcpip.ProtocolAddress{
+			Protocol:          header.IPv4ProtocolNumber,
+			AddressWithPrefix: tcpip.AddressWithPrefix{Address: addr, PrefixLen: 24},
+		}
+		if err := s.AddProtocolAddress(id, protoAddr, stack.AddressProperties{}); err != nil {
+			t.Fatalf("s.AddProtocolAddress(%d, %+v, {}): %s", id, protoAddr, err)
+		}
+	}
+	s.SetRouteTable([]tcpip.Route{{Destination: header.IPv4EmptySubnet, NIC: nicID}})
+
+	var wq waiter.Queue
+	ep := newTestEndpoint(t, s, &wq)
+	const ident = 1234
+	bindAddr := tcpip.FullAddress{Addr: nicAddr, Port: ident}
+	if err := ep.Bind(bindAddr); err != nil {
+		t.Fatalf("ep.Bind(%+v): %s", bindAddr, err)
+	}
+	received := func() bool {
+		t.Helper()
+		pkt := stack.NewPacketBuffer(stack.PacketBufferOptions{
+			Payload: buffer.MakeWithData(echoReply(remoteAddr, nicAddr, ident)),
+		})
+		e.InjectInbound(header.IPv4ProtocolNumber, pkt)
+		pkt.DecRef()
+		var buf bytes.Buffer
+		_, err := ep.Read(&buf, tcpip.ReadOptions{})
+		if _, ok := err.(*tcpip.ErrWouldBlock); ok {
+			return false
+		}
+		if err != nil {
+			t.Fatalf("ep.Read(_, _): %s", err)
+		}
+		return true
+	}
+
+	if !received() {
+		t.Fatalf("echo reply wasn't received before SO_BINDTODEVICE was set")
+	}
+	// Binding to another device after the endpoint was registered with the
+	// stack filters out packets from other NICs.
+	if err := ep.SocketOptions().SetBindToDevice(otherNICID); err != nil {
+		t.Fatalf("SetBindToDevice(%d): %s", otherNICID, err)
+	}
+	if received() {
+		t.Errorf("echo reply from NIC %d was received with SO_BINDTODEVICE set to NIC %d", nicID, otherNICID)
+	}
+
+	// Sending through another NIC isn't allowed.
+	req := header.ICMPv4(make([]byte, header.ICMPv4MinimumSize))
+	req.SetType(header.ICMPv4Echo)
+	opts := tcpip.WriteOptions{To: &tcpip.FullAddress{Addr: remoteAddr, NIC: nicID}}
+	if _, err := ep.Write(bytes.NewReader(req), opts); err == nil {
+		t.Errorf("ep.Write(_, %+v) succeeded, want error", opts)
+	} else if _, ok := err.(*tcpip.ErrHostUnreachable); !ok {
+		t.Errorf("ep.Write(_, %+v) = %s, want %s", opts, err, &tcpip.ErrHostUnreachable{})
+	}
+
+	// Clearing the option lets packets from any NIC through again.
+	if err := ep.SocketOptions().SetBindToDevice(0); err != nil {
+		t.Fatalf("SetBindToDevice(0): %s", err)
+	}
+	if !received() {
+		t.Errorf("echo reply wasn't received after SO_BINDTODEVICE was cleared")
+	}
+}
+
+func TestTruncatedRead(t *testing.T) {
+	s := newTestStack(t)
+	var wq waiter.Queue
+	ep := newTestEndpoint(t, s, &wq)
+
+	payload := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	sendEcho(t, ep, 1, payload)
+	sendEcho(t, ep, 2, payload)
+	const (
+		bufSize = header.ICMPv4MinimumSize + 2
+		fullLen = header.ICMPv4MinimumSize + 8
+	)
+	for _, peek := range []bool{true, false} {
+		var buf bytes.Buffer
+		res, err := ep.Read(&tcpip.LimitedWriter{W: &buf, N: bufSize}, tcpip.ReadOptions{Peek: peek})
+		if err != nil {
+			t.Fatalf("ep.Read(_, {Peek: %t}): %s", peek, err)
+		}
+		if res.Count != bufSize || res.Total != fullLen {
+			t.Errorf("ep.Read(_, {Peek: %t}) = {Count: %d, Total: %d}, want {Count: %d, Total: %d}", peek, res.Count, res.Total, bufSize, fullLen)
+		}
+		if got := header.ICMPv4(buf.Bytes()).Sequence(); got != 1 {
+			t.Errorf("ep.Read(_, {Peek: %t}) returned sequence %d, want 1", peek, got)
+		}
+	}
+
+	// The rest of a truncated reply is discarded rather than returned by the
+	// next read.
+	var buf bytes.Buffer
+	res, err := ep.Read(&buf, tcpip.ReadOptions{})
+	if err != nil {
+		t.Fatalf("ep.Read(_, _): %s", err)
+	}
+	reply := header.ICMPv4(buf.Bytes())
+	if res.Count != fullLen || reply.Sequence() != 2 || !bytes.Equal(reply.Payload(), payload) {
+		t.Errorf("got %d bytes %x, want the second reply with payload %x", res.Count, buf.Bytes(), payload)
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	s := newTestStack(t)
+	var wq waiter.Queue
+	ep := newTestEndpoint(t, s, &wq)
+	we, ch := waiter.NewChannelEntry(waiter.ReadableEvents)
+	wq.EventRegister(&we)
+	defer wq.EventUnregister(&we)
+
+	if got := ep.Readiness(waiter.ReadableEvents); got != 0 {
+		t.Fatalf("got readiness %#x with an empty receive queue, want 0", got)
+	}
+	sendEcho(t, ep, 1, nil)
+	select {
+	case <-ch:
+	default:
+		t.Fatalf("reader wasn't woken when a reply was queued")
+	}
+	if got := ep.Readiness(waiter.ReadableEvents); got&waiter.EventIn == 0 {
+		t.Errorf("got readiness %#x with a queued reply, want %#x", got, waiter.EventIn)
+	}
+
+	// Readers are only woken when the queue becomes non-empty.
+	sendEcho(t, ep, 2, nil)
+	select {
+	case <-ch:
+		t.Errorf("reader was woken when a reply was queued behind another")
+	default:
+	}
+
+	for i := 0; i < 2; i++ {
+		var buf bytes.Buffer
+		if _, err := ep.Read(&buf, tcpip.ReadOptions{}); err != nil {
+			t.Fatalf("ep.Read(_, _): %s", err)
+		}
+	}
+	if got := ep.Readiness(waiter.ReadableEvents); got != 0 {
+		t.Errorf("got readiness %#x after draining the receive queue, want 0", got)
+	}
+}
@@ -19,6 +19,7 @@ func (p *icmpPacket) StateFields() []string {
 		"receivedAt",
 		"tosOrTClass",
 		"ttlOrHopLimit",
+		"dropCount",
 	}
 }
 
@@ -36,6 +37,7 @@ func (p *icmpPacket) StateSave(stateSinkObject state.Sink) {
 	stateSinkObject.Save(3, &p.data)
 	stateSinkObject.Save(5, &p.tosOrTClass)
 	stateSinkObject.Save(6, &p.ttlOrHopLimit)
+	stateSinkObject.Save(7, &p.dropCount)
 }
 
 func (p *icmpPacket) afterLoad() {}
@@ -48,6 +50,7 @@ func (p *icmpPacket) StateLoad(stateSourceObject state.Source) {
 	stateSourceObject.Load(3, &p.data)
 	stateSourceObject.Load(5, &p.tosOrTClass)
 	stateSourceObject.Load(6, &p.ttlOrHopLimit)
+	stateSourceObject.Load(7, &p.dropCount)
 	stateSourceObject.LoadValue(4, new(int64), func(y any) { p.loadReceivedAt(y.(int64)) })
 }
 
@@ -68,8 +71,10 @@ func (e *endpoint) StateFields() []string {
 		"rcvList",
 		"rcvBufSize",
 		"rcvClosed",
+		"rcvDropped",
 		"frozen",
 		"ident",
+		"boundBindToDevice",
 	}
 }
 
@@ -87,8 +92,10 @@ func (e *endpoint) StateSave(stateSinkObject state.Sink) {
 	stateSinkObject.Save(8, &e.rcvList)
 	stateSinkObject.Save(9, &e.rcvBufSize)
 	stateSinkObject.Save(10, &e.rcvClosed)
-	stateSinkObject.Save(11, &e.frozen)
-	stateSinkObject.Save(12, &e.ident)
+	stateSinkObject.Save(11, &e.rcvDropped)
+	stateSinkObject.Save(12, &e.frozen)
+	stateSinkObject.Save(13, &e.ident)
+	stateSinkObject.Save(14, &e.boundBindToDevice)
 }
 
 // +checklocksignore
@@ -104,8 +111,10 @@ func (e *endpoint) StateLoad(stateSourceObject state.Source) {
 	stateSourceObject.Load(8, &e.rcvList)
 	stateSourceObject.Load(9, &e.rcvBufSize)
 	stateSourceObject.Load(10, &e.rcvClosed)
-	stateSourceObject.Load(11, &e.frozen)
-	stateSourceObject.Load(12, &e.ident)
+	stateSourceObject.Load(11, &e.rcvDropped)
+	stateSourceObject.Load(12, &e.frozen)
+	stateSourceObject.Load(13, &e.ident)
+	stateSourceObject.Load(14, &e.boundBindToDevice)
 	stateSourceObject.AfterLoad(e.afterLoad)
 }
 
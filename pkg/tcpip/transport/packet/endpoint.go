@@ -49,6 +49,18 @@ type packet struct {
 	packetInfo tcpip.LinkPacketInfo
 }
 
+// membership is a membership added to a packet endpoint with
+// PACKET_ADD_MEMBERSHIP.
+//
+// +stateify savable
+type membership struct {
+	tcpip.PacketMembershipOption
+
+	// count is the number of times the membership was added. The membership is
+	// dropped once it has been removed as many times.
+	count int
+}
+
 // endpoint is the packet socket implementation of tcpip.Endpoint. It is legal
 // to have goroutines make concurrent calls into the endpoint.
 //
@@ -87,6 +99,8 @@ type endpoint struct {
 	boundNetProto tcpip.NetworkProtocolNumber
 	// +checklocks:mu
 	boundNIC tcpip.NICID
+	// +checklocks:mu
+	memberships []membership
 
 	lastErrorMu sync.Mutex `state:"nosave"`
 	// +checklocks:lastErrorMu
@@ -137,6 +151,11 @@ func (ep *endpoint) Close() {
 
 	ep.stack.UnregisterPacketEndpoint(ep.boundNIC, ep.boundNetProto, ep)
 
+	for _, m := range ep.memberships {
+		ep.dropMembershipLocked(m.PacketMembershipOption)
+	}
+	ep.memberships = nil
+
 	ep.rcvMu.Lock()
 	defer ep.rcvMu.Unlock()
 
@@ -245,11 +264,15 @@ func (ep *endpoint) Write(p tcpip.Payloader, opts tcpip.WriteOptions) (int64, tc
 	}
 	payloadSz := payload.Size()
 
+	// With PACKET_QDISC_BYPASS, frames are handed directly to the link
+	// endpoint. Our egress path has no traffic control layer beyond the NIC's
+	// optional queueing discipline, so that is all that is skipped.
+	bypassQDisc := ep.ops.GetQdiscBypass()
 	if err := func() tcpip.Error {
 		if ep.cooked {
-			return ep.stack.WritePacketToRemote(nicID, remote, proto, payload)
+			return ep.stack.WritePacketToRemote(nicID, remote, proto, payload, bypassQDisc)
 		}
-		return ep.stack.WriteRawPacket(nicID, proto, payload)
+		return ep.stack.WriteRawPacket(nicID, proto, payload, bypassQDisc)
 	}(); err != nil {
 		return 0, err
 	}
@@ -298,6 +321,9 @@ func (ep *endpoint) Bind(addr tcpip.FullAddress) tcpip.Error {
 	ep.mu.Lock()
 	defer ep.mu.Unlock()
 
+	// Only frames whose EtherType matches netProto are delivered to the
+	// endpoint, unless netProto is ETH_P_ALL (header.EthernetProtocolAll), in
+	// which case all frames are; see nic.DeliverLinkPacket.
 	netProto := tcpip.NetworkProtocolNumber(addr.Port)
 	if netProto == 0 {
 		// Do not allow unbinding the network protocol.
@@ -344,7 +370,8 @@ func (*endpoint) GetRemoteAddress() (tcpip.FullAddress, tcpip.Error) {
 
 // Readiness implements tcpip.Endpoint.Readiness.
 func (ep *endpoint) Readiness(mask waiter.EventMask) waiter.EventMask {
-	// The endpoint is always writable.
+	// The endpoint is always writable: writes are handed to the NIC
+	// synchronously and never wait for send buffer space.
 	result := waiter.WritableEvents & mask
 
 	// Determine whether the endpoint is readable.
@@ -359,19 +386,100 @@ func (ep *endpoint) Readiness(mask waiter.EventMask) waiter.EventMask {
 	return result
 }
 
-// SetSockOpt implements tcpip.Endpoint.SetSockOpt. Packet sockets cannot be
-// used with SetSockOpt, and this function always returns
-// *tcpip.ErrNotSupported.
+// SetSockOpt implements tcpip.Endpoint.SetSockOpt.
 func (ep *endpoint) SetSockOpt(opt tcpip.SettableSocketOption) tcpip.Error {
-	switch opt.(type) {
+	switch v := opt.(type) {
 	case *tcpip.SocketDetachFilterOption:
 		return nil
 
+	case *tcpip.AddPacketMembershipOption:
+		return ep.addMembership(tcpip.PacketMembershipOption(*v))
+
+	case *tcpip.RemovePacketMembershipOption:
+		return ep.removeMembership(tcpip.PacketMembershipOption(*v))
+
 	default:
 		return &tcpip.ErrUnknownProtocolOption{}
 	}
 }
 
+// addMembership adds a membership to the endpoint, applying it to the NIC
+// unless an identical membership already exists.
+func (ep *endpoint) addMembership(m tcpip.PacketMembershipOption) tcpip.Error {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+
+	if ep.closed {
+		return &tcpip.ErrClosedForReceive{}
+	}
+
+	for i := range ep.memberships {
+		if ep.memberships[i].PacketMembershipOption == m {
+			ep.memberships[i].count++
+			return nil
+		}
+	}
+
+	if !ep.stack.HasNIC(m.NIC) {
+		return &tcpip.ErrUnknownDevice{}
+	}
+	if err := ep.applyMembershipLocked(m); err != nil {
+		return err
+	}
+	ep.memberships = append(ep.memberships, membership{
+		PacketMembershipOption: m,
+		count:                  1,
+	})
+	return nil
+}
+
+// removeMembership removes a membership previously added to the endpoint,
+// dropping it from the NIC once it is no longer referenced.
+func (ep *endpoint) removeMembership(m tcpip.PacketMembershipOption) tcpip.Error {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+
+	for i := range ep.memberships {
+		if ep.memberships[i].PacketMembershipOption != m {
+			continue
+		}
+		ep.memberships[i].count--
+		if ep.memberships[i].count == 0 {
+			ep.dropMembershipLocked(m)
+			ep.memberships = append(ep.memberships[:i], ep.memberships[i+1:]...)
+		}
+		return nil
+	}
+	return &tcpip.ErrBadLocalAddress{}
+}
+
+// applyMembershipLocked updates the NIC for a newly added membership.
+//
+// +checklocks:ep.mu
+func (ep *endpoint) applyMembershipLocked(m tcpip.PacketMembershipOption) tcpip.Error {
+	switch m.Type {
+	case tcpip.PacketMembershipPromiscuous:
+		return ep.stack.AdjustPacketEndpointPromiscuity(m.NIC, 1)
+	case tcpip.PacketMembershipMulticast, tcpip.PacketMembershipAllMulticast, tcpip.PacketMembershipUnicast:
+		// Netstack NICs don't filter frames by link-layer destination, so
+		// these frames are already delivered.
+		return nil
+	default:
+		return &tcpip.ErrInvalidOptionValue{}
+	}
+}
+
+// dropMembershipLocked reverts the NIC update made for a membership.
+//
+// +checklocks:ep.mu
+func (ep *endpoint) dropMembershipLocked(m tcpip.PacketMembershipOption) {
+	switch m.Type {
+	case tcpip.PacketMembershipPromiscuous:
+		// The NIC may have been removed since the membership was added.
+		_ = ep.stack.AdjustPacketEndpointPromiscuity(m.NIC, -1)
+	}
+}
+
 // SetSockOptInt implements tcpip.Endpoint.SetSockOptInt.
 func (*endpoint) SetSockOptInt(tcpip.SockOptInt, int) tcpip.Error {
 	return &tcpip.ErrUnknownProtocolOption{}
@@ -418,6 +526,12 @@ func (ep *endpoint) GetSockOptInt(opt tcpip.SockOptInt) (int, tcpip.Error) {
 
 // HandlePacket implements stack.PacketEndpoint.HandlePacket.
 func (ep *endpoint) HandlePacket(nicID tcpip.NICID, netProto tcpip.NetworkProtocolNumber, pkt stack.PacketBufferPtr) {
+	// Packets sent by the local host are silently skipped, as if the endpoint
+	// never saw them, when PACKET_IGNORE_OUTGOING is set.
+	if pkt.PktType == tcpip.PacketOutgoing && ep.ops.GetIgnoreOutgoing() {
+		return
+	}
+
 	ep.rcvMu.Lock()
 
 	// Drop the packet if our buffer is currently full.
@@ -436,7 +550,9 @@ func (ep *endpoint) HandlePacket(nicID tcpip.NICID, netProto tcpip.NetworkProtoc
 		return
 	}
 
-	wasEmpty := ep.rcvBufSize == 0
+	// Check the list rather than rcvBufSize, since cooked endpoints may
+	// queue packets with no payload.
+	wasEmpty := ep.rcvList.Empty()
 
 	rcvdPkt := packet{
 		packetInfo: tcpip.LinkPacketInfo{
@@ -454,13 +570,17 @@ func (ep *endpoint) HandlePacket(nicID tcpip.NICID, netProto tcpip.NetworkProtoc
 		rcvdPkt.senderAddr.LinkAddr = hdr.SourceAddress()
 	}
 
-	// Raw packet endpoints include link-headers in received packets.
+	// Raw packet endpoints include link-headers in received packets. The
+	// virtio-net header, if any, precedes the link header and is never
+	// delivered.
 	pktBuf := pkt.ToBuffer()
+	trim := len(pkt.VirtioNetHeader().Slice())
 	if ep.cooked {
 		// Cooked packet endpoints don't include the link-headers in received
-		// packets.
-		pktBuf.TrimFront(int64(len(pkt.LinkHeader().Slice()) + len(pkt.VirtioNetHeader().Slice())))
+		// packets; the link header is synthesized by the NIC on send.
+		trim += len(pkt.LinkHeader().Slice())
 	}
+	pktBuf.TrimFront(int64(trim))
 	rcvdPkt.data = stack.NewPacketBuffer(stack.PacketBufferOptions{Payload: pktBuf})
 
 	ep.rcvList.PushBack(&rcvdPkt)
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"time"
 
+	"gvisor.dev/gvisor/pkg/log"
 	"gvisor.dev/gvisor/pkg/tcpip"
 	"gvisor.dev/gvisor/pkg/tcpip/stack"
 )
@@ -51,6 +52,18 @@ func (ep *endpoint) afterLoad() {
 		panic(fmt.Sprintf("RegisterPacketEndpoint(%d, %d, _): %s", ep.boundNIC, ep.boundNetProto, err))
 	}
 
+	// Reapply memberships to the restored NICs. A membership on a NIC that no
+	// longer exists can't be restored, so it is dropped.
+	memberships := ep.memberships[:0]
+	for _, m := range ep.memberships {
+		if err := ep.applyMembershipLocked(m.PacketMembershipOption); err != nil {
+			log.Warningf("Dropping packet socket membership %+v on restore: %s", m.PacketMembershipOption, err)
+			continue
+		}
+		memberships = append(memberships, m)
+	}
+	ep.memberships = memberships
+
 	ep.rcvMu.Lock()
 	ep.rcvDisabled = false
 	ep.rcvMu.Unlock()
@@ -0,0 +1,554 @@
+// Copyright 2023 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packet_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"gvisor.dev/gvisor/pkg/buffer"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"gvisor.dev/gvisor/pkg/tcpip/link/channel"
+	"gvisor.dev/gvisor/pkg/tcpip/link/ethernet"
+	"gvisor.dev/gvisor/pkg/tcpip/link/packetsocket"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+	"gvisor.dev/gvisor/pkg/tcpip/transport/packet"
+	"gvisor.dev/gvisor/pkg/waiter"
+)
+
+const (
+	nicID       = 1
+	nicLinkAddr = tcpip.LinkAddress("\x02\x02\x03\x04\x05\x06")
+	otherHost   = tcpip.LinkAddress("\x02\x02\x03\x04\x05\x07")
+	remoteAddr  = tcpip.LinkAddress("\x02\x02\x03\x04\x05\x08")
+)
+
+func newTestStack(t *testing.T) (*stack.Stack, *channel.Endpoint) {
+	t.Helper()
+	return newTestStackWithOptions(t, stack.NICOptions{})
+}
+
+func newTestStackWithOptions(t *testing.T, opts stack.NICOptions) (*stack.Stack, *channel.Endpoint) {
+	t.Helper()
+	s := stack.New(stack.Options{AllowPacketEndpointWrite: true})
+	t.Cleanup(s.Close)
+	ch := channel.New(4, header.EthernetMinimumSize+1500, nicLinkAddr)
+	if err := s.CreateNICWithOptions(nicID, packetsocket.New(ethernet.New(ch)), opts); err != nil {
+		t.Fatalf("s.CreateNICWithOptions(%d, _, %+v): %s", nicID, opts, err)
+	}
+	return s, ch
+}
+
+func newTestEndpoint(t *testing.T, s *stack.Stack) tcpip.Endpoint {
+	t.Helper()
+	return newTestEndpointWithProtocol(t, s, false /* cooked */, header.EthernetProtocolAll)
+}
+
+func newTestEndpointWithProtocol(t *testing.T, s *stack.Stack, cooked bool, netProto tcpip.NetworkProtocolNumber) tcpip.Endpoint {
+	t.Helper()
+	var wq waiter.Queue
+	ep, err := packet.NewEndpoint(s, cooked, netProto, &wq)
+	if err != nil {
+		t.Fatalf("packet.NewEndpoint(_, %t, %d, _): %s", cooked, netProto, err)
+	}
+	t.Cleanup(ep.Close)
+	return ep
+}
+
+func nicPromiscuous(t *testing.T, s *stack.Stack) bool {
+	t.Helper()
+	info, ok := s.NICInfo()[nicID]
+	if !ok {
+		t.Fatalf("NIC %d not found", nicID)
+	}
+	return info.Flags.Promiscuous
+}
+
+func testFrame(dst tcpip.LinkAddress) []byte {
+	payload := []byte{1, 2, 3, 4}
+	frame := make([]byte, header.EthernetMinimumSize+len(payload))
+	header.Ethernet(frame).Encode(&header.EthernetFields{
+		SrcAddr: remoteAddr,
+		DstAddr: dst,
+		Type:    header.IPv4ProtocolNumber,
+	})
+	copy(frame[header.EthernetMinimumSize:], payload)
+	return frame
+}
+
+func TestIgnoreOutgoing(t *testing.T) {
+	s, ch := newTestStack(t)
+	sender := newTestEndpoint(t, s)
+	ep := newTestEndpoint(t, s)
+
+	write := func() {
+		t.Helper()
+		frame := testFrame(otherHost)
+		opts := tcpip.WriteOptions{To: &tcpip.FullAddress{NIC: nicID}}
+		if _, err := sender.Write(bytes.NewReader(frame), opts); err != nil {
+			t.Fatalf("sender.Write(_, %+v): %s", opts, err)
+		}
+		if n := ch.Drain(); n != 1 {
+			t.Fatalf("got %d packets written to the link, want 1", n)
+		}
+	}
+
+	write()
+	var buf bytes.Buffer
+	res, err := ep.Read(&buf, tcpip.ReadOptions{NeedLinkPacketInfo: true})
+	if err != nil {
+		t.Fatalf("ep.Read(_, _): %s", err)
+	}
+	if got, want := res.LinkPacketInfo.PktType, tcpip.PacketOutgoing; got != want {
+		t.Errorf("got PktType = %d, want %d", got, want)
+	}
+
+	ep.SocketOptions().SetIgnoreOutgoing(true)
+	write()
+	buf.Reset()
+	if _, err := ep.Read(&buf, tcpip.ReadOptions{}); err == nil {
+		t.Errorf("ep.Read(_, _) returned an outgoing packet with PACKET_IGNORE_OUTGOING set")
+	} else if _, ok := err.(*tcpip.ErrWouldBlock); !ok {
+		t.Fatalf("ep.Read(_, _): %s", err)
+	}
+
+	// Incoming packets are still received.
+	frame := testFrame(nicLinkAddr)
+	pkt := stack.NewPacketBuffer(stack.PacketBufferOptions{
+		Payload: buffer.MakeWithData(frame),
+	})
+	ch.InjectInbound(0, pkt)
+	pkt.DecRef()
+	buf.Reset()
+	if _, err := ep.Read(&buf, tcpip.ReadOptions{}); err != nil {
+		t.Fatalf("ep.Read(_, _): %s", err)
+	}
+	if !bytes.Equal(buf.Bytes(), frame) {
+		t.Errorf("got frame %x, want %x", buf.Bytes(), frame)
+	}
+}
+
+// TestPromiscuousMembership checks that promiscuous memberships are counted
+// across endpoints. Only the NIC's reported promiscuous flag is tracked, since
+// netstack delivers frames addressed to other hosts regardless.
+func TestPromiscuousMembership(t *testing.T) {
+	s, _ := newTestStack(t)
+	ep1 := newTestEndpoint(t, s)
+	ep2 := newTestEndpoint(t, s)
+
+	promisc := tcpip.PacketMembershipOption{NIC: nicID, Type: tcpip.PacketMembershipPromiscuous}
+	add := tcpip.AddPacketMembershipOption(promisc)
+	remove := tcpip.RemovePacketMembershipOption(promisc)
+
+	if nicPromiscuous(t, s) {
+		t.Fatalf("NIC is promiscuous before any membership was added")
+	}
+	for _, ep := range []tcpip.Endpoint{ep1, ep1, ep2} {
+		if err := ep.SetSockOpt(&add); err != nil {
+			t.Fatalf("SetSockOpt(%+v): %s", add, err)
+		}
+	}
+	if !nicPromiscuous(t, s) {
+		t.Errorf("NIC isn't promiscuous after adding memberships")
+	}
+
+	// The NIC stays promiscuous until every membership is dropped.
+	for _, ep := range []tcpip.Endpoint{ep1, ep2} {
+		if err := ep.SetSockOpt(&remove); err != nil {
+			t.Fatalf("SetSockOpt(%+v): %s", remove, err)
+		}
+		if !nicPromiscuous(t, s) {
+			t.Errorf("NIC isn't promiscuous while a membership remains")
+		}
+	}
+	if err := ep1.SetSockOpt(&remove); err != nil {
+		t.Fatalf("SetSockOpt(%+v): %s", remove, err)
+	}
+	if nicPromiscuous(t, s) {
+		t.Errorf("NIC is promiscuous after all memberships were dropped")
+	}
+	if err := ep1.SetSockOpt(&remove); err == nil {
+		t.Errorf("SetSockOpt(%+v) of a dropped membership succeeded, want error", remove)
+	}
+
+	// Closing an endpoint drops its memberships.
+	if err := ep2.SetSockOpt(&add); err != nil {
+		t.Fatalf("SetSockOpt(%+v): %s", add, err)
+	}
+	ep2.Close()
+	if nicPromiscuous(t, s) {
+		t.Errorf("NIC is promiscuous after the endpoint holding a membership was closed")
+	}
+}
+
+// TestLinkLayerMemberships checks that multicast, all-multicast and unicast
+// memberships are accepted and counted, but don't make the NIC promiscuous.
+func TestLinkLayerMemberships(t *testing.T) {
+	s, _ := newTestStack(t)
+	ep := newTestEndpoint(t, s)
+
+	const group = tcpip.LinkAddress("\x01\x80\xc2\x00\x00\x0e")
+	for _, m := range []tcpip.PacketMembershipOption{
+		{NIC: nicID, Type: tcpip.PacketMembershipMulticast, Addr: group},
+		{NIC: nicID, Type: tcpip.PacketMembershipAllMulticast},
+		{NIC: nicID, Type: tcpip.PacketMembershipUnicast, Addr: otherHost},
+	} {
+		add := tcpip.AddPacketMembershipOption(m)
+		remove := tcpip.RemovePacketMembershipOption(m)
+		for i := 0; i < 2; i++ {
+			if err := ep.SetSockOpt(&add); err != nil {
+				t.Fatalf("SetSockOpt(%+v): %s", add, err)
+			}
+		}
+		if nicPromiscuous(t, s) {
+			t.Errorf("NIC is promiscuous after adding %+v", add)
+		}
+
+		// A membership with another address is a different membership.
+		other := remove
+		other.Addr = remoteAddr
+		if err := ep.SetSockOpt(&other); err == nil {
+			t.Errorf("SetSockOpt(%+v) of a membership never added succeeded, want error", other)
+		}
+
+		for i := 0; i < 2; i++ {
+			if err := ep.SetSockOpt(&remove); err != nil {
+				t.Fatalf("SetSockOpt(%+v): %s", remove, err)
+			}
+		}
+		if err := ep.SetSockOpt(&remove); err == nil {
+			t.Errorf("SetSockOpt(%+v) of a dropped membership succeeded, want error", remove)
+		}
+	}
+}
+
+func TestMembershipInvalid(t *testing.T) {
+	s, _ := newTestStack(t)
+	ep := newTestEndpoint(t, s)
+
+	unknownNIC := tcpip.AddPacketMembershipOption{NIC: nicID + 1, Type: tcpip.PacketMembershipPromiscuous}
+	err := ep.SetSockOpt(&unknownNIC)
+	if _, ok := err.(*tcpip.ErrUnknownDevice); !ok {
+		t.Errorf("SetSockOpt(%+v) = %v, want %s", unknownNIC, err, &tcpip.ErrUnknownDevice{})
+	}
+	unknownType := tcpip.AddPacketMembershipOption{NIC: nicID, Type: tcpip.PacketMembershipUnicast + 1}
+	err = ep.SetSockOpt(&unknownType)
+	if _, ok := err.(*tcpip.ErrInvalidOptionValue); !ok {
+		t.Errorf("SetSockOpt(%+v) = %v, want %s", unknownType, err, &tcpip.ErrInvalidOptionValue{})
+	}
+}
+
+// TestOtherHostDelivered checks that frames addressed to other hosts reach
+// packet endpoints without a promiscuous membership, as netstack NICs don't
+// filter them.
+func TestOtherHostDelivered(t *testing.T) {
+	s, ch := newTestStack(t)
+	ep := newTestEndpoint(t, s)
+
+	frame := testFrame(otherHost)
+	pkt := stack.NewPacketBuffer(stack.PacketBufferOptions{
+		Payload: buffer.MakeWithData(frame),
+	})
+	ch.InjectInbound(0, pkt)
+	pkt.DecRef()
+
+	var buf bytes.Buffer
+	res, err := ep.Read(&buf, tcpip.ReadOptions{NeedLinkPacketInfo: true})
+	if err != nil {
+		t.Fatalf("ep.Read(_, _): %s", err)
+	}
+	if got, want := res.LinkPacketInfo.PktType, tcpip.PacketOtherHost; got != want {
+		t.Errorf("got PktType = %d, want %d", got, want)
+	}
+	if !bytes.Equal(buf.Bytes(), frame) {
+		t.Errorf("got frame %x, want %x", buf.Bytes(), frame)
+	}
+}
+
+func TestTruncatedRead(t *testing.T) {
+	s, ch := newTestStack(t)
+	ep := newTestEndpoint(t, s)
+
+	frame := testFrame(nicLinkAddr)
+	for i := 0; i < 2; i++ {
+		pkt := stack.NewPacketBuffer(stack.PacketBufferOptions{
+			Payload: buffer.MakeWithData(frame),
+		})
+		ch.InjectInbound(0, pkt)
+		pkt.DecRef()
+	}
+
+	const bufSize = 4
+	for _, peek := range []bool{true, false} {
+		var buf bytes.Buffer
+		res, err := ep.Read(&tcpip.LimitedWriter{W: &buf, N: bufSize}, tcpip.ReadOptions{Peek: peek})
+		if err != nil {
+			t.Fatalf("ep.Read(_, {Peek: %t}): %s", peek, err)
+		}
+		// The copied length and the full frame length are both reported, so
+		// that recvmsg can return either depending on MSG_TRUNC.
+		if res.Count != bufSize || res.Total != len(frame) {
+			t.Errorf("ep.Read(_, {Peek: %t}) = {Count: %d, Total: %d}, want {Count: %d, Total: %d}", peek, res.Count, res.Total, bufSize, len(frame))
+		}
+		if !bytes.Equal(buf.Bytes(), frame[:bufSize]) {
+			t.Errorf("got data %x, want %x", buf.Bytes(), frame[:bufSize])
+		}
+	}
+
+	// The rest of a truncated frame is discarded rather than returned by the
+	// next read.
+	var buf bytes.Buffer
+	res, err := ep.Read(&buf, tcpip.ReadOptions{})
+	if err != nil {
+		t.Fatalf("ep.Read(_, _): %s", err)
+	}
+	if res.Count != len(frame) || !bytes.Equal(buf.Bytes(), frame) {
+		t.Errorf("got %d bytes %x, want the second frame %x", res.Count, buf.Bytes(), frame)
+	}
+}
+
+// countingQDisc is a queueing discipline that counts and drops the packets
+// written to it.
+type countingQDisc struct {
+	packets int
+}
+
+// WritePacket implements stack.QueueingDiscipline.WritePacket.
+func (q *countingQDisc) WritePacket(stack.PacketBufferPtr) tcpip.Error {
+	q.packets++
+	return nil
+}
+
+// Close implements stack.QueueingDiscipline.Close.
+func (*countingQDisc) Close() {}
+
+func TestQdiscBypass(t *testing.T) {
+	for _, cooked := range []bool{false, true} {
+		t.Run(fmt.Sprintf("cooked=%t", cooked), func(t *testing.T) {
+			var qDisc countingQDisc
+			s, ch := newTestStackWithOptions(t, stack.NICOptions{QDisc: &qDisc})
+			ep := newTestEndpointWithProtocol(t, s, cooked, header.EthernetProtocolAll)
+
+			data := testFrame(otherHost)
+			if cooked {
+				data = data[header.EthernetMinimumSize:]
+			}
+			opts := tcpip.WriteOptions{To: &tcpip.FullAddress{
+				NIC:      nicID,
+				LinkAddr: otherHost,
+				Port:     uint16(header.IPv4ProtocolNumber),
+			}}
+			write := func() {
+				t.Helper()
+				if _, err := ep.Write(bytes.NewReader(data), opts); err != nil {
+					t.Fatalf("ep.Write(_, %+v): %s", opts, err)
+				}
+			}
+
+			write()
+			if qDisc.packets != 1 {
+				t.Errorf("got %d packets written to the qdisc, want 1", qDisc.packets)
+			}
+			if n := ch.Drain(); n != 0 {
+				t.Errorf("got %d packets written to the link, want 0", n)
+			}
+
+			// With PACKET_QDISC_BYPASS, frames skip the qdisc.
+			ep.SocketOptions().SetQdiscBypass(true)
+			write()
+			if qDisc.packets != 1 {
+				t.Errorf("got %d packets written to the qdisc, want 1", qDisc.packets)
+			}
+			if n := ch.Drain(); n != 1 {
+				t.Errorf("got %d packets written to the link, want 1", n)
+			}
+		})
+	}
+}
+
+func TestVirtioNetHeaderNotDelivered(t *testing.T) {
+	frame := testFrame(nicLinkAddr)
+	for _, tc := range []struct {
+		cooked bool
+		want   []byte
+	}{
+		// Raw endpoints receive the link header but not the virtio-net
+		// header preceding it.
+		{cooked: false, want: frame},
+		{cooked: true, want: frame[header.EthernetMinimumSize:]},
+	} {
+		t.Run(fmt.Sprintf("cooked=%t", tc.cooked), func(t *testing.T) {
+			s, _ := newTestStack(t)
+			ep := newTestEndpointWithProtocol(t, s, tc.cooked, header.EthernetProtocolAll)
+
+			data := make([]byte, header.VirtioNetHeaderSize, header.VirtioNetHeaderSize+len(frame))
+			for i := range data {
+				data[i] = 0xff
+			}
+			data = append(data, frame...)
+			pkt := stack.NewPacketBuffer(stack.PacketBufferOptions{
+				Payload: buffer.MakeWithData(data),
+			})
+			defer pkt.DecRef()
+			if _, ok := pkt.VirtioNetHeader().Consume(header.VirtioNetHeaderSize); !ok {
+				t.Fatalf("pkt.VirtioNetHeader().Consume(%d) failed", header.VirtioNetHeaderSize)
+			}
+			if _, ok := pkt.LinkHeader().Consume(header.EthernetMinimumSize); !ok {
+				t.Fatalf("pkt.LinkHeader().Consume(%d) failed", header.EthernetMinimumSize)
+			}
+			ep.(stack.PacketEndpoint).HandlePacket(nicID, header.IPv4ProtocolNumber, pkt)
+
+			var buf bytes.Buffer
+			res, err := ep.Read(&buf, tcpip.ReadOptions{NeedRemoteAddr: true})
+			if err != nil {
+				t.Fatalf("ep.Read(_, _): %s", err)
+			}
+			if !bytes.Equal(buf.Bytes(), tc.want) {
+				t.Errorf("got data %x, want %x", buf.Bytes(), tc.want)
+			}
+			if got := res.RemoteAddr.LinkAddr; got != remoteAddr {
+				t.Errorf("got sender address %s, want %s", got, remoteAddr)
+			}
+		})
+	}
+}
+
+func TestReadinessEmptyPayload(t *testing.T) {
+	s, ch := newTestStack(t)
+	var wq waiter.Queue
+	ep, err := packet.NewEndpoint(s, true /* cooked */, header.EthernetProtocolAll, &wq)
+	if err != nil {
+		t.Fatalf("packet.NewEndpoint(_, true, %d, _): %s", header.EthernetProtocolAll, err)
+	}
+	defer ep.Close()
+	we, notifyCh := waiter.NewChannelEntry(waiter.ReadableEvents)
+	wq.EventRegister(&we)
+	defer wq.EventUnregister(&we)
+
+	if got := ep.Readiness(waiter.ReadableEvents); got != 0 {
+		t.Errorf("got ep.Readiness(%#x) = %#x with no packets, want 0", waiter.ReadableEvents, got)
+	}
+	if got := ep.Readiness(waiter.WritableEvents); got != waiter.WritableEvents {
+		t.Errorf("got ep.Readiness(%#x) = %#x, want %#x", waiter.WritableEvents, got, waiter.WritableEvents)
+	}
+
+	// A frame without payload queues an empty packet on cooked endpoints,
+	// which is still readable.
+	inject := func() {
+		t.Helper()
+		frame := testFrame(nicLinkAddr)[:header.EthernetMinimumSize]
+		pkt := stack.NewPacketBuffer(stack.PacketBufferOptions{
+			Payload: buffer.MakeWithData(frame),
+		})
+		ch.InjectInbound(0, pkt)
+		pkt.DecRef()
+	}
+	inject()
+	select {
+	case <-notifyCh:
+	default:
+		t.Errorf("readers weren't notified of an empty packet")
+	}
+	if got := ep.Readiness(waiter.ReadableEvents); got != waiter.ReadableEvents {
+		t.Errorf("got ep.Readiness(%#x) = %#x with an empty packet queued, want %#x", waiter.ReadableEvents, got, waiter.ReadableEvents)
+	}
+
+	// Readers are only notified when the receive queue becomes non-empty.
+	inject()
+	select {
+	case <-notifyCh:
+		t.Errorf("readers were notified of a packet queued behind an empty packet")
+	default:
+	}
+
+	for i := 0; i < 2; i++ {
+		var buf bytes.Buffer
+		res, err := ep.Read(&buf, tcpip.ReadOptions{})
+		if err != nil {
+			t.Fatalf("ep.Read(_, _): %s", err)
+		}
+		if res.Total != 0 {
+			t.Errorf("got %d bytes, want 0", res.Total)
+		}
+	}
+	if got := ep.Readiness(waiter.ReadableEvents); got != 0 {
+		t.Errorf("got ep.Readiness(%#x) = %#x after reading every packet, want 0", waiter.ReadableEvents, got)
+	}
+}
+
+func TestBindEtherType(t *testing.T) {
+	s, ch := newTestStack(t)
+	all := newTestEndpoint(t, s)
+	ipv4 := newTestEndpoint(t, s)
+	addr := tcpip.FullAddress{NIC: nicID, Port: uint16(header.IPv4ProtocolNumber)}
+	if err := ipv4.Bind(addr); err != nil {
+		t.Fatalf("ipv4.Bind(%+v): %s", addr, err)
+	}
+
+	inject := func(netProto tcpip.NetworkProtocolNumber) []byte {
+		t.Helper()
+		frame := testFrame(nicLinkAddr)
+		header.Ethernet(frame).Encode(&header.EthernetFields{
+			SrcAddr: remoteAddr,
+			DstAddr: nicLinkAddr,
+			Type:    netProto,
+		})
+		pkt := stack.NewPacketBuffer(stack.PacketBufferOptions{
+			Payload: buffer.MakeWithData(frame),
+		})
+		ch.InjectInbound(0, pkt)
+		pkt.DecRef()
+		return frame
+	}
+	read := func(ep tcpip.Endpoint) ([]byte, tcpip.Error) {
+		t.Helper()
+		var buf bytes.Buffer
+		_, err := ep.Read(&buf, tcpip.ReadOptions{})
+		return buf.Bytes(), err
+	}
+
+	// Frames of other EtherTypes are only delivered to the ETH_P_ALL
+	// endpoint.
+	ipv6Frame := inject(header.IPv6ProtocolNumber)
+	if got, err := read(all); err != nil || !bytes.Equal(got, ipv6Frame) {
+		t.Errorf("got all.Read(_, _) = (%x, %v), want (%x, nil)", got, err, ipv6Frame)
+	}
+	if _, err := read(ipv4); err == nil {
+		t.Errorf("ipv4.Read(_, _) returned an IPv6 frame")
+	} else if _, ok := err.(*tcpip.ErrWouldBlock); !ok {
+		t.Fatalf("ipv4.Read(_, _): %s", err)
+	}
+
+	ipv4Frame := inject(header.IPv4ProtocolNumber)
+	for name, ep := range map[string]tcpip.Endpoint{"all": all, "ipv4": ipv4} {
+		if got, err := read(ep); err != nil || !bytes.Equal(got, ipv4Frame) {
+			t.Errorf("got %s.Read(_, _) = (%x, %v), want (%x, nil)", name, got, err, ipv4Frame)
+		}
+	}
+
+	// The protocol can't be unbound.
+	unbind := tcpip.FullAddress{NIC: nicID}
+	if err := ipv4.Bind(unbind); err != nil {
+		t.Fatalf("ipv4.Bind(%+v): %s", unbind, err)
+	}
+	inject(header.IPv6ProtocolNumber)
+	if _, err := read(ipv4); err == nil {
+		t.Errorf("ipv4.Read(_, _) returned an IPv6 frame after binding without a protocol")
+	} else if _, ok := err.(*tcpip.ErrWouldBlock); !ok {
+		t.Fatalf("ipv4.Read(_, _): %s", err)
+	}
+}
@@ -45,6 +45,34 @@ func (p *packet) StateLoad(stateSourceObject state.Source) {
 	stateSourceObject.LoadValue(2, new(int64), func(y any) { p.loadReceivedAt(y.(int64)) })
 }
 
+func (m *membership) StateTypeName() string {
+	return "pkg/tcpip/transport/packet.membership"
+}
+
+func (m *membership) StateFields() []string {
+	return []string{
+		"PacketMembershipOption",
+		"count",
+	}
+}
+
+func (m *membership) beforeSave() {}
+
+// +checklocksignore
+func (m *membership) StateSave(stateSinkObject state.Sink) {
+	m.beforeSave()
+	stateSinkObject.Save(0, &m.PacketMembershipOption)
+	stateSinkObject.Save(1, &m.count)
+}
+
+func (m *membership) afterLoad() {}
+
+// +checklocksignore
+func (m *membership) StateLoad(stateSourceObject state.Source) {
+	stateSourceObject.Load(0, &m.PacketMembershipOption)
+	stateSourceObject.Load(1, &m.count)
+}
+
 func (ep *endpoint) StateTypeName() string {
 	return "pkg/tcpip/transport/packet.endpoint"
 }
@@ -63,6 +91,7 @@ func (ep *endpoint) StateFields() []string {
 		"closed",
 		"boundNetProto",
 		"boundNIC",
+		"memberships",
 		"lastError",
 	}
 }
@@ -82,7 +111,8 @@ func (ep *endpoint) StateSave(stateSinkObject state.Sink) {
 	stateSinkObject.Save(9, &ep.closed)
 	stateSinkObject.Save(10, &ep.boundNetProto)
 	stateSinkObject.Save(11, &ep.boundNIC)
-	stateSinkObject.Save(12, &ep.lastError)
+	stateSinkObject.Save(12, &ep.memberships)
+	stateSinkObject.Save(13, &ep.lastError)
 }
 
 // +checklocksignore
@@ -99,7 +129,8 @@ func (ep *endpoint) StateLoad(stateSourceObject state.Source) {
 	stateSourceObject.Load(9, &ep.closed)
 	stateSourceObject.Load(10, &ep.boundNetProto)
 	stateSourceObject.Load(11, &ep.boundNIC)
-	stateSourceObject.Load(12, &ep.lastError)
+	stateSourceObject.Load(12, &ep.memberships)
+	stateSourceObject.Load(13, &ep.lastError)
 	stateSourceObject.AfterLoad(ep.afterLoad)
 }
 
@@ -161,6 +192,7 @@ func (e *packetEntry) StateLoad(stateSourceObject state.Source) {
 
 func init() {
 	state.Register((*packet)(nil))
+	state.Register((*membership)(nil))
 	state.Register((*endpoint)(nil))
 	state.Register((*packetList)(nil))
 	state.Register((*packetEntry)(nil))
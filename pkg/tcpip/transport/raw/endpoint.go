@@ -57,6 +57,10 @@ type rawPacket struct {
 	tosOrTClass uint8
 	// ttlOrHopLimit stores either the TTL for IPv4 or the HopLimit for IPv6
 	ttlOrHopLimit uint8
+
+	// dropCount is the number of packets dropped by the endpoint before this
+	// packet was queued. It is reported via SO_RXQ_OVFL.
+	dropCount uint32
 }
 
 // endpoint is the raw socket implementation of tcpip.Endpoint. It is legal to
@@ -91,6 +95,11 @@ type endpoint struct {
 	rcvClosed bool
 	// +checklocks:rcvMu
 	rcvDisabled bool
+	// rcvDropped is the number of packets dropped because the receive queue
+	// was full.
+	//
+	// +checklocks:rcvMu
+	rcvDropped uint32
 
 	mu sync.RWMutex `state:"nosave"`
 
@@ -285,6 +294,11 @@ func (e *endpoint) Read(dst io.Writer, opts tcpip.ReadOptions) (tcpip.ReadResult
 	default:
 		panic(fmt.Sprintf("unrecognized network protocol = %d", netProto))
 	}
+	// Like Linux, only report the drop count once packets have been dropped.
+	if e.ops.GetRxqOvfl() && pkt.dropCount != 0 {
+		cm.HasDropCount = true
+		cm.DropCount = pkt.dropCount
+	}
 
 	res := tcpip.ReadResult{
 		Total:           pkt.data.Data().Size(),
@@ -609,6 +623,7 @@ func (e *endpoint) HandlePacket(pkt stack.PacketBufferPtr) {
 
 		rcvBufSize := e.ops.GetReceiveBufferSize()
 		if e.rcvDisabled || e.rcvBufSize >= int(rcvBufSize) {
+			e.rcvDropped++
 			e.stack.Stats().DroppedPackets.Increment()
 			e.stats.ReceiveErrors.ReceiveBufferOverflow.Increment()
 			return false
@@ -661,6 +676,7 @@ func (e *endpoint) HandlePacket(pkt stack.PacketBufferPtr) {
 				DestinationAddr: dstAddr,
 				NIC:             pkt.NICID,
 			},
+			dropCount: e.rcvDropped,
 		}
 
 		// Save any useful information from the network header to the packet.
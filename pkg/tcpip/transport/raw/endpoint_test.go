@@ -0,0 +1,116 @@
+// Copyright 2023 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raw_test
+
+import (
+	"bytes"
+	"testing"
+
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"gvisor.dev/gvisor/pkg/tcpip/link/loopback"
+	"gvisor.dev/gvisor/pkg/tcpip/network/ipv4"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+	"gvisor.dev/gvisor/pkg/tcpip/transport/raw"
+	"gvisor.dev/gvisor/pkg/tcpip/transport/udp"
+	"gvisor.dev/gvisor/pkg/waiter"
+)
+
+const (
+	nicID   = 1
+	rcvPort = 1234
+)
+
+func TestReceiveQueueOverflowDropCount(t *testing.T) {
+	s := stack.New(stack.Options{
+		NetworkProtocols:   []stack.NetworkProtocolFactory{ipv4.NewProtocol},
+		TransportProtocols: []stack.TransportProtocolFactory{udp.NewProtocol},
+	})
+	defer s.Close()
+	if err := s.CreateNIC(nicID, loopback.New()); err != nil {
+		t.Fatalf("s.CreateNIC(%d, _): %s", nicID, err)
+	}
+	protoAddr := tcpip.ProtocolAddress{
+		Protocol:          header.IPv4ProtocolNumber,
+		AddressWithPrefix: tcpip.AddrFrom4([4]byte{127, 0, 0, 1}).WithPrefix(),
+	}
+	if err := s.AddProtocolAddress(nicID, protoAddr, stack.AddressProperties{}); err != nil {
+		t.Fatalf("s.AddProtocolAddress(%d, %+v, {}): %s", nicID, protoAddr, err)
+	}
+	s.SetRouteTable([]tcpip.Route{{Destination: header.IPv4EmptySubnet, NIC: nicID}})
+
+	var rwq, swq waiter.Queue
+	rep, err := raw.NewEndpoint(s, header.IPv4ProtocolNumber, udp.ProtocolNumber, &rwq)
+	if err != nil {
+		t.Fatalf("raw.NewEndpoint(_, ipv4, udp, _): %s", err)
+	}
+	defer rep.Close()
+	to := tcpip.FullAddress{Addr: protoAddr.AddressWithPrefix.Address, Port: rcvPort}
+	// Any queued packet fills the receive queue.
+	rep.SocketOptions().SetReceiveBufferSize(1, false /* notify */)
+	rep.SocketOptions().SetRxqOvfl(true)
+
+	sep, err := s.NewEndpoint(udp.ProtocolNumber, header.IPv4ProtocolNumber, &swq)
+	if err != nil {
+		t.Fatalf("s.NewEndpoint(udp, ipv4, _): %s", err)
+	}
+	defer sep.Close()
+	send := func(b byte) {
+		t.Helper()
+		opts := tcpip.WriteOptions{To: &to}
+		if _, err := sep.Write(bytes.NewReader([]byte{b}), opts); err != nil {
+			t.Fatalf("sep.Write(_, %+v): %s", opts, err)
+		}
+	}
+	read := func() tcpip.ReadResult {
+		t.Helper()
+		var buf bytes.Buffer
+		res, err := rep.Read(&buf, tcpip.ReadOptions{})
+		if err != nil {
+			t.Fatalf("rep.Read(_, _): %s", err)
+		}
+		return res
+	}
+
+	// Nothing has been dropped yet, so no drop count is reported.
+	send(0)
+	if res := read(); res.ControlMessages.HasDropCount {
+		t.Errorf("got drop count %d before any drops, want none", res.ControlMessages.DropCount)
+	}
+
+	const dropped = 3
+	for i := 0; i <= dropped; i++ {
+		send(byte(i))
+	}
+	// The first packet was queued before the others were dropped.
+	res := read()
+	if res.ControlMessages.HasDropCount {
+		t.Errorf("got drop count %d for the packet queued before the drops, want none", res.ControlMessages.DropCount)
+	}
+
+	// Packets queued after the drops report the total.
+	send(0)
+	res = read()
+	if !res.ControlMessages.HasDropCount || res.ControlMessages.DropCount != dropped {
+		t.Errorf("got drop count (%t, %d), want (true, %d)", res.ControlMessages.HasDropCount, res.ControlMessages.DropCount, dropped)
+	}
+
+	// The count isn't reported unless SO_RXQ_OVFL is set.
+	rep.SocketOptions().SetRxqOvfl(false)
+	send(0)
+	if res := read(); res.ControlMessages.HasDropCount {
+		t.Errorf("got drop count %d with SO_RXQ_OVFL unset, want none", res.ControlMessages.DropCount)
+	}
+}
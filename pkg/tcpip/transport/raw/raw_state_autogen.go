@@ -19,6 +19,7 @@ func (p *rawPacket) StateFields() []string {
 		"packetInfo",
 		"tosOrTClass",
 		"ttlOrHopLimit",
+		"dropCount",
 	}
 }
 
@@ -36,6 +37,7 @@ func (p *rawPacket) StateSave(stateSinkObject state.Sink) {
 	stateSinkObject.Save(4, &p.packetInfo)
 	stateSinkObject.Save(5, &p.tosOrTClass)
 	stateSinkObject.Save(6, &p.ttlOrHopLimit)
+	stateSinkObject.Save(7, &p.dropCount)
 }
 
 func (p *rawPacket) afterLoad() {}
@@ -48,6 +50,7 @@ func (p *rawPacket) StateLoad(stateSourceObject state.Source) {
 	stateSourceObject.Load(4, &p.packetInfo)
 	stateSourceObject.Load(5, &p.tosOrTClass)
 	stateSourceObject.Load(6, &p.ttlOrHopLimit)
+	stateSourceObject.Load(7, &p.dropCount)
 	stateSourceObject.LoadValue(2, new(int64), func(y any) { p.loadReceivedAt(y.(int64)) })
 }
 
@@ -68,6 +71,7 @@ func (e *endpoint) StateFields() []string {
 		"rcvBufSize",
 		"rcvClosed",
 		"rcvDisabled",
+		"rcvDropped",
 		"ipv6ChecksumOffset",
 		"icmpv6Filter",
 	}
@@ -87,8 +91,9 @@ func (e *endpoint) StateSave(stateSinkObject state.Sink) {
 	stateSinkObject.Save(8, &e.rcvBufSize)
 	stateSinkObject.Save(9, &e.rcvClosed)
 	stateSinkObject.Save(10, &e.rcvDisabled)
-	stateSinkObject.Save(11, &e.ipv6ChecksumOffset)
-	stateSinkObject.Save(12, &e.icmpv6Filter)
+	stateSinkObject.Save(11, &e.rcvDropped)
+	stateSinkObject.Save(12, &e.ipv6ChecksumOffset)
+	stateSinkObject.Save(13, &e.icmpv6Filter)
 }
 
 // +checklocksignore
@@ -104,8 +109,9 @@ func (e *endpoint) StateLoad(stateSourceObject state.Source) {
 	stateSourceObject.Load(8, &e.rcvBufSize)
 	stateSourceObject.Load(9, &e.rcvClosed)
 	stateSourceObject.Load(10, &e.rcvDisabled)
-	stateSourceObject.Load(11, &e.ipv6ChecksumOffset)
-	stateSourceObject.Load(12, &e.icmpv6Filter)
+	stateSourceObject.Load(11, &e.rcvDropped)
+	stateSourceObject.Load(12, &e.ipv6ChecksumOffset)
+	stateSourceObject.Load(13, &e.icmpv6Filter)
 	stateSourceObject.AfterLoad(e.afterLoad)
 }
 
@@ -47,6 +47,10 @@ type udpPacket struct {
 	tosOrTClass uint8
 	// ttlOrHopLimit stores either the TTL for IPv4 or the HopLimit for IPv6
 	ttlOrHopLimit uint8
+
+	// dropCount is the number of packets dropped by the endpoint before this
+	// packet was queued. It is reported via SO_RXQ_OVFL.
+	dropCount uint32
 }
 
 // endpoint represents a UDP endpoint. This struct serves as the interface
@@ -76,6 +80,9 @@ type endpoint struct {
 	rcvList    udpPacketList
 	rcvBufSize int
 	rcvClosed  bool
+	// rcvDropped is the number of packets dropped because the receive queue
+	// was full.
+	rcvDropped uint32
 
 	lastErrorMu sync.Mutex `state:"nosave"`
 	lastError   tcpip.Error
@@ -289,6 +296,11 @@ func (e *endpoint) Read(dst io.Writer, opts tcpip.ReadOptions) (tcpip.ReadResult
 		cm.HasOriginalDstAddress = true
 		cm.OriginalDstAddress = p.destinationAddress
 	}
+	// Like Linux, only report the drop count once packets have been dropped.
+	if e.ops.GetRxqOvfl() && p.dropCount != 0 {
+		cm.HasDropCount = true
+		cm.DropCount = p.dropCount
+	}
 
 	// Read Result
 	res := tcpip.ReadResult{
@@ -948,6 +960,7 @@ func (e *endpoint) HandlePacket(id stack.TransportEndpointID, pkt stack.PacketBu
 	rcvBufSize := e.ops.GetReceiveBufferSize()
 	// Drop the packet if our buffer is currently full.
 	if e.frozen || e.rcvBufSize >= int(rcvBufSize) {
+		e.rcvDropped++
 		e.rcvMu.Unlock()
 		e.stack.Stats().UDP.ReceiveBufferErrors.Increment()
 		e.stats.ReceiveErrors.ReceiveBufferOverflow.Increment()
@@ -969,7 +982,8 @@ func (e *endpoint) HandlePacket(id stack.TransportEndpointID, pkt stack.PacketBu
 			Addr: id.LocalAddress,
 			Port: hdr.DestinationPort(),
 		},
-		pkt: pkt.IncRef(),
+		pkt:       pkt.IncRef(),
+		dropCount: e.rcvDropped,
 	}
 	e.rcvList.PushBack(packet)
 	e.rcvBufSize += pkt.Data().Size()
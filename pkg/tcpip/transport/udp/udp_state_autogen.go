@@ -21,6 +21,7 @@ func (p *udpPacket) StateFields() []string {
 		"receivedAt",
 		"tosOrTClass",
 		"ttlOrHopLimit",
+		"dropCount",
 	}
 }
 
@@ -40,6 +41,7 @@ func (p *udpPacket) StateSave(stateSinkObject state.Sink) {
 	stateSinkObject.Save(5, &p.pkt)
 	stateSinkObject.Save(7, &p.tosOrTClass)
 	stateSinkObject.Save(8, &p.ttlOrHopLimit)
+	stateSinkObject.Save(9, &p.dropCount)
 }
 
 func (p *udpPacket) afterLoad() {}
@@ -54,6 +56,7 @@ func (p *udpPacket) StateLoad(stateSourceObject state.Source) {
 	stateSourceObject.Load(5, &p.pkt)
 	stateSourceObject.Load(7, &p.tosOrTClass)
 	stateSourceObject.Load(8, &p.ttlOrHopLimit)
+	stateSourceObject.Load(9, &p.dropCount)
 	stateSourceObject.LoadValue(6, new(int64), func(y any) { p.loadReceivedAt(y.(int64)) })
 }
 
@@ -73,6 +76,7 @@ func (e *endpoint) StateFields() []string {
 		"rcvList",
 		"rcvBufSize",
 		"rcvClosed",
+		"rcvDropped",
 		"lastError",
 		"portFlags",
 		"boundBindToDevice",
@@ -98,15 +102,16 @@ func (e *endpoint) StateSave(stateSinkObject state.Sink) {
 	stateSinkObject.Save(7, &e.rcvList)
 	stateSinkObject.Save(8, &e.rcvBufSize)
 	stateSinkObject.Save(9, &e.rcvClosed)
-	stateSinkObject.Save(10, &e.lastError)
-	stateSinkObject.Save(11, &e.portFlags)
-	stateSinkObject.Save(12, &e.boundBindToDevice)
-	stateSinkObject.Save(13, &e.boundPortFlags)
-	stateSinkObject.Save(14, &e.readShutdown)
-	stateSinkObject.Save(15, &e.effectiveNetProtos)
-	stateSinkObject.Save(16, &e.frozen)
-	stateSinkObject.Save(17, &e.localPort)
-	stateSinkObject.Save(18, &e.remotePort)
+	stateSinkObject.Save(10, &e.rcvDropped)
+	stateSinkObject.Save(11, &e.lastError)
+	stateSinkObject.Save(12, &e.portFlags)
+	stateSinkObject.Save(13, &e.boundBindToDevice)
+	stateSinkObject.Save(14, &e.boundPortFlags)
+	stateSinkObject.Save(15, &e.readShutdown)
+	stateSinkObject.Save(16, &e.effectiveNetProtos)
+	stateSinkObject.Save(17, &e.frozen)
+	stateSinkObject.Save(18, &e.localPort)
+	stateSinkObject.Save(19, &e.remotePort)
 }
 
 // +checklocksignore
@@ -121,15 +126,16 @@ func (e *endpoint) StateLoad(stateSourceObject state.Source) {
 	stateSourceObject.Load(7, &e.rcvList)
 	stateSourceObject.Load(8, &e.rcvBufSize)
 	stateSourceObject.Load(9, &e.rcvClosed)
-	stateSourceObject.Load(10, &e.lastError)
-	stateSourceObject.Load(11, &e.portFlags)
-	stateSourceObject.Load(12, &e.boundBindToDevice)
-	stateSourceObject.Load(13, &e.boundPortFlags)
-	stateSourceObject.Load(14, &e.readShutdown)
-	stateSourceObject.Load(15, &e.effectiveNetProtos)
-	stateSourceObject.Load(16, &e.frozen)
-	stateSourceObject.Load(17, &e.localPort)
-	stateSourceObject.Load(18, &e.remotePort)
+	stateSourceObject.Load(10, &e.rcvDropped)
+	stateSourceObject.Load(11, &e.lastError)
+	stateSourceObject.Load(12, &e.portFlags)
+	stateSourceObject.Load(13, &e.boundBindToDevice)
+	stateSourceObject.Load(14, &e.boundPortFlags)
+	stateSourceObject.Load(15, &e.readShutdown)
+	stateSourceObject.Load(16, &e.effectiveNetProtos)
+	stateSourceObject.Load(17, &e.frozen)
+	stateSourceObject.Load(18, &e.localPort)
+	stateSourceObject.Load(19, &e.remotePort)
 	stateSourceObject.AfterLoad(e.afterLoad)
 }
 
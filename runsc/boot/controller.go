@@ -103,6 +103,12 @@ const (
 
 	// DebugStacks collects sandbox stacks for debugging.
 	DebugStacks = "debug.Stacks"
+
+	// DebugSetStubFastPath switches the systrap stub fast path on or off.
+	DebugSetStubFastPath = "debug.SetStubFastPath"
+
+	// DebugWakeSemaphoreWaiter wakes up a process blocked in semop(2).
+	DebugWakeSemaphoreWaiter = "debug.WakeSemaphoreWaiter"
 )
 
 // Profiling related commands (see pprof.go for more details).
@@ -177,7 +183,7 @@ func newController(fd int, l *Loader) (*controller, error) {
 	ctrl.srv.Register(&control.State{Kernel: l.k})
 	ctrl.srv.Register(&control.Usage{Kernel: l.k})
 	ctrl.srv.Register(&control.Metrics{})
-	ctrl.srv.Register(&debug{})
+	ctrl.srv.Register(&debug{k: l.k})
 
 	if eps, ok := l.k.RootNetworkNamespace().Stack().(*netstack.Stack); ok {
 		ctrl.srv.Register(&Network{Stack: eps.Stack})
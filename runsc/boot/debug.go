@@ -15,10 +15,15 @@
 package boot
 
 import (
+	"fmt"
+
 	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/pkg/sentry/platform/systrap"
 )
 
 type debug struct {
+	k *kernel.Kernel
 }
 
 // Stacks collects all sandbox stacks and copies them to 'stacks'.
@@ -27,3 +32,30 @@ func (*debug) Stacks(_ *struct{}, stacks *string) error {
 	*stacks = string(buf)
 	return nil
 }
+
+// SetStubFastPath switches the fast path in systrap stub processes on or off.
+// It fails if the sandbox doesn't run on the systrap platform.
+func (d *debug) SetStubFastPath(enabled *bool, _ *struct{}) error {
+	if _, ok := d.k.Platform.(*systrap.Systrap); !ok {
+		return fmt.Errorf("the stub fast path is only supported on the systrap platform")
+	}
+	systrap.SetStubFastPathEnabled(*enabled)
+	return nil
+}
+
+// WakeSemaphoreWaiter wakes up a semop(2) call blocked on behalf of the process
+// with the given PID, which then fails with EINTR. found is set to whether such
+// a call was found.
+func (d *debug) WakeSemaphoreWaiter(pid *int32, found *bool) error {
+	tg := d.k.TaskSet().Root.ThreadGroupWithID(kernel.ThreadID(*pid))
+	if tg == nil {
+		return fmt.Errorf("no process with PID %d", *pid)
+	}
+	ns := tg.Leader().GetIPCNamespace()
+	if ns == nil {
+		return fmt.Errorf("process %d has exited", *pid)
+	}
+	defer ns.DecRef(d.k.SupervisorContext())
+	*found = ns.SemaphoreRegistry().WakeWaiterByPID(*pid)
+	return nil
+}
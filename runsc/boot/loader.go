@@ -442,6 +442,7 @@ func New(args Args) (*Loader, error) {
 		RootIPCNamespace:            kernel.NewIPCNamespace(creds.UserNamespace),
 		RootAbstractSocketNamespace: kernel.NewAbstractSocketNamespace(),
 		PIDNamespace:                kernel.NewRootPIDNamespace(creds.UserNamespace),
+		RTSignalQueueLimit:          args.Conf.RTSignalQueueLimit,
 	}); err != nil {
 		return nil, fmt.Errorf("initializing kernel: %w", err)
 	}
@@ -46,6 +46,8 @@ type Debug struct {
 	strace       string
 	logLevel     string
 	logPackets   string
+	stubFastPath string
+	wakeSemPID   int
 	delay        time.Duration
 	duration     time.Duration
 	ps           bool
@@ -81,6 +83,8 @@ func (d *Debug) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&d.strace, "strace", "", `A comma separated list of syscalls to trace. "all" enables all traces, "off" disables all.`)
 	f.StringVar(&d.logLevel, "log-level", "", "The log level to set: warning (0), info (1), or debug (2).")
 	f.StringVar(&d.logPackets, "log-packets", "", "A boolean value to enable or disable packet logging: true or false.")
+	f.StringVar(&d.stubFastPath, "systrap-stub-fast-path", "", "A boolean value to enable or disable the fast path in systrap stub processes: true or false.")
+	f.IntVar(&d.wakeSemPID, "wake-sem-waiter", 0, "wakes up a semop call blocked on behalf of the given PID, which then fails with EINTR.")
 	f.BoolVar(&d.ps, "ps", false, "lists processes")
 }
 
@@ -212,6 +216,26 @@ func (d *Debug) Execute(_ context.Context, f *flag.FlagSet, args ...any) subcomm
 		}
 		util.Infof("Logging options changed")
 	}
+	if len(d.stubFastPath) != 0 {
+		enabled, err := strconv.ParseBool(d.stubFastPath)
+		if err != nil {
+			return util.Errorf("invalid value for systrap-stub-fast-path %q", d.stubFastPath)
+		}
+		if err := c.Sandbox.SetStubFastPath(enabled); err != nil {
+			return util.Errorf(err.Error())
+		}
+		util.Infof("Systrap stub fast path enabled: %t", enabled)
+	}
+	if d.wakeSemPID > 0 {
+		found, err := c.Sandbox.WakeSemaphoreWaiter(int32(d.wakeSemPID))
+		if err != nil {
+			return util.Errorf(err.Error())
+		}
+		if !found {
+			return util.Errorf("PID %d isn't blocked on a semaphore", d.wakeSemPID)
+		}
+		util.Infof("Semaphore waiter of PID %d woken", d.wakeSemPID)
+	}
 	if d.ps {
 		util.Infof("Retrieving process list")
 		pList, err := c.Processes()
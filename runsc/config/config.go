@@ -277,6 +277,10 @@ type Config struct {
 	// asynchronous I/O operations.
 	IOUring bool `flag:"iouring"`
 
+	// RTSignalQueueLimit is the maximum number of instances of each realtime
+	// signal that may be queued. If zero, the kernel's default is used.
+	RTSignalQueueLimit int `flag:"rt-signal-queue-limit"`
+
 	// DirectFS sets up the sandbox to directly access/mutate the filesystem from
 	// the sentry. Sentry runs with escalated privileges. Gofer process still
 	// exists, but is mostly idle. Not supported in rootless mode.
@@ -328,6 +332,9 @@ func (c *Config) validate() error {
 	if c.NumNetworkChannels <= 0 {
 		return fmt.Errorf("num_network_channels must be > 0, got: %d", c.NumNetworkChannels)
 	}
+	if c.RTSignalQueueLimit < 0 {
+		return fmt.Errorf("rt-signal-queue-limit must be >= 0, got: %d", c.RTSignalQueueLimit)
+	}
 	// Require profile flags to explicitly opt-in to profiling with
 	// -profile rather than implying it since these options have security
 	// implications.
@@ -1386,6 +1386,26 @@ func (s *Sandbox) ChangeLogging(args control.LoggingArgs) error {
 	return nil
 }
 
+// SetStubFastPath switches the systrap stub fast path on or off.
+func (s *Sandbox) SetStubFastPath(enabled bool) error {
+	log.Debugf("Set stub fast path %q: %t", s.ID, enabled)
+	if err := s.call(boot.DebugSetStubFastPath, &enabled, nil); err != nil {
+		return fmt.Errorf("setting sandbox %q stub fast path: %w", s.ID, err)
+	}
+	return nil
+}
+
+// WakeSemaphoreWaiter wakes up a semop(2) call blocked on behalf of the given
+// process. It returns whether such a call was found.
+func (s *Sandbox) WakeSemaphoreWaiter(pid int32) (bool, error) {
+	log.Debugf("Wake semaphore waiter %q: PID %d", s.ID, pid)
+	var found bool
+	if err := s.call(boot.DebugWakeSemaphoreWaiter, &pid, &found); err != nil {
+		return false, fmt.Errorf("waking semaphore waiter of PID %d in sandbox %q: %w", pid, s.ID, err)
+	}
+	return found, nil
+}
+
 // DestroyContainer destroys the given container. If it is the root container,
 // then the entire sandbox is destroyed.
 func (s *Sandbox) DestroyContainer(cid string) error {